@@ -0,0 +1,332 @@
+package compiler
+
+import (
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// An outputRoute is a terminal route as it appears in the output, with the
+// information that is needed to generate code for it.
+type outputRoute struct {
+	name     string
+	filename string
+	line     int
+	template []routeElement
+	params   []outputParam // in order of first occurrence in the template
+	tags     []string
+	methods  []string
+	hasGlob  bool
+	route    *RouteWithParents
+}
+
+type outputParam struct {
+	name      string
+	kind      routeElementKind
+	values    []string // for enumerated parameters
+	paramType string
+	optional  bool // true if the parameter is in an optional group
+}
+
+// getOutputRoutes returns the routes that are included in the output, in
+// order of their position in the input files. A route with alternations
+// appears once, although it's expanded into a family member for each
+// combination of alternatives.
+func getOutputRoutes(rrs *routeRegexps, filter *TagExpr) []outputRoute {
+	var routes []outputRoute
+	for _, g := range rrs.families {
+		for _, m := range g.members {
+			methods := matchingMethods(filter, m.route.Route.Info.Methods, filterTags(m.route.Route.Info.Tags, m.route.Route.Info.Meta))
+			if len(methods) == 0 || !firstAlternatives(m.route) {
+				continue
+			}
+
+			r := outputRoute{
+				name:     m.name,
+				filename: m.route.Route.Info.Filename,
+				line:     m.route.Route.Info.Line,
+				template: getTemplate(m.route),
+				tags:     computeTags(&m),
+				methods:  stringSetToList(methods),
+				route:    m.route,
+			}
+			seen := make(map[string]struct{})
+			optionalDepth := 0
+			for _, e := range r.template {
+				switch e.kind {
+				case parameter, integerParameter, restParameter:
+					if _, ok := seen[e.value]; !ok {
+						seen[e.value] = struct{}{}
+						r.params = append(r.params, outputParam{e.value, e.kind, e.values, e.paramType, optionalDepth > 0})
+					}
+				case optionalStart:
+					optionalDepth++
+				case optionalEnd:
+					optionalDepth--
+				case singleGlob, doubleGlob:
+					r.hasGlob = true
+				}
+			}
+			routes = append(routes, r)
+		}
+	}
+
+	sort.SliceStable(routes, func(i, j int) bool {
+		if routes[i].filename != routes[j].filename {
+			return routes[i].filename < routes[j].filename
+		}
+		if routes[i].line != routes[j].line {
+			return routes[i].line < routes[j].line
+		}
+		return routes[i].name < routes[j].name
+	})
+
+	return routes
+}
+
+// firstAlternatives reports whether the first alternative of each of the
+// alternations in the full path of a route was chosen when it was expanded.
+func firstAlternatives(rwp *RouteWithParents) bool {
+	for _, r := range append(slices.Clone(rwp.Parents), rwp.Route) {
+		for _, e := range r.Compiled.Elems {
+			if e.kind == constant && e.values != nil && e.value != e.values[0] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// paramKey identifies the set of parameters of a route (which distinguishes
+// routes with the same name).
+func (r *outputRoute) paramKey() string {
+	names := make([]string, len(r.params))
+	for i, p := range r.params {
+		names[i] = p.name
+	}
+	sort.Strings(names)
+	return strings.Join(names, "\x00")
+}
+
+// A routeVariant is the set of routes that share both a name and a set of
+// parameters. Code generators declare a params type for each variant.
+type routeVariant struct {
+	routes []*outputRoute
+}
+
+// groupRouteVariants groups routes by name and then by variant. The names and
+// the variants for each name are in order of first occurrence.
+func groupRouteVariants(routes []outputRoute) (names []string, variants map[string][]*routeVariant) {
+	variants = make(map[string][]*routeVariant)
+	for i := range routes {
+		r := &routes[i]
+		if _, ok := variants[r.name]; !ok {
+			names = append(names, r.name)
+		}
+
+		var v *routeVariant
+		for _, existing := range variants[r.name] {
+			if existing.routes[0].paramKey() == r.paramKey() {
+				v = existing
+				break
+			}
+		}
+		if v == nil {
+			v = &routeVariant{}
+			variants[r.name] = append(variants[r.name], v)
+		}
+		v.routes = append(v.routes, r)
+	}
+	return
+}
+
+// baseIdent returns an identifier in PascalCase for the variant, which is the
+// i-th variant of its route name. The first variant is identified by the route
+// name alone, and the others additionally by their parameters (e.g. 'Posts'
+// and 'PostsWithN').
+func (v *routeVariant) baseIdent(i int) string {
+	r := v.routes[0]
+	ident := pascalCase(r.name, "R")
+	if i > 0 {
+		ident += "With"
+		for _, p := range r.params {
+			ident += pascalCase(p.name, "P")
+		}
+	}
+	return ident
+}
+
+// buildableRoute returns the first route of the variant whose URL can be
+// constructed from its parameters, or nil if there is no such route.
+func (v *routeVariant) buildableRoute() *outputRoute {
+	for _, r := range v.routes {
+		if !r.hasGlob {
+			return r
+		}
+	}
+	// There's no value that could be substituted for a glob.
+	return nil
+}
+
+// templateString formats a template in the syntax of the text route format.
+func templateString(template []routeElement) string {
+	var sb strings.Builder
+	for _, e := range template {
+		switch e.kind {
+		case slash:
+			sb.WriteByte('/')
+		case constant:
+			sb.WriteString(e.value)
+		case parameter:
+			if e.values != nil {
+				sb.WriteString(":{" + e.value + " in " + strings.Join(e.values, ",") + "}")
+			} else if e.regexp != "" {
+				sb.WriteString(":{" + e.value + " ~ " + e.regexp + "}")
+			} else if e.paramType != "" {
+				sb.WriteString(":" + e.paramType + ":" + e.value)
+			} else {
+				sb.WriteString(":" + e.value)
+			}
+		case integerParameter:
+			sb.WriteString(":#" + e.value)
+		case restParameter:
+			sb.WriteString(":**" + e.value)
+		case singleGlob:
+			sb.WriteString("*")
+		case doubleGlob:
+			sb.WriteString("**")
+		case optionalStart:
+			sb.WriteByte('[')
+		case optionalEnd:
+			sb.WriteByte(']')
+		case alternation:
+			sb.WriteString("(" + strings.Join(e.values, "|") + ")")
+		}
+	}
+	return sb.String()
+}
+
+// optionalGroupEnd returns the index of the optionalEnd element that closes the
+// optional group starting at index i of the template.
+func optionalGroupEnd(template []routeElement, i int) int {
+	depth := 0
+	for j := i; j < len(template); j++ {
+		switch template[j].kind {
+		case optionalStart:
+			depth++
+		case optionalEnd:
+			depth--
+			if depth == 0 {
+				return j
+			}
+		}
+	}
+	panic("Internal error in 'optionalGroupEnd': unbalanced optional group")
+}
+
+// optionalGroupParams returns the names of the parameters in the optional group
+// starting at index i of the template (including those in nested groups).
+func optionalGroupParams(template []routeElement, i int) []string {
+	var names []string
+	for _, e := range template[i:optionalGroupEnd(template, i)] {
+		switch e.kind {
+		case parameter, integerParameter, restParameter:
+			if !slices.Contains(names, e.value) {
+				names = append(names, e.value)
+			}
+		}
+	}
+	return names
+}
+
+// expandOptionalGroups returns every template that can be obtained by removing
+// some of the optional groups of the template (and the markers of the others).
+// The first template has every group present and the last has none.
+func expandOptionalGroups(template []routeElement) [][]routeElement {
+	i := slices.IndexFunc(template, func(e routeElement) bool { return e.kind == optionalStart })
+	if i == -1 {
+		return [][]routeElement{template}
+	}
+	end := optionalGroupEnd(template, i)
+	var expanded [][]routeElement
+	for _, inner := range expandOptionalGroups(template[i+1 : end]) {
+		for _, rest := range expandOptionalGroups(template[end+1:]) {
+			expanded = append(expanded, slices.Concat(template[:i], inner, rest))
+		}
+	}
+	for _, rest := range expandOptionalGroups(template[end+1:]) {
+		expanded = append(expanded, slices.Concat(template[:i], rest))
+	}
+	return expanded
+}
+
+// expandAlternations returns every template that can be obtained by replacing
+// each alternation of the template with one of its alternatives.
+func expandAlternations(template []routeElement) [][]routeElement {
+	i := slices.IndexFunc(template, func(e routeElement) bool { return e.kind == alternation })
+	if i == -1 {
+		return [][]routeElement{template}
+	}
+	var expanded [][]routeElement
+	for _, alt := range template[i].values {
+		for _, rest := range expandAlternations(template[i+1:]) {
+			expanded = append(expanded, slices.Concat(template[:i], []routeElement{{kind: constant, value: alt}}, rest))
+		}
+	}
+	return expanded
+}
+
+// identAllocator hands out identifiers that are unique within some scope.
+type identAllocator struct {
+	used map[string]struct{}
+}
+
+func newIdentAllocator(reserved ...string) *identAllocator {
+	a := &identAllocator{make(map[string]struct{})}
+	for _, r := range reserved {
+		a.used[r] = struct{}{}
+	}
+	return a
+}
+
+// alloc returns ident, or ident followed by a number if ident is already in
+// use.
+func (a *identAllocator) alloc(ident string) string {
+	candidate := ident
+	for i := 2; ; i++ {
+		if _, ok := a.used[candidate]; !ok {
+			a.used[candidate] = struct{}{}
+			return candidate
+		}
+		candidate = ident + strconv.Itoa(i)
+	}
+}
+
+// pascalCase converts a route or parameter name to an identifier in
+// PascalCase. Characters that are neither letters nor digits separate words
+// and are dropped. The prefix is added if the result would not otherwise begin
+// with an upper case letter.
+func pascalCase(name string, prefix string) string {
+	var sb strings.Builder
+	startOfWord := true
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			startOfWord = true
+			continue
+		}
+		if startOfWord {
+			r = unicode.ToUpper(r)
+			startOfWord = false
+		}
+		sb.WriteRune(r)
+	}
+
+	ident := sb.String()
+	if first, _ := utf8.DecodeRuneInString(ident); !unicode.IsUpper(first) {
+		ident = prefix + ident
+	}
+	return ident
+}
@@ -0,0 +1,684 @@
+package compiler
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// A RouteSet is the set of routes included in the output for some route files
+// or the set of routes in some compiled JSON. Route sets are compared using
+// DiffRouteSets.
+type RouteSet struct {
+	routes []diffRoute
+}
+
+type diffRoute struct {
+	name            string
+	template        []routeElement
+	noTrailingSlash bool
+	methods         []string
+	params          map[string]routeElementKind
+	priority        int
+	matchRegexp     string
+	nfa             *node
+}
+
+// RouteChangeKind is a set of flags describing how a route differs between two
+// route sets.
+type RouteChangeKind int
+
+const (
+	RouteAdded RouteChangeKind = 1 << iota
+	RouteRemoved
+	RouteRenamed
+	RoutePatternChanged
+	RouteParamNamesChanged
+	RouteParamKindsChanged
+	RouteMethodsChanged
+	// RouteShadowed indicates that a route is otherwise unchanged but that some
+	// of its URLs are now matched by a higher priority route.
+	RouteShadowed
+)
+
+// A RouteChange describes how a route differs between two route sets.
+type RouteChange struct {
+	Kind       RouteChangeKind
+	OldName    string
+	NewName    string
+	OldPattern string
+	NewPattern string
+	OldMethods []string
+	NewMethods []string
+	// Breaking is set if some URL that the old route matched is now unmatched,
+	// or is matched by a different route or with different parameters.
+	// BrokenMethod and BrokenURL give an example of such a request, and
+	// NowMatchedBy gives the name of the route that now matches it (if any).
+	Breaking     bool
+	BrokenMethod string
+	BrokenURL    string
+	NowMatchedBy string
+}
+
+// RouteRegexpsToRouteSet returns the set of routes that are included in the
+// output.
+func RouteRegexpsToRouteSet(rrs *routeRegexps, filter *TagExpr) (RouteSet, error) {
+	var rs RouteSet
+	for _, r := range getOutputRoutes(rrs, filter) {
+		elems := r.route.Route.Compiled.Elems
+		nts := len(elems) > 0 && elems[len(elems)-1].kind == noTrailingSlash
+		re := templateMatchRegexp(r.template, nts)
+		nfa, err := regexpToNfa(re)
+		if err != nil {
+			return rs, err
+		}
+		rs.routes = append(rs.routes, diffRoute{
+			name:            r.name,
+			template:        r.template,
+			noTrailingSlash: nts,
+			methods:         r.methods,
+			params:          outputParamKinds(r.params),
+			priority:        r.route.Route.Info.Priority,
+			matchRegexp:     re,
+			nfa:             nfa,
+		})
+	}
+	return rs, nil
+}
+
+func outputParamKinds(params []outputParam) map[string]routeElementKind {
+	kinds := make(map[string]routeElementKind, len(params))
+	for _, p := range params {
+		kinds[p.name] = p.kind
+	}
+	return kinds
+}
+
+type compiledJSON struct {
+	Families map[string]compiledJSONFamily `json:"families"`
+}
+
+type compiledJSONFamily struct {
+	MatchRegexp string               `json:"matchRegexp"`
+	Members     []compiledJSONMember `json:"members"`
+}
+
+type compiledJSONMember struct {
+	Name         string            `json:"name"`
+	Tags         []string          `json:"tags"`
+	Methods      []string          `json:"methods"`
+	Template     []json.RawMessage `json:"template"`
+	Priority     int               `json:"priority"`
+	Alternatives []string          `json:"alternatives"`
+	Meta         map[string]string `json:"meta"`
+}
+
+// RouteSetFromJSON returns the set of routes in compiled JSON output that
+// match the filter. Compiled JSON doesn't record whether a route ends in '!/',
+// so this is determined by checking whether the regexp of the route's family
+// matches an example URL for the route with a trailing slash.
+func RouteSetFromJSON(input []byte, filter *TagExpr) (RouteSet, error) {
+	var rs RouteSet
+
+	var doc compiledJSON
+	if err := json.Unmarshal(input, &doc); err != nil {
+		return rs, err
+	}
+	if doc.Families == nil {
+		return rs, fmt.Errorf("expected compiled JSON to have a 'families' field")
+	}
+
+	for _, cp := range stringSetToList(doc.Families) {
+		f := doc.Families[cp]
+		re, ok := strings.CutPrefix(f.MatchRegexp, "^")
+		if ok {
+			re, ok = strings.CutSuffix(re, "(\\?[^#]*)?(#.*)?$")
+		}
+		if !ok {
+			return rs, fmt.Errorf("unexpected form of match regexp for family %q", cp)
+		}
+		familyNfa, err := regexpToNfa(re)
+		if err != nil {
+			return rs, fmt.Errorf("bad match regexp for family %q: %v", cp, err)
+		}
+
+		for _, m := range f.Members {
+			methods := matchingMethods(filter, stringListToSet(m.Methods), filterTags(stringListToSet(m.Tags), m.Meta))
+			if len(methods) == 0 {
+				continue
+			}
+
+			r := diffRoute{
+				name:     m.Name,
+				methods:  stringSetToList(methods),
+				params:   make(map[string]routeElementKind),
+				priority: m.Priority,
+			}
+			for _, te := range m.Template {
+				e, err := parseTemplateElement(te)
+				if err != nil {
+					return rs, fmt.Errorf("bad template for route %q: %v", m.Name, err)
+				}
+				switch e.kind {
+				case parameter, integerParameter, restParameter:
+					r.params[e.value] = e.kind
+				}
+				r.template = append(r.template, e)
+			}
+			if !firstTemplateAlternatives(r.template, m.Alternatives) {
+				// As with getOutputRoutes, a route with alternations is included
+				// only once.
+				continue
+			}
+
+			if len(r.template) > 1 && r.template[len(r.template)-1].kind != slash {
+				// Find out if the family matches a URL for the route with a
+				// trailing slash.
+				nts, err := regexpToNfa(templateMatchRegexp(r.template, true))
+				if err != nil {
+					return rs, err
+				}
+				if w, ok := overlapWitness(nts, familyNfa); ok && !run(familyNfa, w+"/") {
+					r.noTrailingSlash = true
+				}
+			}
+
+			r.matchRegexp = templateMatchRegexp(r.template, r.noTrailingSlash)
+			r.nfa, err = regexpToNfa(r.matchRegexp)
+			if err != nil {
+				return rs, err
+			}
+			rs.routes = append(rs.routes, r)
+		}
+	}
+
+	return rs, nil
+}
+
+// firstTemplateAlternatives reports whether the given alternatives of a family
+// member are the first alternatives of the alternations in its template.
+func firstTemplateAlternatives(template []routeElement, alternatives []string) bool {
+	i := 0
+	for _, e := range template {
+		if e.kind == alternation {
+			if i < len(alternatives) && alternatives[i] != e.values[0] {
+				return false
+			}
+			i++
+		}
+	}
+	return true
+}
+
+func parseTemplateElement(input json.RawMessage) (routeElement, error) {
+	var s string
+	if err := json.Unmarshal(input, &s); err == nil {
+		if s == "/" {
+			return routeElement{kind: slash}, nil
+		}
+		return routeElement{kind: constant, value: s}, nil
+	}
+
+	var a []string
+	if err := json.Unmarshal(input, &a); err != nil {
+		return routeElement{}, err
+	}
+	if len(a) == 1 {
+		switch a[0] {
+		case "*":
+			return routeElement{kind: singleGlob}, nil
+		case "**":
+			return routeElement{kind: doubleGlob}, nil
+		case "[":
+			return routeElement{kind: optionalStart}, nil
+		case "]":
+			return routeElement{kind: optionalEnd}, nil
+		}
+	}
+	if len(a) > 2 && a[0] == "|" {
+		return routeElement{kind: alternation, values: a[1:]}, nil
+	}
+	if len(a) > 2 && a[0] == ":" {
+		return routeElement{kind: parameter, value: a[1], values: a[2:]}, nil
+	}
+	if len(a) == 3 && a[0] == ":~" {
+		if _, _, ok := translateParamRegexp(a[2]); !ok {
+			return routeElement{}, fmt.Errorf("unsupported parameter regexp %q", a[2])
+		}
+		return routeElement{kind: parameter, value: a[1], regexp: a[2]}, nil
+	}
+	if len(a) == 2 {
+		if typ, ok := strings.CutPrefix(a[0], ":"); ok {
+			if _, ok := paramTypes[typ]; ok {
+				return routeElement{kind: parameter, value: a[1], paramType: typ}, nil
+			}
+		}
+		switch a[0] {
+		case ":":
+			return routeElement{kind: parameter, value: a[1]}, nil
+		case ":#":
+			return routeElement{kind: integerParameter, value: a[1]}, nil
+		case ":**":
+			return routeElement{kind: restParameter, value: a[1]}, nil
+		}
+	}
+	return routeElement{}, fmt.Errorf("unrecognized template element %v", string(input))
+}
+
+// templateMatchRegexp returns a regexp (without anchors or query and anchor
+// groups) that matches the paths matched by a route with the given template.
+func templateMatchRegexp(template []routeElement, noTrailingSlash bool) string {
+	if len(template) <= 1 {
+		return "\\/+\\/*"
+	}
+	term := "\\/*"
+	if template[len(template)-1].kind == slash {
+		term = "\\/+"
+	} else if noTrailingSlash {
+		term = ""
+	}
+	return "\\/+" + routeToRegexps(template).MatchRegexp + term
+}
+
+func stringListToSet(strs []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(strs))
+	for _, s := range strs {
+		set[s] = struct{}{}
+	}
+	return set
+}
+
+func (r *diffRoute) pattern() string {
+	if r.noTrailingSlash {
+		return templateString(r.template) + "!/"
+	}
+	return templateString(r.template)
+}
+
+func (r *diffRoute) sameParamNames(other *diffRoute) bool {
+	if len(r.params) != len(other.params) {
+		return false
+	}
+	for p := range r.params {
+		if _, ok := other.params[p]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *diffRoute) sameParamKinds(other *diffRoute) bool {
+	for p, k := range r.params {
+		if other.params[p] != k {
+			return false
+		}
+	}
+	return true
+}
+
+// sameParamOrder determines whether the parameters of the two routes occur in
+// the same order in their templates.
+func (r *diffRoute) sameParamOrder(other *diffRoute) bool {
+	paramNames := func(template []routeElement) []string {
+		var names []string
+		for _, e := range template {
+			switch e.kind {
+			case parameter, integerParameter, restParameter:
+				names = append(names, e.value)
+			}
+		}
+		return names
+	}
+	return slices.Equal(paramNames(r.template), paramNames(other.template))
+}
+
+// A paramBinding gives the name and kind of the parameter that captures some
+// span of a URL.
+type paramBinding struct {
+	name string
+	kind routeElementKind
+}
+
+// paramBindings returns the parameter that captures each span of a URL matched
+// by the route.
+func (r *diffRoute) paramBindings(url string) (map[[2]int]paramBinding, error) {
+	re, err := regexp.Compile("^(?:" + r.matchRegexp + ")$")
+	if err != nil {
+		return nil, err
+	}
+	m := re.FindStringSubmatchIndex(url)
+	if m == nil {
+		return nil, fmt.Errorf("route %v does not match %v", r.name, url)
+	}
+	bindings := make(map[[2]int]paramBinding)
+	if len(r.template) <= 1 {
+		return bindings, nil
+	}
+	for name, g := range routeToRegexps(r.template).ParamGroupNumbers {
+		if m[2*g] != -1 {
+			bindings[[2]int{m[2*g], m[2*g+1]}] = paramBinding{name, r.params[name]}
+		}
+	}
+	return bindings, nil
+}
+
+// changedParamBindings looks for a URL that is matched by both routes but whose
+// parameters have different names or kinds under the two routes. Parameters
+// are compared by the spans of the URL that they capture, so (for example)
+// '/x/:p/:q' and '/x/:q/:p' bind different parameters for every URL that they
+// both match.
+func changedParamBindings(old, new *diffRoute) (string, bool, error) {
+	if old.pattern() == new.pattern() {
+		return "", false, nil
+	}
+	w, ok := overlapWitness(old.nfa, new.nfa)
+	if !ok {
+		return "", false, nil
+	}
+	oldBindings, err := old.paramBindings(w)
+	if err != nil {
+		return "", false, err
+	}
+	newBindings, err := new.paramBindings(w)
+	if err != nil {
+		return "", false, err
+	}
+	if maps.Equal(oldBindings, newBindings) {
+		return "", false, nil
+	}
+	return w, true, nil
+}
+
+type routePair struct {
+	old, new *diffRoute
+}
+
+// DiffRouteSets reports the routes that have been added, removed or changed
+// in going from one route set to another. A change is breaking if some URL
+// matched by an old route is not matched by a new route with the same name,
+// method and parameter names, or is now matched by a higher priority route.
+// Changes are sorted by route name. An error is returned only if a route's
+// regexp is invalid.
+func DiffRouteSets(oldSet, newSet *RouteSet) ([]RouteChange, error) {
+	pairs := pairRoutes(oldSet, newSet)
+
+	newByName := make(map[string][]*diffRoute)
+	maxPriority := math.MinInt
+	for i := range newSet.routes {
+		r := &newSet.routes[i]
+		newByName[r.name] = append(newByName[r.name], r)
+		maxPriority = max(maxPriority, r.priority)
+	}
+
+	var changes []RouteChange
+	for _, p := range pairs {
+		c := RouteChange{Kind: routePairChangeKind(p)}
+		if p.old != nil {
+			c.OldName = p.old.name
+			c.OldPattern = p.old.pattern()
+			c.OldMethods = p.old.methods
+			var err error
+			c.BrokenMethod, c.BrokenURL, c.Breaking, err = findBrokenURL(p.old, newSet, newByName[p.old.name], maxPriority)
+			if err != nil {
+				return nil, err
+			}
+			if c.Breaking {
+				c.NowMatchedBy = routeInSet(newSet, c.BrokenMethod, c.BrokenURL)
+				if c.Kind == 0 {
+					c.Kind = RouteShadowed
+				}
+			}
+		}
+		if p.new != nil {
+			c.NewName = p.new.name
+			c.NewPattern = p.new.pattern()
+			c.NewMethods = p.new.methods
+		}
+		if c.Kind != 0 {
+			changes = append(changes, c)
+		}
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].name() < changes[j].name()
+	})
+
+	return changes, nil
+}
+
+// pairRoutes pairs each old route with the new route (if any) that it became.
+// Routes are paired by name, or by pattern if no route has the same name.
+func pairRoutes(oldSet, newSet *RouteSet) []routePair {
+	oldByName := make(map[string][]*diffRoute)
+	for i := range oldSet.routes {
+		r := &oldSet.routes[i]
+		oldByName[r.name] = append(oldByName[r.name], r)
+	}
+	newByName := make(map[string][]*diffRoute)
+	for i := range newSet.routes {
+		r := &newSet.routes[i]
+		newByName[r.name] = append(newByName[r.name], r)
+	}
+
+	var pairs []routePair
+	var unpairedOld, unpairedNew []*diffRoute
+
+	// Routes with the same name are paired by preference with a route that has
+	// the same pattern and methods, then the same pattern, then the same
+	// parameters, and otherwise in order.
+	matchers := []func(o, n *diffRoute) bool{
+		func(o, n *diffRoute) bool { return o.pattern() == n.pattern() && slices.Equal(o.methods, n.methods) },
+		func(o, n *diffRoute) bool { return o.pattern() == n.pattern() },
+		func(o, n *diffRoute) bool { return o.sameParamNames(n) },
+		func(o, n *diffRoute) bool { return true },
+	}
+	pairUp := func(olds, news []*diffRoute) (restOld, restNew []*diffRoute) {
+		for _, match := range matchers {
+			for i := 0; i < len(olds); i++ {
+				if j := slices.IndexFunc(news, func(n *diffRoute) bool { return match(olds[i], n) }); j != -1 {
+					pairs = append(pairs, routePair{olds[i], news[j]})
+					olds = slices.Delete(olds, i, i+1)
+					news = slices.Delete(news, j, j+1)
+					i--
+				}
+			}
+		}
+		return olds, news
+	}
+
+	for _, name := range stringSetToList(oldByName) {
+		restOld, restNew := pairUp(oldByName[name], newByName[name])
+		unpairedOld = append(unpairedOld, restOld...)
+		unpairedNew = append(unpairedNew, restNew...)
+	}
+	for _, name := range stringSetToList(newByName) {
+		if _, ok := oldByName[name]; !ok {
+			unpairedNew = append(unpairedNew, newByName[name]...)
+		}
+	}
+
+	// A route that has disappeared has been renamed if there is a new route
+	// with the same pattern.
+	for _, o := range unpairedOld {
+		if j := slices.IndexFunc(unpairedNew, func(n *diffRoute) bool { return o.pattern() == n.pattern() }); j != -1 {
+			pairs = append(pairs, routePair{o, unpairedNew[j]})
+			unpairedNew = slices.Delete(unpairedNew, j, j+1)
+		} else {
+			pairs = append(pairs, routePair{o, nil})
+		}
+	}
+	for _, n := range unpairedNew {
+		pairs = append(pairs, routePair{nil, n})
+	}
+
+	return pairs
+}
+
+func routePairChangeKind(p routePair) RouteChangeKind {
+	if p.old == nil {
+		return RouteAdded
+	}
+	if p.new == nil {
+		return RouteRemoved
+	}
+
+	var kind RouteChangeKind
+	if p.old.name != p.new.name {
+		kind |= RouteRenamed
+	}
+	if p.old.pattern() != p.new.pattern() {
+		kind |= RoutePatternChanged
+		if !p.old.sameParamNames(p.new) || !p.old.sameParamOrder(p.new) {
+			kind |= RouteParamNamesChanged
+		} else if !p.old.sameParamKinds(p.new) {
+			kind |= RouteParamKindsChanged
+		}
+	}
+	if !slices.Equal(p.old.methods, p.new.methods) {
+		kind |= RouteMethodsChanged
+	}
+	return kind
+}
+
+// findBrokenURL looks for a request that the old route matched but that the
+// new route set doesn't route in the same way. namesakes are the routes in the
+// new route set with the same name as the old route, and maxPriority is the
+// highest priority of any route in the new route set. A URL is also broken if
+// it is matched by a namesake but binds parameters with different names or
+// kinds.
+func findBrokenURL(old *diffRoute, newSet *RouteSet, namesakes []*diffRoute, maxPriority int) (method string, url string, broken bool, err error) {
+	for _, m := range old.methods {
+		var same []*diffRoute
+		for _, n := range namesakes {
+			if slices.Contains(n.methods, m) && n.sameParamNames(old) {
+				same = append(same, n)
+			}
+		}
+
+		if len(same) == 0 {
+			if w, ok := overlapWitness(old.nfa, old.nfa); ok {
+				return m, w, true, nil
+			}
+			continue
+		}
+
+		var union strings.Builder
+		union.WriteString("(?:")
+		minPriority := same[0].priority
+		for i, n := range same {
+			if i != 0 {
+				union.WriteByte('|')
+			}
+			union.WriteString(n.matchRegexp)
+			minPriority = min(minPriority, n.priority)
+		}
+		union.WriteByte(')')
+		unionNfa, err := regexpToNfa(union.String())
+		if err != nil {
+			return "", "", false, err
+		}
+		if w, ok := difference(old.nfa, unionNfa); ok {
+			return m, w, true, nil
+		}
+
+		for _, n := range same {
+			w, ok, err := changedParamBindings(old, n)
+			if err != nil {
+				return "", "", false, err
+			}
+			if ok {
+				return m, w, true, nil
+			}
+		}
+
+		// URLs may be taken by a new route with a higher priority.
+		if maxPriority <= minPriority {
+			continue
+		}
+		for i := range newSet.routes {
+			n := &newSet.routes[i]
+			if n.priority > minPriority && !slices.Contains(same, n) && slices.Contains(n.methods, m) {
+				if w, ok := overlapWitness(old.nfa, n.nfa); ok {
+					return m, w, true, nil
+				}
+			}
+		}
+	}
+	return "", "", false, nil
+}
+
+// routeInSet returns the name of the highest priority route in the set that
+// matches the method and URL, or "" if there is no such route.
+func routeInSet(rs *RouteSet, method, url string) string {
+	var best *diffRoute
+	for i := range rs.routes {
+		r := &rs.routes[i]
+		if slices.Contains(r.methods, method) && run(r.nfa, url) && (best == nil || r.priority > best.priority) {
+			best = r
+		}
+	}
+	if best == nil {
+		return ""
+	}
+	return best.name
+}
+
+func (c *RouteChange) name() string {
+	if c.OldName != "" {
+		return c.OldName
+	}
+	return c.NewName
+}
+
+func (c RouteChange) String() string {
+	var sb strings.Builder
+
+	switch {
+	case c.Kind&RouteAdded != 0:
+		fmt.Fprintf(&sb, "+ %v (%v %v): added", c.NewName, strings.Join(c.NewMethods, ", "), c.NewPattern)
+	case c.Kind&RouteRemoved != 0:
+		fmt.Fprintf(&sb, "- %v (%v %v): removed", c.OldName, strings.Join(c.OldMethods, ", "), c.OldPattern)
+	default:
+		fmt.Fprintf(&sb, "~ %v (%v %v):", c.OldName, strings.Join(c.OldMethods, ", "), c.OldPattern)
+		var descs []string
+		if c.Kind&RouteRenamed != 0 {
+			descs = append(descs, "renamed to "+c.NewName)
+		}
+		if c.Kind&RoutePatternChanged != 0 {
+			d := "pattern changed to " + c.NewPattern
+			if c.Kind&RouteParamNamesChanged != 0 {
+				d += " (parameter names changed)"
+			} else if c.Kind&RouteParamKindsChanged != 0 {
+				d += " (parameter kinds changed)"
+			}
+			descs = append(descs, d)
+		}
+		if c.Kind&RouteMethodsChanged != 0 {
+			descs = append(descs, "methods changed to "+strings.Join(c.NewMethods, ", "))
+		}
+		if c.Kind&RouteShadowed != 0 {
+			descs = append(descs, "shadowed by a higher priority route")
+		}
+		sb.WriteString(" ")
+		sb.WriteString(strings.Join(descs, "; "))
+	}
+
+	if c.Breaking {
+		fmt.Fprintf(&sb, "\n    BREAKING: %v %v ", c.BrokenMethod, c.BrokenURL)
+		switch c.NowMatchedBy {
+		case "":
+			sb.WriteString("is no longer matched")
+		case c.OldName:
+			sb.WriteString("is now matched with different parameters")
+		default:
+			fmt.Fprintf(&sb, "is now matched by %v", c.NowMatchedBy)
+		}
+	}
+
+	return sb.String()
+}
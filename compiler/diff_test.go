@@ -0,0 +1,189 @@
+package compiler
+
+import (
+	"strings"
+	"testing"
+)
+
+const diffTestRoutes = `
+root /
+users /users [users]
+  login [GET,POST] /login
+  settings /:user_id/settings
+  strict /:user_id/strict!/
+  slashed /:user_id/slashed/
+managers /managers [managers]
+  user /:manager_id/user/:#user_id
+  files /:manager_id/files/:**path
+  glob /:manager_id/glob/*/**
+`
+
+func TestDiffRouteSetsNoChanges(t *testing.T) {
+	old := getRouteSet(t, diffTestRoutes)
+	changes := mustDiffRouteSets(t, &old, &old)
+	if len(changes) != 0 {
+		t.Errorf("Expected no changes, got %v\n", changes)
+	}
+}
+
+func TestDiffRouteSetsFromJSON(t *testing.T) {
+	old := getRouteSet(t, diffTestRoutes)
+	fromJSON := getRouteSetFromJSON(t, diffTestRoutes)
+
+	if len(fromJSON.routes) != len(old.routes) {
+		t.Fatalf("Expected %v routes from JSON, got %v\n", len(old.routes), len(fromJSON.routes))
+	}
+	for _, changes := range [][]RouteChange{mustDiffRouteSets(t, &old, &fromJSON), mustDiffRouteSets(t, &fromJSON, &old)} {
+		if len(changes) != 0 {
+			t.Errorf("Expected no changes between route file and compiled JSON, got %v\n", changes)
+		}
+	}
+}
+
+func TestDiffRouteSets(t *testing.T) {
+	const newRoutes = `
+root /
+users /users [users]
+  login [GET] /login
+  profile /:user_id/profile
+  strict /:user_id/strict
+  slashed /:user_id/slashed!/
+managers /managers [managers]
+  member /:manager_id/user/:#user_id
+  files /:manager_id/files/:**path
+  glob /:manager_id/glob/*/**
+`
+
+	old := getRouteSet(t, diffTestRoutes)
+	new := getRouteSet(t, newRoutes)
+	assertChanges(t, mustDiffRouteSets(t, &old, &new),
+		"~ managers/user (GET /managers/:manager_id/user/:#user_id): renamed to managers/member\n"+
+			"    BREAKING: GET /managers/a/user/0 is now matched by managers/member",
+		"~ users/login (GET, POST /users/login): methods changed to GET\n"+
+			"    BREAKING: POST /users/login is no longer matched",
+		"+ users/profile (GET /users/:user_id/profile): added",
+		"- users/settings (GET /users/:user_id/settings): removed\n"+
+			"    BREAKING: GET /users/a/settings is no longer matched",
+		"~ users/slashed (GET /users/:user_id/slashed/): pattern changed to /users/:user_id/slashed!/\n"+
+			"    BREAKING: GET /users/a/slashed/ is no longer matched",
+		"~ users/strict (GET /users/:user_id/strict!/): pattern changed to /users/:user_id/strict",
+	)
+}
+
+func TestDiffRouteSetsParams(t *testing.T) {
+	old := getRouteSet(t, "a /a/:#x\nb /b/:x\nc /c/:x\nd /d/:x\ne /e/:p/:q\nf /f/:x\ng /g/:#x\n")
+	new := getRouteSet(t, "a /a/:x\nb /b/:#x\nc /c/:y\nd /d/:x/:y\ne /e/:q/:p\nf /f/:x!/\ng /(g|h)/:#x\n")
+	assertChanges(t, mustDiffRouteSets(t, &old, &new),
+		"~ a (GET /a/:#x): pattern changed to /a/:x (parameter kinds changed)\n"+
+			"    BREAKING: GET /a/0 is now matched with different parameters",
+		"~ b (GET /b/:x): pattern changed to /b/:#x (parameter kinds changed)\n"+
+			"    BREAKING: GET /b/a is no longer matched",
+		"~ c (GET /c/:x): pattern changed to /c/:y (parameter names changed)\n"+
+			"    BREAKING: GET /c/a is now matched with different parameters",
+		"~ d (GET /d/:x): pattern changed to /d/:x/:y (parameter names changed)\n"+
+			"    BREAKING: GET /d/a is no longer matched",
+		"~ e (GET /e/:p/:q): pattern changed to /e/:q/:p (parameter names changed)\n"+
+			"    BREAKING: GET /e/a/a is now matched with different parameters",
+		"~ f (GET /f/:x): pattern changed to /f/:x!/\n"+
+			"    BREAKING: GET /f/a/ is no longer matched",
+		"~ g (GET /g/:#x): pattern changed to /(g|h)/:#x",
+	)
+}
+
+func TestDiffRouteSetsPriority(t *testing.T) {
+	old := getRouteSet(t, "show /users/:id\n")
+	new := getRouteSet(t, "show /users/:id\nnew ^1 /users/new\n")
+	assertChanges(t, mustDiffRouteSets(t, &old, &new),
+		"+ new (GET /users/new): added",
+		"~ show (GET /users/:id): shadowed by a higher priority route\n"+
+			"    BREAKING: GET /users/new is now matched by new",
+	)
+}
+
+func TestDiffRouteSetsAlternations(t *testing.T) {
+	const routes = "photo /(photos|images)/:id\n"
+	old := getRouteSet(t, routes)
+	fromJSON := getRouteSetFromJSON(t, routes)
+	if len(old.routes) != 1 || len(fromJSON.routes) != 1 {
+		t.Fatalf("Expected one route, got %v and %v\n", len(old.routes), len(fromJSON.routes))
+	}
+	if changes := mustDiffRouteSets(t, &old, &fromJSON); len(changes) != 0 {
+		t.Errorf("Expected no changes between route file and compiled JSON, got %v\n", changes)
+	}
+
+	new := getRouteSet(t, "photo /photos/:id\n")
+	assertChanges(t, mustDiffRouteSets(t, &old, &new),
+		"~ photo (GET /(photos|images)/:id): pattern changed to /photos/:id\n"+
+			"    BREAKING: GET /images/a is no longer matched",
+	)
+}
+
+func TestRouteSetFromJSONErrors(t *testing.T) {
+	for _, input := range []string{`[]`, `{}`, `{"families":{"x":{"matchRegexp":"x","members":[]}}}`, `{"families":{"x":{"matchRegexp":"^(?:(\\/+))(\\?[^#]*)?(#.*)?$","members":[{"name":"x","methods":["GET"],"template":["/",["?"]]}]}}}`} {
+		if _, err := RouteSetFromJSON([]byte(input), nil); err == nil {
+			t.Errorf("Expected error for %v\n", input)
+		}
+	}
+}
+
+func mustDiffRouteSets(t *testing.T, oldSet, newSet *RouteSet) []RouteChange {
+	t.Helper()
+
+	changes, err := DiffRouteSets(oldSet, newSet)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	return changes
+}
+
+func getRouteSet(t *testing.T, routeFile string) RouteSet {
+	t.Helper()
+
+	rrs := getRouteRegexpsForDiff(t, routeFile)
+	rs, err := RouteRegexpsToRouteSet(&rrs, nil)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	return rs
+}
+
+func getRouteSetFromJSON(t *testing.T, routeFile string) RouteSet {
+	t.Helper()
+
+	rrs := getRouteRegexpsForDiff(t, routeFile)
+	json, _ := RouteRegexpsToJSON(&rrs, nil)
+	rs, err := RouteSetFromJSON(json, nil)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	return rs
+}
+
+func getRouteRegexpsForDiff(t *testing.T, routeFile string) routeRegexps {
+	t.Helper()
+
+	entries, errors := ParseRouteFile(strings.NewReader(routeFile), DisallowUpperCase)
+	if len(errors) > 0 {
+		t.Fatalf("Errors parsing route file: %+v\n", errors)
+	}
+	routes, errors := ProcessRouteFiles([][]RouteFileEntry{entries}, []string{""}, "/")
+	errors = append(errors, CheckForGroupErrors(routes)...)
+	if len(errors) > 0 {
+		t.Fatalf("Errors processing route file: %+v\n", errors)
+	}
+	return GetRouteRegexps(routes, nil)
+}
+
+func assertChanges(t *testing.T, changes []RouteChange, expected ...string) {
+	t.Helper()
+
+	if len(changes) != len(expected) {
+		t.Errorf("Expected %v changes, got %v:\n%v\n", len(expected), len(changes), changes)
+		return
+	}
+	for i := range changes {
+		if s := changes[i].String(); s != expected[i] {
+			t.Errorf("Expected change %v to be\n%v\ngot\n%v\n", i, expected[i], s)
+		}
+	}
+}
@@ -0,0 +1,332 @@
+package compiler
+
+import (
+	"fmt"
+	"go/format"
+	"go/token"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// goReservedIdents are the exported identifiers declared by every generated Go
+// file.
+var goReservedIdents = []string{"RouteName", "Params", "Match", "MatchMethod"}
+
+// A goVariant is a routeVariant together with the identifiers of its params
+// struct and URLFor function.
+type goVariant struct {
+	*routeVariant
+	structIdent string
+	urlForIdent string
+	fields      []goField
+}
+
+type goField struct {
+	ident string
+	param outputParam
+}
+
+// RouteRegexpsToGo generates the source of a Go package that declares a
+// constant for each route name, a params struct for each route, a URLFor
+// function for each route that doesn't contain a glob, and Match, MatchMethod
+// and MatchHost functions that wrap router.Route, router.RouteMethod and
+// router.RouteHost. The
+// generated code is intended to be used with the JSON output for the same
+// routes and filter. An error is returned if packageName is not a valid Go
+// package name.
+func RouteRegexpsToGo(rrs *routeRegexps, filter *TagExpr, packageName string) ([]byte, error) {
+	if !IsGoPackageName(packageName) {
+		return nil, fmt.Errorf("%q is not a valid Go package name", packageName)
+	}
+
+	names, rvs := groupRouteVariants(getOutputRoutes(rrs, filter))
+
+	idents := newIdentAllocator(goReservedIdents...)
+	nameIdents := make(map[string]string)
+	variants := make(map[string][]*goVariant)
+	for _, name := range names {
+		nameIdents[name] = idents.alloc("Route" + pascalCase(name, ""))
+	}
+	for _, name := range names {
+		for i, rv := range rvs[name] {
+			v := &goVariant{routeVariant: rv}
+			v.structIdent = idents.alloc(rv.baseIdent(i) + "Params")
+			v.urlForIdent = idents.alloc("URLFor" + strings.TrimSuffix(v.structIdent, "Params"))
+			fieldIdents := newIdentAllocator("RouteName")
+			for _, p := range rv.routes[0].params {
+				v.fields = append(v.fields, goField{fieldIdents.alloc(pascalCase(p.name, "P")), p})
+			}
+			variants[name] = append(variants[name], v)
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString(`// RouteName is the name of a route.
+type RouteName string
+
+`)
+
+	sb.WriteString("const (\n")
+	for _, name := range names {
+		fmt.Fprintf(&sb, "%v RouteName = %v\n", nameIdents[name], strconv.Quote(name))
+	}
+	sb.WriteString(")\n\n")
+
+	sb.WriteString(`// Params is implemented by the params struct of each route.
+type Params interface {
+	RouteName() RouteName
+}
+
+`)
+
+	usesStrconv := false
+	for _, name := range names {
+		for _, v := range variants[name] {
+			if writeGoVariant(&sb, nameIdents[name], v) {
+				usesStrconv = true
+			}
+		}
+	}
+
+	writeGoMatch(&sb, names, variants)
+
+	var header strings.Builder
+	header.WriteString("// Code generated by claney. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&header, "package %v\n\nimport (\n", packageName)
+	header.WriteString("\"fmt\"\n\"net/url\"\n\"sort\"\n")
+	if usesStrconv {
+		header.WriteString("\"strconv\"\n")
+	}
+	header.WriteString("\"strings\"\n\n\"github.com/addrummond/claney/router\"\n)\n\n")
+
+	src, err := format.Source([]byte(header.String() + sb.String()))
+	if err != nil {
+		return nil, fmt.Errorf("generated Go code could not be formatted: %v", err)
+	}
+	return src, nil
+}
+
+// IsGoPackageName determines whether a name can be used as the package name of
+// the generated Go code.
+func IsGoPackageName(name string) bool {
+	return token.IsIdentifier(name) && !token.IsKeyword(name)
+}
+
+// writeGoVariant writes the params struct and URLFor function for a variant. It
+// returns true if the generated code uses the strconv package.
+func writeGoVariant(sb *strings.Builder, nameIdent string, v *goVariant) bool {
+	r := v.routes[0]
+
+	fmt.Fprintf(sb, "// %v holds the parameters of the %v route (%v).\n", v.structIdent, r.name, templateString(r.template))
+	if len(v.fields) == 0 {
+		fmt.Fprintf(sb, "type %v struct{}\n\n", v.structIdent)
+	} else {
+		fmt.Fprintf(sb, "type %v struct {\n", v.structIdent)
+		for _, f := range v.fields {
+			typ := "string"
+			if f.param.kind == integerParameter {
+				typ = "int64"
+			}
+			if f.param.optional {
+				// nil if the parameter's optional group is absent
+				typ = "*" + typ
+			}
+			fmt.Fprintf(sb, "%v %v\n", f.ident, typ)
+		}
+		sb.WriteString("}\n\n")
+	}
+
+	fmt.Fprintf(sb, "func (%v) RouteName() RouteName { return %v }\n\n", v.structIdent, nameIdent)
+
+	buildable := v.buildableRoute()
+	if buildable == nil {
+		return false
+	}
+
+	fieldIdents := make(map[string]string)
+	optional := make(map[string]bool)
+	for _, f := range v.fields {
+		fieldIdents[f.param.name] = f.ident
+		optional[f.param.name] = f.param.optional
+	}
+
+	fmt.Fprintf(sb, "// %v returns the path of the %v route.\n", v.urlForIdent, r.name)
+	fmt.Fprintf(sb, "func %v(p %v) (string, error) {\n", v.urlForIdent, v.structIdent)
+	sb.WriteString("var sb strings.Builder\n")
+	usesStrconv := false
+	var literal strings.Builder
+	flush := func() {
+		if literal.Len() > 0 {
+			fmt.Fprintf(sb, "sb.WriteString(%v)\n", strconv.Quote(literal.String()))
+			literal.Reset()
+		}
+	}
+	// groupParams holds, for each enclosing optional group, the name of its
+	// parameter if it has exactly one (and so is known to be given inside it).
+	var groupParams []string
+	// paramValue returns an expression for the value of a parameter, first
+	// checking that the parameter is given if it's optional.
+	paramValue := func(name string) string {
+		flush()
+		if !optional[name] {
+			return "p." + fieldIdents[name]
+		}
+		if slices.Contains(groupParams, name) {
+			return "*p." + fieldIdents[name]
+		}
+		fmt.Fprintf(sb, "if p.%v == nil {\nreturn \"\", fmt.Errorf(\"%%w: %%v\", router.ErrMissingParam, %v)\n}\n", fieldIdents[name], strconv.Quote(name))
+		return "*p." + fieldIdents[name]
+	}
+	template := buildable.template
+	for i := 0; i < len(template); i++ {
+		e := template[i]
+		switch e.kind {
+		case slash:
+			literal.WriteByte('/')
+		case constant:
+			literal.WriteString(e.value)
+		case alternation:
+			literal.WriteString(e.values[0])
+		case parameter:
+			fmt.Fprintf(sb, "if err := writeParam(&sb, %v, %v); err != nil {\nreturn \"\", err\n}\n", strconv.Quote(e.value), paramValue(e.value))
+		case integerParameter:
+			fmt.Fprintf(sb, "sb.WriteString(strconv.FormatInt(%v, 10))\n", paramValue(e.value))
+			usesStrconv = true
+		case restParameter:
+			fmt.Fprintf(sb, "if err := writeRestParam(&sb, %v, %v); err != nil {\nreturn \"\", err\n}\n", strconv.Quote(e.value), paramValue(e.value))
+		case optionalStart:
+			// An optional group is included if any of its parameters are given,
+			// so a group without parameters is never included.
+			params := optionalGroupParams(template, i)
+			if len(params) == 0 {
+				i = optionalGroupEnd(template, i)
+				continue
+			}
+			if len(params) == 1 {
+				groupParams = append(groupParams, params[0])
+			} else {
+				groupParams = append(groupParams, "")
+			}
+			flush()
+			conds := make([]string, len(params))
+			for j, name := range params {
+				conds[j] = "p." + fieldIdents[name] + " != nil"
+			}
+			fmt.Fprintf(sb, "if %v {\n", strings.Join(conds, " || "))
+		case optionalEnd:
+			groupParams = groupParams[:len(groupParams)-1]
+			flush()
+			sb.WriteString("}\n")
+		}
+	}
+	flush()
+	sb.WriteString("return sb.String(), nil\n}\n\n")
+	return usesStrconv
+}
+
+func writeGoMatch(sb *strings.Builder, names []string, variants map[string][]*goVariant) {
+	sb.WriteString(`// Match routes the given URL using router.Route and returns the params struct
+// of the matching route together with the router's result. A URL whose
+// integer parameter values do not fit in an int64 is not matched.
+func Match(r *router.Router, url string) (Params, router.RouteResult, bool) {
+	result, ok := router.Route(r, url)
+	if !ok {
+		return nil, result, false
+	}
+	p, ok := paramsFromResult(&result)
+	return p, result, ok
+}
+
+// MatchMethod is like Match but uses router.RouteMethod. A non-nil Params value
+// is returned only if the status is router.Found.
+func MatchMethod(r *router.Router, method, url string) (Params, router.RouteResult, router.Status) {
+	result, status := router.RouteMethod(r, method, url)
+	if status != router.Found {
+		return nil, result, status
+	}
+	p, ok := paramsFromResult(&result)
+	if !ok {
+		return nil, router.RouteResult{}, router.NotFound
+	}
+	return p, result, status
+}
+
+// MatchHost is like MatchMethod but uses router.RouteHost, so that routes
+// restricted to the given host are also considered.
+func MatchHost(r *router.Router, method, host, url string) (Params, router.RouteResult, router.Status) {
+	result, status := router.RouteHost(r, method, host, url)
+	if status != router.Found {
+		return nil, result, status
+	}
+	p, ok := paramsFromResult(&result)
+	if !ok {
+		return nil, router.RouteResult{}, router.NotFound
+	}
+	return p, result, status
+}
+
+func paramsFromResult(result *router.RouteResult) (Params, bool) {
+	switch matchKey(result) {
+`)
+	for _, name := range names {
+		for _, v := range variants[name] {
+			fmt.Fprintf(sb, "case %v:\n", strconv.Quote(name+"\x00"+v.routes[0].paramKey()))
+			var values []string
+			for i, f := range v.fields {
+				name := strconv.Quote(f.param.name)
+				switch {
+				case f.param.optional && f.param.kind == integerParameter:
+					fmt.Fprintf(sb, "var v%v *int64\nif _, ok := result.Params[%v]; ok {\nn, err := result.Int64(%v)\nif err != nil {\nreturn nil, false\n}\nv%v = &n\n}\n", i, name, name, i)
+					values = append(values, fmt.Sprintf("%v: v%v", f.ident, i))
+				case f.param.optional:
+					fmt.Fprintf(sb, "var v%v *string\nif s, ok := result.Params[%v]; ok {\nv%v = &s\n}\n", i, name, i)
+					values = append(values, fmt.Sprintf("%v: v%v", f.ident, i))
+				case f.param.kind == integerParameter:
+					fmt.Fprintf(sb, "v%v, err := result.Int64(%v)\nif err != nil {\nreturn nil, false\n}\n", i, name)
+					values = append(values, fmt.Sprintf("%v: v%v", f.ident, i))
+				default:
+					values = append(values, fmt.Sprintf("%v: result.Params[%v]", f.ident, name))
+				}
+			}
+			fmt.Fprintf(sb, "return %v{%v}, true\n", v.structIdent, strings.Join(values, ", "))
+		}
+	}
+	sb.WriteString(`}
+	return nil, false
+}
+
+func matchKey(result *router.RouteResult) string {
+	names := make([]string, 0, len(result.ParamKinds))
+	for name := range result.ParamKinds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return result.Name + "\x00" + strings.Join(names, "\x00")
+}
+
+func writeParam(sb *strings.Builder, name, value string) error {
+	if value == "" {
+		return fmt.Errorf("%w: %v", router.ErrEmptyParam, name)
+	}
+	if strings.IndexByte(value, '/') != -1 {
+		return fmt.Errorf("%w: %v", router.ErrSlashInParam, name)
+	}
+	sb.WriteString(url.PathEscape(value))
+	return nil
+}
+
+func writeRestParam(sb *strings.Builder, name, value string) error {
+	if strings.Trim(value, "/") == "" {
+		return fmt.Errorf("%w: %v", router.ErrEmptyParam, name)
+	}
+	for i, segment := range strings.Split(value, "/") {
+		if i != 0 {
+			sb.WriteByte('/')
+		}
+		sb.WriteString(url.PathEscape(segment))
+	}
+	return nil
+}
+`)
+}
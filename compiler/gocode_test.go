@@ -0,0 +1,140 @@
+package compiler
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestRouteRegexpsToGo(t *testing.T) {
+	const routeFile = `
+root /
+managers /managers/
+  .
+  user     /:manager_id/user/:#user_id
+  files    /:manager_id/files/:**path
+  glob     /glob/*
+users /users!/
+  .
+  profile  /:user_id/profile [admin]
+posts /posts
+posts /posts/:#n
+params /params/:{route name}
+photo /(photos|images)/:id
+`
+
+	src := getGoSource(t, routeFile, nil)
+
+	if _, err := parser.ParseFile(token.NewFileSet(), "routes.go", src, 0); err != nil {
+		t.Fatalf("Generated code does not parse: %v\n%s\n", err, src)
+	}
+
+	for _, expected := range []string{
+		"package routes\n",
+		`RouteManagersUser  RouteName = "managers/user"`,
+		"type ManagersUserParams struct {\n\tManagerId string\n\tUserId    int64\n}",
+		"func URLForManagersUser(p ManagersUserParams) (string, error) {",
+		`if err := writeRestParam(&sb, "path", p.Path); err != nil {`,
+		"type PostsParams struct{}",
+		"type PostsWithNParams struct {\n\tN int64\n}",
+		"func URLForPostsWithN(p PostsWithNParams) (string, error) {",
+		`case "posts\x00n":`,
+		"type ParamsParams struct {\n\tRouteName2 string\n}",
+		"func Match(r *router.Router, url string) (Params, router.RouteResult, bool) {",
+		"func MatchHost(r *router.Router, method, host, url string) (Params, router.RouteResult, router.Status) {",
+		"func URLForPhoto(p PhotoParams) (string, error) {\n\tvar sb strings.Builder\n\tsb.WriteString(\"/photos/\")",
+	} {
+		if !strings.Contains(string(src), expected) {
+			t.Errorf("Expected generated code to contain\n%v\n", expected)
+		}
+	}
+
+	if strings.Contains(string(src), "URLForManagersGlob") {
+		t.Errorf("Expected no URL builder for route containing glob\n")
+	}
+}
+
+func TestRouteRegexpsToGoFilter(t *testing.T) {
+	const routeFile = `
+a /a [admin]
+b /b
+`
+
+	filter, err := ParseTagExpr("!admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(getGoSource(t, routeFile, filter))
+
+	if strings.Contains(src, "RouteA ") || !strings.Contains(src, "RouteB ") {
+		t.Errorf("Expected filter to be applied to generated code:\n%v\n", src)
+	}
+	if strings.Contains(src, `"strconv"`) {
+		t.Errorf("Expected strconv not to be imported:\n%v\n", src)
+	}
+}
+
+func TestRouteRegexpsToGoOptionalGroups(t *testing.T) {
+	const routeFile = `
+search /search[/:q[/:#page]]
+`
+
+	src := string(getGoSource(t, routeFile, nil))
+
+	if _, err := parser.ParseFile(token.NewFileSet(), "routes.go", src, 0); err != nil {
+		t.Fatalf("Generated code does not parse: %v\n%s\n", err, src)
+	}
+
+	for _, expected := range []string{
+		"type SearchParams struct {\n\tQ    *string\n\tPage *int64\n}",
+		"\tif p.Q != nil || p.Page != nil {\n\t\tsb.WriteString(\"/\")\n\t\tif p.Q == nil {\n\t\t\treturn \"\", fmt.Errorf(\"%w: %v\", router.ErrMissingParam, \"q\")\n\t\t}",
+		"\t\tif p.Page != nil {\n\t\t\tsb.WriteString(\"/\")\n\t\t\tsb.WriteString(strconv.FormatInt(*p.Page, 10))\n\t\t}",
+		"\t\tif s, ok := result.Params[\"q\"]; ok {",
+	} {
+		if !strings.Contains(src, expected) {
+			t.Errorf("Expected generated code to contain\n%v\n", expected)
+		}
+	}
+}
+
+func TestPascalCase(t *testing.T) {
+	cases := []struct{ name, expected string }{
+		{"managers/user", "ManagersUser"},
+		{"user_id", "UserId"},
+		{"fooBar", "FooBar"},
+		{"9lives", "X9lives"},
+		{"_", "X"},
+	}
+	for _, c := range cases {
+		if got := pascalCase(c.name, "X"); got != c.expected {
+			t.Errorf("Expected pascalCase(%q) to be %q, got %q\n", c.name, c.expected, got)
+		}
+	}
+}
+
+func TestRouteRegexpsToGoBadPackageName(t *testing.T) {
+	for _, name := range []string{"my-routes", "func", "", "1routes"} {
+		rrs := GetRouteRegexps(nil, nil)
+		if _, err := RouteRegexpsToGo(&rrs, nil, name); err == nil {
+			t.Errorf("Expected an error for package name %q\n", name)
+		}
+	}
+}
+
+func getGoSource(t *testing.T, routeFile string, filter *TagExpr) []byte {
+	entries, errs := ParseRouteFile(strings.NewReader(routeFile), DisallowUpperCase)
+	if len(errs) > 0 {
+		t.Fatalf("%+v\n", errs)
+	}
+	routes, errs := ProcessRouteFiles([][]RouteFileEntry{entries}, []string{""}, "/")
+	if len(errs) > 0 {
+		t.Fatalf("%+v\n", errs)
+	}
+	rrs := GetRouteRegexps(routes, filter)
+	src, err := RouteRegexpsToGo(&rrs, filter, "routes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return src
+}
@@ -4,6 +4,9 @@ import (
 	"bytes"
 	"fmt"
 	"runtime"
+	"slices"
+	"sort"
+	"strconv"
 	"sync"
 	"unicode/utf8"
 )
@@ -159,16 +162,25 @@ func parseRange(regexp string, i int) ([4]uint64, int, error) {
 
 	var mask [4]uint64
 
-	// We don't need general support for ranges, so just special case '0-9'
-	if i+3 < len(regexp) && regexp[i] == '0' && regexp[i+1] == '-' && regexp[i+2] == '9' && regexp[i+3] == ']' {
-		i += 3
-		mask[0] = 0x3ff000000000000
-		return mask, i, nil
+	readChar := func() (rune, error) {
+		r, l := utf8.DecodeRuneInString(regexp[i:])
+		if r == '\\' {
+			i++
+			if i >= len(regexp) {
+				return 0, fmt.Errorf("expected char following \\ escape in chargroup")
+			}
+			r, l = utf8.DecodeRuneInString(regexp[i:])
+		}
+		i += l
+		if r > 127 {
+			return 0, fmt.Errorf("only ASCII chars permitted in char classes")
+		}
+		return r, nil
 	}
 
 	orig := i
 	not := false
-	chars := make([]rune, 0)
+	empty := true
 	for {
 		if i == len(regexp) {
 			return mask, i, fmt.Errorf("unexpected end of regexp after '['")
@@ -179,33 +191,31 @@ func parseRange(regexp string, i int) ([4]uint64, int, error) {
 		} else if regexp[i] == ']' {
 			break
 		} else {
-			r, l := utf8.DecodeRuneInString(regexp[i:])
-			if r == '\\' {
+			lo, err := readChar()
+			if err != nil {
+				return mask, i, err
+			}
+			hi := lo
+			if i+1 < len(regexp) && regexp[i] == '-' && regexp[i+1] != ']' {
 				i++
-				if i >= len(regexp) {
-					return mask, i, fmt.Errorf("expected char following \\ escape in chargroup")
+				if hi, err = readChar(); err != nil {
+					return mask, i, err
+				}
+				if hi < lo {
+					return mask, i, fmt.Errorf("bad range %c-%c in char class", lo, hi)
 				}
-				r, l = utf8.DecodeRuneInString(regexp[i:])
-				chars = append(chars, r)
-				i += l
-			} else {
-				chars = append(chars, r)
-				i += l
 			}
+			for c := lo; c <= hi; c++ {
+				setMask(&mask, byte(c))
+			}
+			empty = false
 		}
 	}
 
-	if len(chars) == 0 {
+	if empty {
 		return mask, i, fmt.Errorf("empty [] char class")
 	}
 
-	for _, c := range chars {
-		if c > 127 {
-			return mask, i, fmt.Errorf("only ASCII chars permitted in char classes")
-		}
-		setMask(&mask, byte(c))
-	}
-
 	if not {
 		invertMask(&mask)
 	}
@@ -324,6 +334,214 @@ func overlap(n1, n2 *node) bool {
 	}
 }
 
+// A witnessVisit records how a state was reached during a breadth first search
+// for a witness string, so that the string can be reconstructed.
+type witnessVisit struct {
+	parent  int // index into visits, or -1 for an initial state
+	hasByte bool
+	b       byte
+}
+
+// witnessString reconstructs the string that leads to the state of the i'th
+// visit.
+func witnessString(visits []witnessVisit, i int) string {
+	var bs []byte
+	for ; i != -1; i = visits[i].parent {
+		if visits[i].hasByte {
+			bs = append(bs, visits[i].b)
+		}
+	}
+	slices.Reverse(bs)
+	return string(bs)
+}
+
+// overlapWitness returns a shortest string that is accepted by both n1 and n2,
+// or false if there is no such string. It walks the same product automaton as
+// overlap, but records how each state was reached so that the string can be
+// reconstructed. This makes it slower than overlap, so it should be used only
+// once an overlap is known to exist.
+func overlapWitness(n1, n2 *node) (string, bool) {
+	type state struct {
+		n1, n2 *node
+	}
+
+	visited := map[state]struct{}{{n1, n2}: {}}
+	states := []state{{n1, n2}}
+	visits := []witnessVisit{{parent: -1}}
+
+	add := func(s state, v witnessVisit) {
+		if _, ok := visited[s]; !ok {
+			visited[s] = struct{}{}
+			states = append(states, s)
+			visits = append(visits, v)
+		}
+	}
+
+	// Breadth first, so that the witness found is as short as possible.
+	for i := 0; i < len(states); i++ {
+		s := states[i]
+
+		foundTerm := false
+		epsilonStep(s.n1, func(e1 *node) iterState {
+			return epsilonStep(s.n2, func(e2 *node) iterState {
+				if isTerminalNode(e1) && isTerminalNode(e2) {
+					foundTerm = true
+					return iterBreak
+				}
+				add(state{e1, e2}, witnessVisit{parent: i})
+				return iterContinue
+			})
+		})
+		if foundTerm {
+			return witnessString(visits, i), true
+		}
+
+		var common [4]uint64
+		for j := range common {
+			common[j] = s.n1.mask[j] & s.n2.mask[j]
+		}
+		if b, ok := witnessByte(&common); ok {
+			add(state{s.n1.next, s.n2.next}, witnessVisit{parent: i, hasByte: true, b: b})
+		}
+	}
+
+	return "", false
+}
+
+// difference returns a shortest string that is accepted by n1 but not by n2,
+// or false if every string accepted by n1 is accepted by n2. It walks the
+// product of n1 with the subset construction of n2, so n2 should be small.
+func difference(n1, n2 *node) (string, bool) {
+	ids := make(map[*node]int)
+	closure := func(ns []*node) ([]*node, string) {
+		seen := make(map[*node]struct{})
+		var out []*node
+		for _, n := range ns {
+			epsilonStep(n, func(e *node) iterState {
+				if _, ok := seen[e]; !ok {
+					seen[e] = struct{}{}
+					out = append(out, e)
+				}
+				return iterContinue
+			})
+		}
+		sortedIds := make([]int, len(out))
+		for i, n := range out {
+			id, ok := ids[n]
+			if !ok {
+				id = len(ids)
+				ids[n] = id
+			}
+			sortedIds[i] = id
+		}
+		sort.Ints(sortedIds)
+		return out, fmt.Sprint(sortedIds)
+	}
+
+	type state struct {
+		n1     *node
+		n2s    []*node
+		n2sKey string
+	}
+	type stateKey struct {
+		n1     *node
+		n2sKey string
+	}
+
+	visited := make(map[stateKey]struct{})
+	var states []state
+	var visits []witnessVisit
+
+	add := func(n1 *node, n2s []*node, n2sKey string, v witnessVisit) {
+		epsilonStep(n1, func(e1 *node) iterState {
+			k := stateKey{e1, n2sKey}
+			if _, ok := visited[k]; !ok {
+				visited[k] = struct{}{}
+				states = append(states, state{e1, n2s, n2sKey})
+				visits = append(visits, v)
+			}
+			return iterContinue
+		})
+	}
+
+	n2s, n2sKey := closure([]*node{n2})
+	add(n1, n2s, n2sKey, witnessVisit{parent: -1})
+
+	// Breadth first, so that the witness found is as short as possible.
+	for i := 0; i < len(states); i++ {
+		s := states[i]
+
+		if isTerminalNode(s.n1) {
+			if !slices.ContainsFunc(s.n2s, isTerminalNode) {
+				return witnessString(visits, i), true
+			}
+			continue
+		}
+
+		// Partition the bytes accepted by n1 according to the n2 states that
+		// accept them, as bytes in the same partition lead to the same state.
+		partitions := make(map[string]*[4]uint64)
+		var order []string
+		var sig []byte
+		for b := 0; b < 256; b++ {
+			if !testMask(&s.n1.mask, byte(b)) {
+				continue
+			}
+			sig = sig[:0]
+			for j, n := range s.n2s {
+				if testMask(&n.mask, byte(b)) {
+					sig = strconv.AppendInt(sig, int64(j), 10)
+					sig = append(sig, ',')
+				}
+			}
+			mask, ok := partitions[string(sig)]
+			if !ok {
+				mask = &[4]uint64{}
+				partitions[string(sig)] = mask
+				order = append(order, string(sig))
+			}
+			setMask(mask, byte(b))
+		}
+
+		for _, sig := range order {
+			mask := partitions[sig]
+			b, _ := witnessByte(mask)
+			var next []*node
+			for _, n := range s.n2s {
+				if testMask(&n.mask, b) {
+					next = append(next, n.next)
+				}
+			}
+			nextN2s, nextN2sKey := closure(next)
+			add(s.n1.next, nextN2s, nextN2sKey, witnessVisit{parent: i, hasByte: true, b: b})
+		}
+	}
+
+	return "", false
+}
+
+// witnessByte picks a byte from a mask, preferring bytes that make for a
+// readable example URL.
+func witnessByte(mask *[4]uint64) (byte, bool) {
+	const preferred = "abcdefghijklmnopqrstuvwxyz0123456789-_.~/"
+	for i := 0; i < len(preferred); i++ {
+		if testMask(mask, preferred[i]) {
+			return preferred[i], true
+		}
+	}
+	for b := 0x21; b < 0x7f; b++ {
+		if testMask(mask, byte(b)) {
+			return byte(b), true
+		}
+	}
+	for b := 0; b < 256; b++ {
+		if testMask(mask, byte(b)) {
+			return byte(b), true
+		}
+	}
+	return 0, false
+}
+
 type iterState int
 
 const (
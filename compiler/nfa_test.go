@@ -72,6 +72,13 @@ func TestNfa(t *testing.T) {
 	testNfa(t, "[0-9][0-9][0-9]", "2344", false)
 	testNfa(t, "[0-9][0-9][0-9]", "24", false)
 	testNfa(t, "[0-9][0-9][0-9]", "aaa", false)
+	testNfa(t, "[a-f0-9]+", "c0ffee", true)
+	testNfa(t, "[a-f0-9]+", "coffee", false)
+	testNfa(t, "[^a-z]", "q", false)
+	testNfa(t, "[^a-z]", "Q", true)
+	testNfa(t, "[a-]", "-", true)
+	testNfa(t, "[\\]-a]", "]", true)
+	testNfa(t, "[\\]-a]", "_", true)
 }
 
 func TestNfaOverlap(t *testing.T) {
@@ -99,6 +106,29 @@ func TestNfaOverlap(t *testing.T) {
 	testNfaOverlap(t, `x+a`, `x+a`, true)
 }
 
+func TestNfaOverlapWitness(t *testing.T) {
+	testNfaOverlapWitness(t, "a", ".", "a")
+	testNfaOverlapWitness(t, "(a|b)*", "", "")
+	testNfaOverlapWitness(t, "(ab|ccc|d|e)*", "abdecccccc", "abdecccccc")
+	testNfaOverlapWitness(t, `/foo/[^/]+`, `/[^/]+/bar`, "/foo/bar")
+	testNfaOverlapWitness(t, `/x/.*`, `/[^/]+/[0-9]+`, "/x/0")
+	testNfaOverlapWitness(t, `/[XY]`, `/[^abc]`, "/X")
+}
+
+func TestNfaDifference(t *testing.T) {
+	testNfaDifference(t, "a", ".", false, "")
+	testNfaDifference(t, ".", "a", true, "b")
+	testNfaDifference(t, "(a|b)*", "a*", true, "b")
+	testNfaDifference(t, "a*", "(a|b)*", false, "")
+	testNfaDifference(t, "a*", "a+", true, "")
+	testNfaDifference(t, `\/+foo\/+[^\/?#]+\/*`, `\/+foo\/+([^\/?#]+)\/*`, false, "")
+	testNfaDifference(t, `\/+foo\/+[^\/?#]+\/*`, `\/+foo\/+(-?[0-9]+)\/*`, true, "/foo/a")
+	testNfaDifference(t, `\/+foo\/+(-?[0-9]+)\/*`, `\/+foo\/+[^\/?#]+\/*`, false, "")
+	testNfaDifference(t, `\/+foo\/*`, `\/+foo`, true, "/foo/")
+	testNfaDifference(t, `\/+foo\/*`, `(?:\/+foo|\/+foo\/+)`, false, "")
+	testNfaDifference(t, `\/+(?:a|b|c)\/*`, `(?:\/+a\/*|\/+c\/*)`, true, "/b")
+}
+
 func TestFindFirstOverlap(t *testing.T) {
 	testFindFirstOverlap(t, "simple", true, []string{"a", "b", "."})
 	testFindFirstOverlap(t, "bigger", true, []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l", "m", "."})
@@ -179,6 +209,35 @@ func testNfaOverlap(t *testing.T, regexp1 string, regexp2 string, shouldOverlap
 		t.Errorf("Expecting no overlap – got overlap: %v should not overlap with %v\n", regexp1, regexp2)
 		return
 	}
+	witness, ok := overlapWitness(startNode1, startNode2)
+	if ok != overlaps {
+		t.Errorf("overlapWitness disagrees with overlap for %v and %v\n", regexp1, regexp2)
+		return
+	}
+	if ok && (!run(startNode1, witness) || !run(startNode2, witness)) {
+		t.Errorf("Witness %q is not matched by both %v and %v\n", witness, regexp1, regexp2)
+	}
+}
+
+func testNfaOverlapWitness(t *testing.T, regexp1 string, regexp2 string, expected string) {
+	startNode1, err := regexpToNfa(regexp1)
+	if err != nil {
+		t.Errorf("Couldn't compile regexp 1: %v\n", err)
+		return
+	}
+	startNode2, err := regexpToNfa(regexp2)
+	if err != nil {
+		t.Errorf("Couldn't compile regexp 2: %v\n", err)
+		return
+	}
+	witness, ok := overlapWitness(startNode1, startNode2)
+	if !ok {
+		t.Errorf("Expecting witness %q for %v and %v, got none\n", expected, regexp1, regexp2)
+		return
+	}
+	if witness != expected {
+		t.Errorf("Expecting witness %q for %v and %v, got %q\n", expected, regexp1, regexp2, witness)
+	}
 }
 
 func testFindFirstOverlap(t *testing.T, desc string, overlapExists bool, regexps []string) {
@@ -258,3 +317,24 @@ func overlappingNeedleInHaystack(n int) []string {
 
 	return regexps
 }
+
+func testNfaDifference(t *testing.T, regexp1 string, regexp2 string, expectDifference bool, expectedWitness string) {
+	startNode1, err := regexpToNfa(regexp1)
+	if err != nil {
+		t.Errorf("Couldn't compile regexp 1: %v\n", err)
+		return
+	}
+	startNode2, err := regexpToNfa(regexp2)
+	if err != nil {
+		t.Errorf("Couldn't compile regexp 2: %v\n", err)
+		return
+	}
+	witness, ok := difference(startNode1, startNode2)
+	if ok != expectDifference {
+		t.Errorf("Expecting difference %v for %v and %v, got %v\n", expectDifference, regexp1, regexp2, ok)
+		return
+	}
+	if witness != expectedWitness {
+		t.Errorf("Expecting witness %q for %v and %v, got %q\n", expectedWitness, regexp1, regexp2, witness)
+	}
+}
@@ -0,0 +1,172 @@
+package compiler
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"strings"
+)
+
+type openAPIDocument struct {
+	OpenAPI string                     `json:"openapi"`
+	Info    openAPIInfo                `json:"info"`
+	Paths   map[string]openAPIPathItem `json:"paths"`
+}
+
+type openAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// An openAPIPathItem maps lower case method names to operations.
+type openAPIPathItem map[string]*openAPIOperation
+
+type openAPIOperation struct {
+	OperationID string                     `json:"operationId"`
+	Tags        []string                   `json:"tags,omitempty"`
+	Parameters  []openAPIParameter         `json:"parameters,omitempty"`
+	Responses   map[string]openAPIResponse `json:"responses"`
+}
+
+type openAPIParameter struct {
+	Name     string        `json:"name"`
+	In       string        `json:"in"`
+	Required bool          `json:"required"`
+	Schema   openAPISchema `json:"schema"`
+}
+
+type openAPISchema struct {
+	Type   string   `json:"type"`
+	Format string   `json:"format,omitempty"`
+	Enum   []string `json:"enum,omitempty"`
+}
+
+type openAPIResponse struct {
+	Description string `json:"description"`
+}
+
+var openAPIMethods = map[string]struct{}{
+	"GET": {}, "PUT": {}, "POST": {}, "DELETE": {}, "OPTIONS": {}, "HEAD": {}, "PATCH": {}, "TRACE": {},
+}
+
+// RouteRegexpsToOpenAPI generates an OpenAPI 3 document whose paths are the
+// routes included in the output. Named parameters become path parameters, the
+// route name becomes the operationId and the tags of the route are carried
+// over. A route with several methods, or several routes with the same name,
+// give rise to several operations; in this case each operationId is the route
+// name followed by '_' and the method (and by a number if this is still not
+// unique). Routes containing rest parameters or wildcards, and methods that
+// OpenAPI doesn't support, are omitted and reported as warnings.
+func RouteRegexpsToOpenAPI(rrs *routeRegexps, filter *TagExpr) ([]byte, []RouteError) {
+	routes := getOutputRoutes(rrs, filter)
+
+	var warnings []RouteError
+	nOperations := make(map[string]int)
+	var included []*outputRoute
+	for i := range routes {
+		r := &routes[i]
+		if kind, ok := openAPIUnsupportedElement(r); ok {
+			warnings = append(warnings, RouteError{Kind: kind, Line: r.line, Col: -1, Filenames: []string{r.filename}})
+			continue
+		}
+		for _, m := range r.methods {
+			if _, ok := openAPIMethods[m]; !ok {
+				warnings = append(warnings, RouteError{Kind: WarningMethodNotInOpenAPI, Line: r.line, Col: -1, Filenames: []string{r.filename}, Method: m})
+				continue
+			}
+			nOperations[r.name]++
+		}
+		included = append(included, r)
+	}
+
+	doc := openAPIDocument{
+		OpenAPI: "3.0.3",
+		Info:    openAPIInfo{Title: "Routes", Version: "1"},
+		Paths:   make(map[string]openAPIPathItem),
+	}
+	operationIDs := newIdentAllocator()
+	for _, r := range included {
+		// OpenAPI has no optional path parameters or alternations, so a route
+		// with optional groups or alternations has a path for each combination
+		// of groups and alternatives.
+		var templates [][]routeElement
+		for _, t := range expandAlternations(r.template) {
+			templates = append(templates, expandOptionalGroups(t)...)
+		}
+		for _, template := range templates {
+			path := openAPIPath(template)
+			var params []openAPIParameter
+			for _, p := range r.params {
+				if !slices.ContainsFunc(template, func(e routeElement) bool { return e.value == p.name && e.kind == p.kind }) {
+					continue
+				}
+				typ := "string"
+				if p.kind == integerParameter {
+					typ = "integer"
+				}
+				var format string
+				if p.paramType == "uuid" || p.paramType == "date" {
+					format = p.paramType
+				}
+				params = append(params, openAPIParameter{Name: p.name, In: "path", Required: true, Schema: openAPISchema{typ, format, p.values}})
+			}
+
+			for _, m := range r.methods {
+				if _, ok := openAPIMethods[m]; !ok {
+					continue
+				}
+				item := doc.Paths[path]
+				if item == nil {
+					item = make(openAPIPathItem)
+					doc.Paths[path] = item
+				}
+				id := r.name
+				if nOperations[r.name] > 1 {
+					id += "_" + m
+				}
+				item[strings.ToLower(m)] = &openAPIOperation{
+					OperationID: operationIDs.alloc(id),
+					Tags:        r.tags,
+					Parameters:  params,
+					Responses:   map[string]openAPIResponse{"default": {Description: "Response"}},
+				}
+			}
+		}
+	}
+
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		panic(err)
+	}
+	return out.Bytes(), warnings
+}
+
+func openAPIUnsupportedElement(r *outputRoute) (RouteErrorKind, bool) {
+	for _, e := range r.template {
+		switch e.kind {
+		case restParameter:
+			return WarningRestParameterInOpenAPI, true
+		case singleGlob, doubleGlob:
+			return WarningGlobInOpenAPI, true
+		}
+	}
+	return 0, false
+}
+
+func openAPIPath(template []routeElement) string {
+	var sb strings.Builder
+	for _, e := range template {
+		switch e.kind {
+		case slash:
+			sb.WriteByte('/')
+		case constant:
+			sb.WriteString(e.value)
+		case parameter, integerParameter:
+			sb.WriteString("{" + e.value + "}")
+		}
+	}
+	return sb.String()
+}
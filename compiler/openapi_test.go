@@ -0,0 +1,157 @@
+package compiler
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRouteRegexpsToOpenAPI(t *testing.T) {
+	const routeFile = `
+managers /managers/ [manager]
+  .
+  user               /:manager_id/user/:#user_id
+  files              /:manager_id/files/:**path
+  glob               /glob/*
+things [GET,POST,PROPFIND] /things/:#id
+docs /docs/:{lang in en,fr}
+item /items/:uuid:id
+`
+
+	entries, errs := ParseRouteFile(strings.NewReader(routeFile), DisallowUpperCase)
+	if len(errs) > 0 {
+		t.Fatalf("%+v\n", errs)
+	}
+	routes, errs := ProcessRouteFiles([][]RouteFileEntry{entries}, []string{"routes"}, "/")
+	if len(errs) > 0 {
+		t.Fatalf("%+v\n", errs)
+	}
+	rrs := GetRouteRegexps(routes, nil)
+	out, warnings := RouteRegexpsToOpenAPI(&rrs, nil)
+
+	var doc openAPIDocument
+	if err := json.Unmarshal(out, &doc); err != nil {
+		t.Fatalf("%v\n%s\n", err, out)
+	}
+
+	if doc.OpenAPI != "3.0.3" {
+		t.Errorf("Unexpected OpenAPI version %v\n", doc.OpenAPI)
+	}
+
+	paths := stringSetToList(doc.Paths)
+	expectedPaths := []string{"/docs/{lang}", "/items/{id}", "/managers/", "/managers/{manager_id}/user/{user_id}", "/things/{id}"}
+	if !reflect.DeepEqual(paths, expectedPaths) {
+		t.Errorf("Expected paths %+v, got %+v\n", expectedPaths, paths)
+	}
+
+	user := doc.Paths["/managers/{manager_id}/user/{user_id}"]["get"]
+	expectedUser := &openAPIOperation{
+		OperationID: "managers/user",
+		Tags:        []string{"manager"},
+		Parameters: []openAPIParameter{
+			{Name: "manager_id", In: "path", Required: true, Schema: openAPISchema{Type: "string"}},
+			{Name: "user_id", In: "path", Required: true, Schema: openAPISchema{Type: "integer"}},
+		},
+		Responses: map[string]openAPIResponse{"default": {Description: "Response"}},
+	}
+	if !reflect.DeepEqual(user, expectedUser) {
+		t.Errorf("Expected operation %+v, got %+v\n", expectedUser, user)
+	}
+
+	things := doc.Paths["/things/{id}"]
+	if len(things) != 2 || things["get"].OperationID != "things_GET" || things["post"].OperationID != "things_POST" {
+		t.Errorf("Unexpected operations for /things/{id}: %+v\n", things)
+	}
+
+	docs := doc.Paths["/docs/{lang}"]["get"]
+	expectedDocsParams := []openAPIParameter{{Name: "lang", In: "path", Required: true, Schema: openAPISchema{Type: "string", Enum: []string{"en", "fr"}}}}
+	if docs == nil || !reflect.DeepEqual(docs.Parameters, expectedDocsParams) {
+		t.Errorf("Expected parameters %+v for /docs/{lang}, got %+v\n", expectedDocsParams, docs)
+	}
+
+	item := doc.Paths["/items/{id}"]["get"]
+	if item == nil || len(item.Parameters) != 1 || !reflect.DeepEqual(item.Parameters[0].Schema, openAPISchema{Type: "string", Format: "uuid"}) {
+		t.Errorf("Expected a uuid parameter for /items/{id}, got %+v\n", item)
+	}
+
+	var warningKinds []RouteErrorKind
+	for _, w := range warnings {
+		warningKinds = append(warningKinds, w.Kind)
+		if w.Kind&RouteWarning == 0 {
+			t.Errorf("Expected %v to be a warning\n", w)
+		}
+	}
+	expectedKinds := []RouteErrorKind{WarningRestParameterInOpenAPI, WarningGlobInOpenAPI, WarningMethodNotInOpenAPI}
+	if !reflect.DeepEqual(warningKinds, expectedKinds) {
+		t.Errorf("Expected warnings %+v, got %+v\n", expectedKinds, warnings)
+	}
+	if len(warnings) == 3 && warnings[2].Error() != "routes:7: method PROPFIND is not supported by OpenAPI; omitted from OpenAPI output" {
+		t.Errorf("Unexpected warning message %v\n", warnings[2])
+	}
+}
+
+func TestRouteRegexpsToOpenAPIOptionalGroups(t *testing.T) {
+	const routeFile = `
+search /search[/:q[/:#page]]
+`
+
+	entries, errs := ParseRouteFile(strings.NewReader(routeFile), DisallowUpperCase)
+	if len(errs) > 0 {
+		t.Fatalf("%+v\n", errs)
+	}
+	routes, errs := ProcessRouteFiles([][]RouteFileEntry{entries}, []string{"routes"}, "/")
+	if len(errs) > 0 {
+		t.Fatalf("%+v\n", errs)
+	}
+	rrs := GetRouteRegexps(routes, nil)
+	out, _ := RouteRegexpsToOpenAPI(&rrs, nil)
+
+	var doc openAPIDocument
+	if err := json.Unmarshal(out, &doc); err != nil {
+		t.Fatalf("%v\n%s\n", err, out)
+	}
+
+	paths := stringSetToList(doc.Paths)
+	expectedPaths := []string{"/search", "/search/{q}", "/search/{q}/{page}"}
+	if !reflect.DeepEqual(paths, expectedPaths) {
+		t.Errorf("Expected paths %+v, got %+v\n", expectedPaths, paths)
+	}
+	if op := doc.Paths["/search/{q}"]["get"]; op == nil || len(op.Parameters) != 1 || op.Parameters[0].Name != "q" {
+		t.Errorf("Expected only the q parameter for /search/{q}, got %+v\n", op)
+	}
+	if op := doc.Paths["/search"]["get"]; op == nil || len(op.Parameters) != 0 {
+		t.Errorf("Expected no parameters for /search, got %+v\n", op)
+	}
+}
+
+func TestRouteRegexpsToOpenAPIAlternations(t *testing.T) {
+	const routeFile = `
+photo /(photos|images)/:id
+`
+
+	entries, errs := ParseRouteFile(strings.NewReader(routeFile), DisallowUpperCase)
+	if len(errs) > 0 {
+		t.Fatalf("%+v\n", errs)
+	}
+	routes, errs := ProcessRouteFiles([][]RouteFileEntry{entries}, []string{"routes"}, "/")
+	if len(errs) > 0 {
+		t.Fatalf("%+v\n", errs)
+	}
+	rrs := GetRouteRegexps(routes, nil)
+	out, _ := RouteRegexpsToOpenAPI(&rrs, nil)
+
+	var doc openAPIDocument
+	if err := json.Unmarshal(out, &doc); err != nil {
+		t.Fatalf("%v\n%s\n", err, out)
+	}
+
+	paths := stringSetToList(doc.Paths)
+	expectedPaths := []string{"/images/{id}", "/photos/{id}"}
+	if !reflect.DeepEqual(paths, expectedPaths) {
+		t.Errorf("Expected paths %+v, got %+v\n", expectedPaths, paths)
+	}
+	if op := doc.Paths["/photos/{id}"]["get"]; op == nil || op.OperationID != "photo" {
+		t.Errorf("Expected the path of the first alternative to have operation ID 'photo', got %+v\n", op)
+	}
+}
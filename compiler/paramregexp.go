@@ -0,0 +1,214 @@
+package compiler
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// A paramType is a built-in parameter type, written ':type:name'.
+type paramType struct {
+	// A regexp in the subset of regexp syntax accepted by translateParamRegexp,
+	// without capturing groups.
+	regexp string
+}
+
+var paramTypes = map[string]paramType{
+	"uuid": {hexDigits(8) + "-" + hexDigits(4) + "-" + hexDigits(4) + "-" + hexDigits(4) + "-" + hexDigits(12)},
+	"slug": {"[a-z0-9]+(?:-[a-z0-9]+)*"},
+	"date": {"[0-9][0-9][0-9][0-9]-(?:0[1-9]|1[0-2])-(?:0[1-9]|[12][0-9]|3[01])"},
+	"hex":  {"[0-9a-fA-F]+"},
+}
+
+func hexDigits(n int) string {
+	return strings.Repeat("[0-9a-fA-F]", n)
+}
+
+// paramTypePrefix returns the type of a parameter written ':type:name' or
+// ':type:{name}', given the part of the route following the initial ':'.
+func paramTypePrefix(route string) string {
+	typ, rest, ok := strings.Cut(route, ":")
+	if !ok || rest == "" {
+		return ""
+	}
+	if _, ok := paramTypes[typ]; !ok {
+		return ""
+	}
+	r, _ := utf8.DecodeRuneInString(rest)
+	if r != '{' && r != '_' && !unicode.IsLetter(r) && !unicode.IsNumber(r) {
+		return ""
+	}
+	return typ
+}
+
+// translateParamRegexp checks that the regexp of a parameter written as
+// ':{name ~ regexp}' uses only the subset of regexp syntax that regexpToNfa
+// understands, so that overlap detection remains sound. The subset consists of
+// ASCII literals, backslash escapes of ASCII punctuation, '.', character
+// classes, groups, '|' and the '*', '+' and '?' quantifiers. The returned
+// regexp is equivalent except that '.' and negated character classes don't
+// match '/', '?' or '#', and all groups are non-capturing.
+func translateParamRegexp(re string) (string, RouteErrorKind, bool) {
+	var sb strings.Builder
+	depth := 0
+	quantifiable := false
+	for i := 0; i < len(re); i++ {
+		c := re[i]
+		switch c {
+		case '\\':
+			if i+1 == len(re) || !isASCIIPunct(re[i+1]) {
+				return "", UnsupportedRegexpInParameter, false
+			}
+			i++
+			sb.WriteByte('\\')
+			sb.WriteByte(re[i])
+			quantifiable = true
+		case '.':
+			sb.WriteString("[^\\/?#]")
+			quantifiable = true
+		case '[':
+			end, ok := translateCharClass(re, i, &sb)
+			if !ok {
+				return "", UnsupportedRegexpInParameter, false
+			}
+			i = end
+			quantifiable = true
+		case '(':
+			if i+1 < len(re) && re[i+1] == '?' {
+				if i+2 == len(re) || re[i+2] != ':' {
+					return "", UnsupportedRegexpInParameter, false
+				}
+				i += 2
+			}
+			sb.WriteString("(?:")
+			depth++
+			quantifiable = false
+		case ')':
+			if depth == 0 {
+				return "", UnsupportedRegexpInParameter, false
+			}
+			sb.WriteByte(')')
+			depth--
+			quantifiable = true
+		case '|':
+			sb.WriteByte('|')
+			quantifiable = false
+		case '*', '+', '?':
+			if !quantifiable {
+				return "", UnsupportedRegexpInParameter, false
+			}
+			sb.WriteByte(c)
+			if c != '?' && i+1 < len(re) && re[i+1] == '?' {
+				i++
+				sb.WriteByte('?')
+			}
+			quantifiable = false
+		case '^', '$', '{', '}':
+			return "", UnsupportedRegexpInParameter, false
+		default:
+			if c <= ' ' || c > '~' {
+				return "", UnsupportedRegexpInParameter, false
+			}
+			sb.WriteByte(c)
+			quantifiable = true
+		}
+	}
+	if depth != 0 {
+		return "", UnsupportedRegexpInParameter, false
+	}
+
+	translated := sb.String()
+	if _, err := regexp.Compile(translated); err != nil {
+		return "", UnsupportedRegexpInParameter, false
+	}
+	n, err := regexpToNfa("(?:" + translated + ")")
+	if err != nil {
+		return "", UnsupportedRegexpInParameter, false
+	}
+	if run(n, "") || nfaHasTransitionOn(n, "/?#") {
+		return "", BadRegexpInParameter, false
+	}
+
+	return translated, 0, true
+}
+
+// translateCharClass writes the character class starting at re[start] to sb.
+// Characters that are special outside character classes are escaped so that
+// parseRegexp does not mistake them for group delimiters. It returns the index
+// of the closing ']'.
+func translateCharClass(re string, start int, sb *strings.Builder) (int, bool) {
+	i := start + 1
+	sb.WriteByte('[')
+	negated := i < len(re) && re[i] == '^'
+	if negated {
+		sb.WriteByte('^')
+		i++
+	}
+	contentStart := i
+	for ; i < len(re) && re[i] != ']'; i++ {
+		c := re[i]
+		switch {
+		case c == '\\':
+			if i+1 == len(re) || !isASCIIPunct(re[i+1]) {
+				return i, false
+			}
+			i++
+			sb.WriteByte('\\')
+			sb.WriteByte(re[i])
+		case c == '[' || c <= ' ' || c > '~':
+			// '[' is excluded because of Go's '[:alpha:]' syntax.
+			return i, false
+		case c == '-':
+			sb.WriteByte('-')
+		case isASCIIPunct(c):
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	if i == len(re) || i == contentStart {
+		return i, false
+	}
+	if negated {
+		sb.WriteString("\\/?#")
+	}
+	sb.WriteByte(']')
+	return i, true
+}
+
+func isASCIIPunct(c byte) bool {
+	return (c >= '!' && c <= '/') || (c >= ':' && c <= '@') || (c >= '[' && c <= '`') || (c >= '{' && c <= '~')
+}
+
+func nfaHasTransitionOn(n *node, chars string) bool {
+	seen := make(map[*node]struct{})
+	var rec func(n *node) bool
+	rec = func(n *node) bool {
+		if n == nil {
+			return false
+		}
+		if _, ok := seen[n]; ok {
+			return false
+		}
+		seen[n] = struct{}{}
+		if n.next != nil {
+			for i := 0; i < len(chars); i++ {
+				if testMask(&n.mask, chars[i]) {
+					return true
+				}
+			}
+		}
+		if rec(n.next) {
+			return true
+		}
+		for _, e := range n.epsilons {
+			if rec(e) {
+				return true
+			}
+		}
+		return false
+	}
+	return rec(n)
+}
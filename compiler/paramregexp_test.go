@@ -0,0 +1,58 @@
+package compiler
+
+import "testing"
+
+func TestTranslateParamRegexp(t *testing.T) {
+	testTranslateParamRegexp(t, "[0-9a-f]+", "[0-9a-f]+", 0)
+	testTranslateParamRegexp(t, "[a-z0-9]+(-[a-z0-9]+)*", "[a-z0-9]+(?:-[a-z0-9]+)*", 0)
+	testTranslateParamRegexp(t, "(?:v1|v2)", "(?:v1|v2)", 0)
+	testTranslateParamRegexp(t, "x.+", "x[^\\/?#]+", 0)
+	testTranslateParamRegexp(t, "[^-]+", "[^-\\/?#]+", 0)
+	testTranslateParamRegexp(t, "[().]x", "[\\(\\)\\.]x", 0)
+	testTranslateParamRegexp(t, "a\\.b", "a\\.b", 0)
+	testTranslateParamRegexp(t, "a+?b", "a+?b", 0)
+
+	testTranslateParamRegexp(t, "\\d+", "", UnsupportedRegexpInParameter)
+	testTranslateParamRegexp(t, "[0-9]{4}", "", UnsupportedRegexpInParameter)
+	testTranslateParamRegexp(t, "^a", "", UnsupportedRegexpInParameter)
+	testTranslateParamRegexp(t, "a$", "", UnsupportedRegexpInParameter)
+	testTranslateParamRegexp(t, "(?i)a", "", UnsupportedRegexpInParameter)
+	testTranslateParamRegexp(t, "(a", "", UnsupportedRegexpInParameter)
+	testTranslateParamRegexp(t, "a)", "", UnsupportedRegexpInParameter)
+	testTranslateParamRegexp(t, "*a", "", UnsupportedRegexpInParameter)
+	testTranslateParamRegexp(t, "a**", "", UnsupportedRegexpInParameter)
+	testTranslateParamRegexp(t, "a??", "", UnsupportedRegexpInParameter)
+	testTranslateParamRegexp(t, "[]", "", UnsupportedRegexpInParameter)
+	testTranslateParamRegexp(t, "[[:alpha:]]", "", UnsupportedRegexpInParameter)
+	testTranslateParamRegexp(t, "[z-a]", "", UnsupportedRegexpInParameter)
+	testTranslateParamRegexp(t, "é", "", UnsupportedRegexpInParameter)
+
+	testTranslateParamRegexp(t, "a*", "", BadRegexpInParameter)
+	testTranslateParamRegexp(t, "(a|)", "", BadRegexpInParameter)
+	testTranslateParamRegexp(t, "a/b", "", BadRegexpInParameter)
+	testTranslateParamRegexp(t, "a\\?", "", BadRegexpInParameter)
+	testTranslateParamRegexp(t, "[!-~]+", "", BadRegexpInParameter)
+}
+
+func testTranslateParamRegexp(t *testing.T, re, expected string, expectedErr RouteErrorKind) {
+	t.Helper()
+	translated, errKind, ok := translateParamRegexp(re)
+	if expectedErr != 0 {
+		if ok || errKind != expectedErr {
+			t.Errorf("Expected error %v for %q, got %q %v %v\n", RouteError{Kind: expectedErr}, re, translated, errKind, ok)
+		}
+		return
+	}
+	if !ok || translated != expected {
+		t.Errorf("Expected %q to translate to %q, got %q %v\n", re, expected, translated, ok)
+	}
+}
+
+func TestParamTypeRegexps(t *testing.T) {
+	for name, typ := range paramTypes {
+		translated, _, ok := translateParamRegexp(typ.regexp)
+		if !ok || translated != typ.regexp {
+			t.Errorf("Regexp for param type %v is not in the subset accepted by translateParamRegexp\n", name)
+		}
+	}
+}
@@ -2,6 +2,8 @@ package compiler
 
 import (
 	"io"
+	"slices"
+	"strconv"
 	"strings"
 
 	j "github.com/addrummond/jsonstream"
@@ -14,12 +16,18 @@ const (
 	jpsSeekingEntry
 	jpsInEntry
 	jpsInTags
+	jpsInMeta
 	jpsInMethods
+	jpsInHosts
 	jpsInStringPattern
 	jpsInArrayPattern
 	jpsInPatternArrayElement
 	jpsInPatternArrayElementNoArg
 	jpsInPatternArrayElementParam
+	jpsInPatternArrayElementParamValues
+	jpsInPatternArrayElementRegexpParam
+	jpsInPatternArrayElementRegexp
+	jpsInPatternArrayElementAlternatives
 )
 
 func appendRouteErr(errors []RouteError, kind RouteErrorKind, line, col int) []RouteError {
@@ -40,6 +48,8 @@ func ParseJsonRouteFile(input io.Reader, casePolicy CasePolicy) (entries []Route
 	s := jpsInitial
 	currentEntry := RouteFileEntry{}
 	currentIndent := 0
+	mount := false                      // whether currentEntry mounts a file under the route
+	var currentTags map[string]struct{} // the set of tags being read by jpsInTags
 	var complexPatternElementStartToken j.Token
 
 	var parser j.Parser
@@ -76,6 +86,7 @@ func ParseJsonRouteFile(input io.Reader, casePolicy CasePolicy) (entries []Route
 			case j.ObjectStart:
 				s = jpsInEntry
 				currentEntry = RouteFileEntry{line: t.Line}
+				mount = false
 				currentEntry.indent = currentIndent
 			case j.ArrayStart:
 				currentIndent++
@@ -94,6 +105,9 @@ func ParseJsonRouteFile(input io.Reader, casePolicy CasePolicy) (entries []Route
 			case j.String:
 				if k == "name" {
 					currentEntry.name = t.AsString()
+				} else if k == "include" || k == "mount" {
+					currentEntry.include = t.AsString()
+					mount = k == "mount"
 				} else if k == "pattern" {
 					elems := parseRoute(t.AsString())
 					errkinds := validateRouteElems(0, currentEntry.indent, elems)
@@ -112,17 +126,56 @@ func ParseJsonRouteFile(input io.Reader, casePolicy CasePolicy) (entries []Route
 					errors = appendRouteErr(errors, UnexpectedKeyInJSONRouteFile, t.Line, t.Col)
 					return
 				}
+			case j.Number:
+				if k != "priority" {
+					errors = appendRouteErr(errors, UnexpectedKeyInJSONRouteFile, t.Line, t.Col)
+					return
+				}
+				p, err := strconv.Atoi(string(t.Value))
+				if err != nil {
+					errors = appendRouteErr(errors, BadPriority, t.Line, t.Col)
+					return
+				}
+				currentEntry.priority = p
 			case j.True, j.False:
 				if k != "terminal" {
 					errors = appendRouteErr(errors, UnexpectedKeyInJSONRouteFile, t.Line, t.Col)
 					return
 				}
 				currentEntry.terminal = t.Kind == j.True
+			case j.ObjectStart:
+				if k != "meta" {
+					errors = appendRouteErr(errors, UnexpectedKeyInJSONRouteFile, t.Line, t.Col)
+					return
+				}
+				currentEntry.meta = make(map[string]string)
+				s = jpsInMeta
 			case j.ArrayStart:
 				if k == "tags" {
+					if currentEntry.tags == nil {
+						currentEntry.tags = make(map[string]struct{})
+					}
+					currentTags = currentEntry.tags
+					s = jpsInTags
+				} else if k == "localTags" {
+					if currentEntry.localTags == nil {
+						currentEntry.localTags = make(map[string]struct{})
+					}
+					currentTags = currentEntry.localTags
+					s = jpsInTags
+				} else if k == "removedTags" {
+					if currentEntry.removedTags == nil {
+						currentEntry.removedTags = make(map[string]struct{})
+					}
+					currentTags = currentEntry.removedTags
 					s = jpsInTags
 				} else if k == "methods" {
+					if currentEntry.methods == nil {
+						currentEntry.methods = make(map[string]struct{})
+					}
 					s = jpsInMethods
+				} else if k == "hosts" {
+					s = jpsInHosts
 				} else if k == "pattern" {
 					s = jpsInArrayPattern
 				} else {
@@ -130,6 +183,15 @@ func ParseJsonRouteFile(input io.Reader, casePolicy CasePolicy) (entries []Route
 				}
 			case j.ObjectEnd:
 				s = jpsSeekingEntry
+				if currentEntry.include != "" && !mount {
+					// An include directive has no other fields
+					if currentEntry.name != "" || currentEntry.pattern != nil || currentEntry.tags != nil || currentEntry.localTags != nil || currentEntry.removedTags != nil || currentEntry.meta != nil || currentEntry.methods != nil || currentEntry.terminal || currentEntry.priority != 0 || currentEntry.hosts != nil {
+						errors = appendRouteErr(errors, UnexpectedKeyInJSONRouteFile, currentEntry.line, -1)
+						return
+					}
+					entries = append(entries, currentEntry)
+					continue
+				}
 				if currentEntry.name == "" {
 					errors = appendRouteErr(errors, JSONRouteMissingNameField, t.Line, t.Col)
 					return
@@ -140,10 +202,11 @@ func ParseJsonRouteFile(input io.Reader, casePolicy CasePolicy) (entries []Route
 				}
 				if currentEntry.tags == nil {
 					currentEntry.tags = make(map[string]struct{})
-					currentEntry.methods = map[string]struct{}{"GET": {}} // TODO is this duplicating logic elsewhere?
 				}
+				// As in ParseRouteFile, a route without a list of methods accepts
+				// only GET requests, and an empty list is an error.
 				if currentEntry.methods == nil {
-					currentEntry.methods = make(map[string]struct{})
+					currentEntry.methods = map[string]struct{}{"GET": {}}
 				}
 				entries = append(entries, currentEntry)
 			default:
@@ -153,17 +216,51 @@ func ParseJsonRouteFile(input io.Reader, casePolicy CasePolicy) (entries []Route
 		case jpsInTags:
 			switch t.Kind {
 			case j.String:
-				currentEntry.tags[t.AsString()] = struct{}{}
+				currentTags[t.AsString()] = struct{}{}
 			case j.ArrayEnd:
 				s = jpsInEntry
 			default:
 				errors = appendRouteErr(errors, UnexpectedTokenInJSONRouteFile, t.Line, t.Col)
 				return
 			}
+		case jpsInMeta:
+			switch t.Kind {
+			case j.String:
+				if len(t.Key) == 0 {
+					errors = appendRouteErr(errors, BadMetadata, t.Line, t.Col)
+					return
+				}
+				currentEntry.meta[string(t.Key)] = t.AsString()
+			case j.ObjectEnd:
+				s = jpsInEntry
+			default:
+				errors = appendRouteErr(errors, UnexpectedTokenInJSONRouteFile, t.Line, t.Col)
+				return
+			}
 		case jpsInMethods:
 			switch t.Kind {
 			case j.String:
 				currentEntry.methods[string(t.Value)] = struct{}{}
+			case j.ArrayEnd:
+				if len(currentEntry.methods) == 0 {
+					errors = appendRouteErr(errors, EmptyMethodList, t.Line, t.Col)
+				}
+				s = jpsInEntry
+			default:
+				errors = appendRouteErr(errors, UnexpectedTokenInJSONRouteFile, t.Line, t.Col)
+				return
+			}
+		case jpsInHosts:
+			switch t.Kind {
+			case j.String:
+				h := strings.ToLower(t.AsString())
+				if !validHostPattern(h) {
+					errors = appendRouteErr(errors, BadHost, t.Line, t.Col)
+					return
+				}
+				if !slices.Contains(currentEntry.hosts, h) {
+					currentEntry.hosts = append(currentEntry.hosts, h)
+				}
 			case j.ArrayEnd:
 				s = jpsInEntry
 			default:
@@ -175,9 +272,9 @@ func ParseJsonRouteFile(input io.Reader, casePolicy CasePolicy) (entries []Route
 			case j.String:
 				val := t.AsString()
 				if val == "/" {
-					currentEntry.pattern = append(currentEntry.pattern, routeElement{slash, "", t.Line, t.Col})
+					currentEntry.pattern = append(currentEntry.pattern, routeElement{kind: slash, line: t.Line, col: t.Col})
 				} else if val == "!/" {
-					currentEntry.pattern = append(currentEntry.pattern, routeElement{noTrailingSlash, "", t.Line, t.Col})
+					currentEntry.pattern = append(currentEntry.pattern, routeElement{kind: noTrailingSlash, line: t.Line, col: t.Col})
 				} else if strings.ContainsRune(val, '/') {
 					errors = appendRouteErr(errors, NoSlashInsideJSONRoutePatternElement, t.Line, t.Col)
 					return
@@ -187,7 +284,7 @@ func ParseJsonRouteFile(input io.Reader, casePolicy CasePolicy) (entries []Route
 							errors = append(errors, routeError(UpperCaseCharInRoute, t.Line, t.Col+lci))
 						}
 					}
-					currentEntry.pattern = append(currentEntry.pattern, routeElement{constant, val, t.Line, t.Col})
+					currentEntry.pattern = append(currentEntry.pattern, routeElement{kind: constant, value: val, line: t.Line, col: t.Col})
 				}
 			case j.ArrayStart:
 				complexPatternElementStartToken = t
@@ -212,19 +309,36 @@ func ParseJsonRouteFile(input io.Reader, casePolicy CasePolicy) (entries []Route
 			switch sval {
 			case "*":
 				s = jpsInPatternArrayElementNoArg
-				currentEntry.pattern = append(currentEntry.pattern, routeElement{singleGlob, "", complexPatternElementStartToken.Line, complexPatternElementStartToken.Col})
+				currentEntry.pattern = append(currentEntry.pattern, routeElement{kind: singleGlob, line: complexPatternElementStartToken.Line, col: complexPatternElementStartToken.Col})
 			case "**":
 				s = jpsInPatternArrayElementNoArg
-				currentEntry.pattern = append(currentEntry.pattern, routeElement{doubleGlob, "", complexPatternElementStartToken.Line, complexPatternElementStartToken.Col})
+				currentEntry.pattern = append(currentEntry.pattern, routeElement{kind: doubleGlob, line: complexPatternElementStartToken.Line, col: complexPatternElementStartToken.Col})
+			case "[":
+				s = jpsInPatternArrayElementNoArg
+				currentEntry.pattern = append(currentEntry.pattern, routeElement{kind: optionalStart, line: complexPatternElementStartToken.Line, col: complexPatternElementStartToken.Col})
+			case "]":
+				s = jpsInPatternArrayElementNoArg
+				currentEntry.pattern = append(currentEntry.pattern, routeElement{kind: optionalEnd, line: complexPatternElementStartToken.Line, col: complexPatternElementStartToken.Col})
+			case "|":
+				s = jpsInPatternArrayElementAlternatives
+				currentEntry.pattern = append(currentEntry.pattern, routeElement{kind: alternation, line: complexPatternElementStartToken.Line, col: complexPatternElementStartToken.Col})
 			case ":":
 				s = jpsInPatternArrayElementParam
-				currentEntry.pattern = append(currentEntry.pattern, routeElement{parameter, "", complexPatternElementStartToken.Line, complexPatternElementStartToken.Col})
+				currentEntry.pattern = append(currentEntry.pattern, routeElement{kind: parameter, line: complexPatternElementStartToken.Line, col: complexPatternElementStartToken.Col})
+			case ":~":
+				s = jpsInPatternArrayElementRegexpParam
+				currentEntry.pattern = append(currentEntry.pattern, routeElement{kind: parameter, line: complexPatternElementStartToken.Line, col: complexPatternElementStartToken.Col})
 			case ":**":
 				s = jpsInPatternArrayElementParam
-				currentEntry.pattern = append(currentEntry.pattern, routeElement{restParameter, "", complexPatternElementStartToken.Line, complexPatternElementStartToken.Col})
+				currentEntry.pattern = append(currentEntry.pattern, routeElement{kind: restParameter, line: complexPatternElementStartToken.Line, col: complexPatternElementStartToken.Col})
 			default:
-				errors = appendRouteErr(errors, BadFirstMemberOfJSONRouteFilePatternElement, t.Line, t.Col)
-				return
+				typ, ok := strings.CutPrefix(sval, ":")
+				if _, isType := paramTypes[typ]; !ok || !isType {
+					errors = appendRouteErr(errors, BadFirstMemberOfJSONRouteFilePatternElement, t.Line, t.Col)
+					return
+				}
+				s = jpsInPatternArrayElementParam
+				currentEntry.pattern = append(currentEntry.pattern, routeElement{kind: parameter, line: complexPatternElementStartToken.Line, col: complexPatternElementStartToken.Col, paramType: typ})
 			}
 		case jpsInPatternArrayElementNoArg:
 			if t.Kind != j.ArrayEnd {
@@ -238,7 +352,75 @@ func ParseJsonRouteFile(input io.Reader, casePolicy CasePolicy) (entries []Route
 				return
 			}
 			currentEntry.pattern[len(currentEntry.pattern)-1].value = t.AsString()
+			if e := &currentEntry.pattern[len(currentEntry.pattern)-1]; e.kind == parameter && e.paramType == "" {
+				s = jpsInPatternArrayElementParamValues
+			} else {
+				s = jpsInPatternArrayElementNoArg
+			}
+		case jpsInPatternArrayElementRegexpParam:
+			if t.Kind != j.String {
+				errors = appendRouteErr(errors, JSONRouteFilePatternElementParameterNameMustBeString, t.Line, t.Col)
+				return
+			}
+			currentEntry.pattern[len(currentEntry.pattern)-1].value = t.AsString()
+			s = jpsInPatternArrayElementRegexp
+		case jpsInPatternArrayElementRegexp:
+			if t.Kind != j.String {
+				errors = appendRouteErr(errors, UnsupportedRegexpInParameter, t.Line, t.Col)
+				return
+			}
+			re := t.AsString()
+			if _, errKind, ok := translateParamRegexp(re); !ok {
+				errors = appendRouteErr(errors, errKind, t.Line, t.Col)
+				return
+			}
+			if casePolicy == DisallowUpperCase && hasNonLowerCase(re) {
+				errors = append(errors, routeError(UpperCaseCharInRoute, t.Line, t.Col))
+			}
+			currentEntry.pattern[len(currentEntry.pattern)-1].regexp = re
 			s = jpsInPatternArrayElementNoArg
+		case jpsInPatternArrayElementParamValues:
+			switch t.Kind {
+			case j.String:
+				val := t.AsString()
+				if !validEnumeratedValue(val) {
+					errors = appendRouteErr(errors, BadEnumeratedParameter, t.Line, t.Col)
+					return
+				}
+				if casePolicy == DisallowUpperCase {
+					if lci := containsNonLowerCase(val); lci != -1 {
+						errors = append(errors, routeError(UpperCaseCharInRoute, t.Line, t.Col+lci))
+					}
+				}
+				e := &currentEntry.pattern[len(currentEntry.pattern)-1]
+				e.values = append(e.values, val)
+			case j.ArrayEnd:
+				s = jpsInArrayPattern
+			default:
+				errors = appendRouteErr(errors, UnexpectedJSONRouteFilePatternElementMember, t.Line, t.Col)
+				return
+			}
+		case jpsInPatternArrayElementAlternatives:
+			e := &currentEntry.pattern[len(currentEntry.pattern)-1]
+			switch t.Kind {
+			case j.String:
+				val := t.AsString()
+				if casePolicy == DisallowUpperCase {
+					if lci := containsNonLowerCase(val); lci != -1 {
+						errors = append(errors, routeError(UpperCaseCharInRoute, t.Line, t.Col+lci))
+					}
+				}
+				e.values = append(e.values, val)
+			case j.ArrayEnd:
+				if len(e.values) < 2 || !validAlternatives(e.values) {
+					errors = appendRouteErr(errors, BadAlternation, e.line, e.col)
+					return
+				}
+				s = jpsInArrayPattern
+			default:
+				errors = appendRouteErr(errors, UnexpectedJSONRouteFilePatternElementMember, t.Line, t.Col)
+				return
+			}
 		}
 	}
 
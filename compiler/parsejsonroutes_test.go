@@ -1,6 +1,7 @@
 package compiler
 
 import (
+	"reflect"
 	"strings"
 	"testing"
 
@@ -230,6 +231,162 @@ func TestParseJsonRouteVarious(t *testing.T) {
 		}
 	})
 
+	t.Run("Priority", func(t *testing.T) {
+		entries, errors := ParseJsonRouteFile(strings.NewReader(`[ {"name": "foo", "pattern": "/foo", "priority": 3}, {"name": "bar", "pattern": "/bar", "priority": -1} ]`), DisallowUpperCase)
+		if len(errors) != 0 || len(entries) != 2 || entries[0].priority != 3 || entries[1].priority != -1 {
+			t.Fatalf("Expected two entries with priorities 3 and -1, got %+v %+v\n", entries, errors)
+		}
+		_, errors = ParseJsonRouteFile(strings.NewReader(`[ {"name": "foo", "pattern": "/foo", "priority": 1.5} ]`), DisallowUpperCase)
+		if len(errors) != 1 || errors[0].Kind != BadPriority {
+			t.Fatalf("Expected a 'BadPriority' error, got %+v\n", errors)
+		}
+	})
+
+	t.Run("Enumerated parameter", func(t *testing.T) {
+		entries, errors := ParseJsonRouteFile(strings.NewReader(`[ {"name": "foo", "pattern": ["/", "docs", "/", [":", "lang", "en", "fr"]]} ]`), DisallowUpperCase)
+		if len(errors) != 0 || len(entries) != 1 || debugPrintParsedRoute(entries[0].pattern) != "/ 'docs' / ${lang in en,fr}" {
+			t.Fatalf("Expected one entry with an enumerated parameter, got %+v %+v\n", entries, errors)
+		}
+		_, errors = ParseJsonRouteFile(strings.NewReader(`[ {"name": "foo", "pattern": ["/", "docs", "/", [":", "lang", "en", "f/r"]]} ]`), DisallowUpperCase)
+		if len(errors) != 1 || errors[0].Kind != BadEnumeratedParameter {
+			t.Fatalf("Expected a 'BadEnumeratedParameter' error, got %+v\n", errors)
+		}
+		_, errors = ParseJsonRouteFile(strings.NewReader(`[ {"name": "foo", "pattern": ["/", [":", "lang", "en", "fr"], "/", [":", "lang"]]} ]`), DisallowUpperCase)
+		if len(errors) != 1 || errors[0].Kind != DuplicateParameterName {
+			t.Fatalf("Expected a 'DuplicateParameterName' error, got %+v\n", errors)
+		}
+	})
+
+	t.Run("Parameter with regexp", func(t *testing.T) {
+		entries, errors := ParseJsonRouteFile(strings.NewReader(`[ {"name": "foo", "pattern": ["/", "items", "/", [":~", "id", "[0-9a-f]+"]]} ]`), DisallowUpperCase)
+		if len(errors) != 0 || len(entries) != 1 || debugPrintParsedRoute(entries[0].pattern) != "/ 'items' / ${id ~ [0-9a-f]+}" {
+			t.Fatalf("Expected one entry with a regexp parameter, got %+v %+v\n", entries, errors)
+		}
+		_, errors = ParseJsonRouteFile(strings.NewReader(`[ {"name": "foo", "pattern": ["/", "items", "/", [":~", "id", "\\d+"]]} ]`), DisallowUpperCase)
+		if len(errors) != 1 || errors[0].Kind != UnsupportedRegexpInParameter {
+			t.Fatalf("Expected an 'UnsupportedRegexpInParameter' error, got %+v\n", errors)
+		}
+	})
+
+	t.Run("Parameter with a built-in type", func(t *testing.T) {
+		entries, errors := ParseJsonRouteFile(strings.NewReader(`[ {"name": "foo", "pattern": ["/", "items", "/", [":uuid", "id"]]} ]`), DisallowUpperCase)
+		if len(errors) != 0 || len(entries) != 1 || debugPrintParsedRoute(entries[0].pattern) != "/ 'items' / $uuid{id}" {
+			t.Fatalf("Expected one entry with a uuid parameter, got %+v %+v\n", entries, errors)
+		}
+		_, errors = ParseJsonRouteFile(strings.NewReader(`[ {"name": "foo", "pattern": ["/", "items", "/", [":nope", "id"]]} ]`), DisallowUpperCase)
+		if len(errors) != 1 || errors[0].Kind != BadFirstMemberOfJSONRouteFilePatternElement {
+			t.Fatalf("Expected a 'BadFirstMemberOfJSONRouteFilePatternElement' error, got %+v\n", errors)
+		}
+	})
+
+	t.Run("Optional group", func(t *testing.T) {
+		entries, errors := ParseJsonRouteFile(strings.NewReader(`[ {"name": "foo", "pattern": ["/", "posts", ["["], "/", "page", "/", [":", "n"], ["]"]]} ]`), DisallowUpperCase)
+		if len(errors) != 0 || len(entries) != 1 || debugPrintParsedRoute(entries[0].pattern) != "/ 'posts' [ / 'page' / ${n} ]" {
+			t.Fatalf("Expected one entry with an optional group, got %+v %+v\n", entries, errors)
+		}
+		_, errors = ParseJsonRouteFile(strings.NewReader(`[ {"name": "foo", "pattern": ["/", "posts", ["["], "/", "page"]} ]`), DisallowUpperCase)
+		if len(errors) != 1 || errors[0].Kind != BadOptionalGroup {
+			t.Fatalf("Expected a 'BadOptionalGroup' error, got %+v\n", errors)
+		}
+		_, errors = ParseJsonRouteFile(strings.NewReader(`[ {"name": "foo", "pattern": ["/", "posts", ["["], "/", [":", "n"], ["]"], "/", [":", "n"]]} ]`), DisallowUpperCase)
+		if len(errors) != 1 || errors[0].Kind != DuplicateParameterName {
+			t.Fatalf("Expected a 'DuplicateParameterName' error, got %+v\n", errors)
+		}
+	})
+
+	t.Run("Alternation", func(t *testing.T) {
+		entries, errors := ParseJsonRouteFile(strings.NewReader(`[ {"name": "foo", "pattern": ["/", ["|", "photos", "images"], "/", [":", "id"]]} ]`), DisallowUpperCase)
+		if len(errors) != 0 || len(entries) != 1 || debugPrintParsedRoute(entries[0].pattern) != "/ (photos|images) / ${id}" {
+			t.Fatalf("Expected one entry with an alternation, got %+v %+v\n", entries, errors)
+		}
+		for _, alternation := range []string{`["|", "photos"]`, `["|", "a", "a"]`, `["|", "a", ""]`} {
+			_, errors = ParseJsonRouteFile(strings.NewReader(`[ {"name": "foo", "pattern": ["/", `+alternation+`]} ]`), DisallowUpperCase)
+			if len(errors) != 1 || errors[0].Kind != BadAlternation {
+				t.Fatalf("Expected a 'BadAlternation' error for %v, got %+v\n", alternation, errors)
+			}
+		}
+	})
+
+	t.Run("Local and removed tags", func(t *testing.T) {
+		entries, errors := ParseJsonRouteFile(strings.NewReader(`[ {"name": "foo", "pattern": "/foo", "tags": ["api"], "localTags": ["public"], "removedTags": ["auth"]} ]`), DisallowUpperCase)
+		if len(errors) != 0 || len(entries) != 1 {
+			t.Fatalf("Expected one entry, got %+v %+v\n", entries, errors)
+		}
+		e := entries[0]
+		if !reflect.DeepEqual(e.tags, map[string]struct{}{"api": {}}) || !reflect.DeepEqual(e.localTags, map[string]struct{}{"public": {}}) || !reflect.DeepEqual(e.removedTags, map[string]struct{}{"auth": {}}) {
+			t.Fatalf("Unexpected tags %+v\n", e)
+		}
+	})
+
+	t.Run("Metadata", func(t *testing.T) {
+		entries, errors := ParseJsonRouteFile(strings.NewReader(`[ {"name": "foo", "pattern": "/foo", "meta": {"auth": "admin", "ratelimit": "100"}} ]`), DisallowUpperCase)
+		if len(errors) != 0 || len(entries) != 1 || !reflect.DeepEqual(entries[0].meta, map[string]string{"auth": "admin", "ratelimit": "100"}) {
+			t.Fatalf("Expected an entry with metadata, got %+v %+v\n", entries, errors)
+		}
+		for _, meta := range []string{`{"": "admin"}`, `{"ratelimit": 100}`} {
+			_, errors = ParseJsonRouteFile(strings.NewReader(`[ {"name": "foo", "pattern": "/foo", "meta": `+meta+`} ]`), DisallowUpperCase)
+			if len(errors) != 1 {
+				t.Fatalf("Expected an error for metadata %v, got %+v\n", meta, errors)
+			}
+		}
+	})
+
+	t.Run("Hosts", func(t *testing.T) {
+		entries, errors := ParseJsonRouteFile(strings.NewReader(`[ {"name": "foo", "pattern": "/foo", "hosts": ["API.example.com", "*.example.com"]} ]`), DisallowUpperCase)
+		if len(errors) != 0 || len(entries) != 1 || !reflect.DeepEqual(entries[0].hosts, []string{"api.example.com", "*.example.com"}) {
+			t.Fatalf("Expected an entry with hosts, got %+v %+v\n", entries, errors)
+		}
+		for _, hosts := range []string{`["example.com:80"]`, `["*"]`, `[1]`} {
+			_, errors = ParseJsonRouteFile(strings.NewReader(`[ {"name": "foo", "pattern": "/foo", "hosts": `+hosts+`} ]`), DisallowUpperCase)
+			if len(errors) != 1 {
+				t.Fatalf("Expected an error for hosts %v, got %+v\n", hosts, errors)
+			}
+		}
+	})
+
+	t.Run("Include", func(t *testing.T) {
+		entries, errors := ParseJsonRouteFile(strings.NewReader(`[ {"name": "foo", "pattern": "/foo"}, [{"include": "foo.json"}] ]`), DisallowUpperCase)
+		if len(errors) != 0 || len(entries) != 2 || entries[1].include != "foo.json" || entries[1].indent != 1 {
+			t.Fatalf("Expected an include directive, got %+v %+v\n", entries, errors)
+		}
+		_, errors = ParseJsonRouteFile(strings.NewReader(`[ {"include": "foo.json", "name": "foo"} ]`), DisallowUpperCase)
+		if len(errors) != 1 || errors[0].Kind != UnexpectedKeyInJSONRouteFile {
+			t.Fatalf("Expected an 'UnexpectedKeyInJSONRouteFile' error, got %+v\n", errors)
+		}
+	})
+
+	t.Run("Tags and methods", func(t *testing.T) {
+		entries, errors := ParseJsonRouteFile(strings.NewReader(`[ {"name": "a", "pattern": "/a", "tags": ["x"]}, {"name": "b", "pattern": "/b", "methods": ["POST", "PUT"]}, {"name": "c", "pattern": "/c"} ]`), DisallowUpperCase)
+		if len(errors) != 0 || len(entries) != 3 {
+			t.Fatalf("Expected three entries, got %+v %+v\n", entries, errors)
+		}
+		if !reflect.DeepEqual(entries[0].tags, map[string]struct{}{"x": {}}) || !reflect.DeepEqual(entries[0].methods, map[string]struct{}{"GET": {}}) {
+			t.Errorf("Expected tag 'x' and the default GET method, got %+v\n", entries[0])
+		}
+		if !reflect.DeepEqual(entries[1].tags, map[string]struct{}{}) || !reflect.DeepEqual(entries[1].methods, map[string]struct{}{"POST": {}, "PUT": {}}) {
+			t.Errorf("Expected no tags and the methods POST and PUT, got %+v\n", entries[1])
+		}
+		if !reflect.DeepEqual(entries[2].tags, map[string]struct{}{}) || !reflect.DeepEqual(entries[2].methods, map[string]struct{}{"GET": {}}) {
+			t.Errorf("Expected no tags and the default GET method, got %+v\n", entries[2])
+		}
+
+		_, errors = ParseJsonRouteFile(strings.NewReader(`[ {"name": "a", "pattern": "/a", "methods": []} ]`), DisallowUpperCase)
+		if len(errors) != 1 || errors[0].Kind != EmptyMethodList {
+			t.Fatalf("Expected an 'EmptyMethodList' error, got %+v\n", errors)
+		}
+	})
+
+	t.Run("Mount", func(t *testing.T) {
+		entries, errors := ParseJsonRouteFile(strings.NewReader(`[ {"name": "managers", "pattern": "/managers", "tags": ["managers"], "methods": ["POST"], "mount": "managers.json"} ]`), DisallowUpperCase)
+		if len(errors) != 0 || len(entries) != 1 {
+			t.Fatalf("Expected one entry, got %+v %+v\n", entries, errors)
+		}
+		e := entries[0]
+		if e.include != "managers.json" || e.name != "managers" || !reflect.DeepEqual(e.tags, map[string]struct{}{"managers": {}}) || !reflect.DeepEqual(e.methods, map[string]struct{}{"POST": {}}) {
+			t.Fatalf("Unexpected mount entry %+v\n", e)
+		}
+	})
+
 	t.Run("Doesn't allow upper case with DisallowUpperCase case policy", func(t *testing.T) {
 		_, errors := ParseJsonRouteFile(strings.NewReader(`[  {"name": "foo", "pattern": ["/", "FOO", "/", "pat"]} ]`), DisallowUpperCase)
 		if len(errors) != 1 {
@@ -0,0 +1,288 @@
+package compiler
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+
+	j "github.com/addrummond/jsonstream"
+)
+
+type openAPIInputDocument struct {
+	Paths      map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Parameters map[string]openAPIInputParameter `json:"parameters"`
+		Schemas    map[string]openAPIInputSchema    `json:"schemas"`
+	} `json:"components"`
+}
+
+type openAPIInputPathItem struct {
+	Parameters []openAPIInputParameter `json:"parameters"`
+	Get        *openAPIInputOperation  `json:"get"`
+	Put        *openAPIInputOperation  `json:"put"`
+	Post       *openAPIInputOperation  `json:"post"`
+	Delete     *openAPIInputOperation  `json:"delete"`
+	Options    *openAPIInputOperation  `json:"options"`
+	Head       *openAPIInputOperation  `json:"head"`
+	Patch      *openAPIInputOperation  `json:"patch"`
+	Trace      *openAPIInputOperation  `json:"trace"`
+}
+
+type openAPIInputOperation struct {
+	OperationID string                  `json:"operationId"`
+	Tags        []string                `json:"tags"`
+	Parameters  []openAPIInputParameter `json:"parameters"`
+}
+
+type openAPIInputParameter struct {
+	Ref    string              `json:"$ref"`
+	Name   string              `json:"name"`
+	In     string              `json:"in"`
+	Schema *openAPIInputSchema `json:"schema"`
+}
+
+type openAPIInputSchema struct {
+	Ref  string `json:"$ref"`
+	Type string `json:"type"`
+}
+
+// ParseOpenAPIFile parses the paths of an OpenAPI 3 JSON document into route
+// file entries. There is an entry for each operationId, whose methods are the
+// methods of the operations with that id. Operations without an operationId
+// are grouped into an entry named after their path (e.g. 'users/id' for
+// '/users/{id}'). Path parameters become named parameters, or named integer
+// parameters if their schema has type 'integer'. The tags of the operations
+// become the tags of the entry.
+func ParseOpenAPIFile(input io.Reader, casePolicy CasePolicy) (entries []RouteFileEntry, errors []RouteError) {
+	inp, err := io.ReadAll(input)
+	if err != nil {
+		errors = appendRouteErr(errors, IOError, -1, -1)
+		return
+	}
+
+	// The document is tokenized as well as decoded to find the line number of
+	// each path and to report syntax errors with their positions.
+	pathLines := make(map[string]int)
+	var parser j.Parser
+	for t := range j.WithPaths(parser.Tokenize(inp)) {
+		if e := t.Token.AsError(); e != nil {
+			errors = append(errors, RouteError{Kind: InvalidJsonInJSONRouteFile, Line: t.Token.Line, Col: t.Token.Col, JsonError: t.Token})
+			return
+		}
+		if p := j.PathToSlice(t.Path); len(p) == 2 && p[0] == "paths" && t.Token.Kind != j.ObjectEnd {
+			if path, ok := p[1].(string); ok {
+				if _, seen := pathLines[path]; !seen {
+					pathLines[path] = t.Token.Line
+				}
+			}
+		}
+	}
+
+	var doc openAPIInputDocument
+	if err := json.Unmarshal(inp, &doc); err != nil {
+		errors = appendRouteErr(errors, UnexpectedValueInOpenAPIDocument, 1, -1)
+		return
+	}
+	if doc.Paths == nil {
+		errors = appendRouteErr(errors, OpenAPIDocumentMissingPaths, 1, -1)
+		return
+	}
+
+	// For determinism
+	paths := stringSetToList(doc.Paths)
+	sort.SliceStable(paths, func(i, j int) bool { return pathLines[paths[i]] < pathLines[paths[j]] })
+
+	entryIndices := make(map[string]int)
+	for _, path := range paths {
+		if strings.HasPrefix(path, "x-") {
+			continue // specification extension
+		}
+		line := pathLines[path]
+		var item openAPIInputPathItem
+		if err := json.Unmarshal(doc.Paths[path], &item); err != nil {
+			errors = appendRouteErr(errors, UnexpectedValueInOpenAPIDocument, line, -1)
+			continue
+		}
+
+		ops := []struct {
+			method string
+			op     *openAPIInputOperation
+		}{
+			{"GET", item.Get}, {"PUT", item.Put}, {"POST", item.Post}, {"DELETE", item.Delete},
+			{"OPTIONS", item.Options}, {"HEAD", item.Head}, {"PATCH", item.Patch}, {"TRACE", item.Trace},
+		}
+		for _, o := range ops {
+			if o.op == nil {
+				continue
+			}
+
+			integerParams := make(map[string]bool)
+			for _, params := range [][]openAPIInputParameter{item.Parameters, o.op.Parameters} {
+				for _, p := range params {
+					p, ok := resolveOpenAPIParameter(&doc, p)
+					if !ok {
+						errors = appendRouteErr(errors, UnresolvableRefInOpenAPIDocument, line, -1)
+						continue
+					}
+					if p.In != "path" {
+						continue
+					}
+					isInteger, ok := isOpenAPIIntegerSchema(&doc, p.Schema)
+					if !ok {
+						errors = appendRouteErr(errors, UnresolvableRefInOpenAPIDocument, line, -1)
+					}
+					integerParams[p.Name] = isInteger
+				}
+			}
+
+			pattern, ok := openAPIPathToPattern(path, integerParams)
+			if !ok {
+				errors = appendRouteErr(errors, BadPathInOpenAPIDocument, line, -1)
+				break
+			}
+			validationErrorKinds := validateRouteElems(0, 0, pattern)
+			for _, k := range validationErrorKinds {
+				errors = appendRouteErr(errors, k, line, -1)
+			}
+			if len(validationErrorKinds) > 0 {
+				break
+			}
+			if casePolicy == DisallowUpperCase {
+				for _, e := range pattern {
+					if e.kind == constant && containsNonLowerCase(e.value) != -1 {
+						errors = appendRouteErr(errors, UpperCaseCharInRoute, line, -1)
+						break
+					}
+				}
+			}
+
+			name := o.op.OperationID
+			if name == "" {
+				name = openAPIPathToName(path)
+			}
+
+			i, ok := entryIndices[name]
+			if !ok || !samePattern(entries[i].pattern, pattern) {
+				i = len(entries)
+				entryIndices[name] = i
+				entries = append(entries, RouteFileEntry{
+					indent:   0,
+					name:     name,
+					pattern:  pattern,
+					line:     line,
+					terminal: true,
+					tags:     make(map[string]struct{}),
+					methods:  make(map[string]struct{}),
+				})
+			}
+			entries[i].methods[o.method] = struct{}{}
+			for _, t := range o.op.Tags {
+				entries[i].tags[t] = struct{}{}
+			}
+		}
+	}
+
+	return
+}
+
+func resolveOpenAPIParameter(doc *openAPIInputDocument, p openAPIInputParameter) (openAPIInputParameter, bool) {
+	if p.Ref == "" {
+		return p, true
+	}
+	const prefix = "#/components/parameters/"
+	if !strings.HasPrefix(p.Ref, prefix) {
+		return p, false
+	}
+	resolved, ok := doc.Components.Parameters[p.Ref[len(prefix):]]
+	if !ok || resolved.Ref != "" {
+		return p, false
+	}
+	return resolved, true
+}
+
+func isOpenAPIIntegerSchema(doc *openAPIInputDocument, s *openAPIInputSchema) (isInteger bool, ok bool) {
+	if s == nil {
+		return false, true
+	}
+	if s.Ref == "" {
+		return s.Type == "integer", true
+	}
+	const prefix = "#/components/schemas/"
+	if !strings.HasPrefix(s.Ref, prefix) {
+		return false, false
+	}
+	resolved, ok := doc.Components.Schemas[s.Ref[len(prefix):]]
+	if !ok || resolved.Ref != "" {
+		return false, false
+	}
+	return resolved.Type == "integer", true
+}
+
+// openAPIPathToPattern converts an OpenAPI path such as '/users/{id}' to route
+// elements. It returns false if the path contains unbalanced braces or an
+// empty parameter name.
+func openAPIPathToPattern(path string, integerParams map[string]bool) ([]routeElement, bool) {
+	var elems []routeElement
+	var current strings.Builder
+	flush := func() {
+		if current.Len() > 0 {
+			elems = append(elems, routeElement{kind: constant, value: current.String()})
+			current.Reset()
+		}
+	}
+
+	for i := 0; i < len(path); i++ {
+		switch path[i] {
+		case '/':
+			flush()
+			elems = append(elems, routeElement{kind: slash})
+		case '{':
+			end := strings.IndexByte(path[i:], '}')
+			if end <= 1 {
+				return nil, false
+			}
+			name := path[i+1 : i+end]
+			if strings.ContainsAny(name, "{/") {
+				return nil, false
+			}
+			flush()
+			kind := parameter
+			if integerParams[name] {
+				kind = integerParameter
+			}
+			elems = append(elems, routeElement{kind: kind, value: name})
+			i += end
+		case '}':
+			return nil, false
+		default:
+			current.WriteByte(path[i])
+		}
+	}
+	flush()
+
+	return elems, true
+}
+
+// openAPIPathToName derives a route name from an OpenAPI path by removing the
+// braces around parameters and the leading and trailing slashes. The name of
+// the path '/' is 'root'.
+func openAPIPathToName(path string) string {
+	name := strings.Trim(strings.NewReplacer("{", "", "}", "").Replace(path), "/")
+	if name == "" {
+		return "root"
+	}
+	return name
+}
+
+func samePattern(p1, p2 []routeElement) bool {
+	if len(p1) != len(p2) {
+		return false
+	}
+	for i := range p1 {
+		if p1[i].kind != p2[i].kind || p1[i].value != p2[i].value {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,110 @@
+package compiler
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseOpenAPIFile(t *testing.T) {
+	const doc = `{
+  "openapi": "3.0.3",
+  "info": {"title": "Test", "version": "1"},
+  "paths": {
+    "/users/{id}": {
+      "parameters": [{"$ref": "#/components/parameters/UserId"}],
+      "get": {"operationId": "getUser", "tags": ["users"]},
+      "put": {"operationId": "getUser"},
+      "delete": {"operationId": "deleteUser", "tags": ["admin"]}
+    },
+    "/files/{name}.{ext}": {
+      "get": {
+        "parameters": [
+          {"name": "name", "in": "path", "required": true, "schema": {"type": "string"}},
+          {"name": "ext", "in": "path", "required": true, "schema": {"$ref": "#/components/schemas/Ext"}},
+          {"name": "q", "in": "query", "schema": {"type": "integer"}}
+        ]
+      }
+    },
+    "/": {"get": {}},
+    "x-extension": "ignored"
+  },
+  "components": {
+    "parameters": {
+      "UserId": {"name": "id", "in": "path", "required": true, "schema": {"type": "integer"}}
+    },
+    "schemas": {
+      "Ext": {"type": "string"}
+    }
+  }
+}`
+
+	entries, errs := ParseOpenAPIFile(strings.NewReader(doc), DisallowUpperCase)
+	if len(errs) > 0 {
+		t.Fatalf("%+v\n", errs)
+	}
+
+	formatted := make([]string, len(entries))
+	for i, e := range entries {
+		formatted[i] = debugFormatOpenAPIEntry(&e)
+	}
+	expected := []string{
+		"5 getUser [GET PUT] [users] / 'users' / $#{id}",
+		"5 deleteUser [DELETE] [admin] / 'users' / $#{id}",
+		"11 files/name.ext [GET] [] / 'files' / ${name} '.' ${ext}",
+		"20 root [GET] [] /",
+	}
+	if !reflect.DeepEqual(formatted, expected) {
+		t.Errorf("Expected\n%v\ngot\n%v\n", strings.Join(expected, "\n"), strings.Join(formatted, "\n"))
+	}
+}
+
+func TestParseOpenAPIFileErrors(t *testing.T) {
+	cases := []struct {
+		doc  string
+		kind RouteErrorKind
+		line int
+	}{
+		{`{"paths": {"/a/{b": {"get": {}}}}`, BadPathInOpenAPIDocument, 1},
+		{"{\n\"paths\": {\n\"/a/{b}\": {\"get\": {\"parameters\": [{\"$ref\": \"#/nope\"}]}}}}", UnresolvableRefInOpenAPIDocument, 3},
+		{`{"paths": {"/A": {"get": {}}}}`, UpperCaseCharInRoute, 1},
+		{`{"paths": []}`, UnexpectedValueInOpenAPIDocument, 1},
+		{`{"openapi": "3.0.3"}`, OpenAPIDocumentMissingPaths, 1},
+		{`{"paths": `, InvalidJsonInJSONRouteFile, 1},
+	}
+
+	for _, c := range cases {
+		_, errs := ParseOpenAPIFile(strings.NewReader(c.doc), DisallowUpperCase)
+		if len(errs) != 1 || errs[0].Kind != c.kind || errs[0].Line != c.line {
+			t.Errorf("Expected one error of kind %v on line %v for %v, got %+v\n", c.kind, c.line, c.doc, errs)
+		}
+	}
+}
+
+func TestParseOpenAPIFileOverlap(t *testing.T) {
+	const doc = `{
+  "paths": {
+    "/users/{id}": {"get": {"operationId": "getUser"}},
+    "/users/{name}": {"get": {"operationId": "getUserByName"}},
+    "/users/{id}/posts": {"get": {}}
+  }
+}`
+
+	entries, errs := ParseOpenAPIFile(strings.NewReader(doc), DisallowUpperCase)
+	if len(errs) > 0 {
+		t.Fatalf("%+v\n", errs)
+	}
+	routes, errs := ProcessRouteFiles([][]RouteFileEntry{entries}, []string{"openapi.json"}, "/")
+	if len(errs) > 0 {
+		t.Fatalf("%+v\n", errs)
+	}
+	groupErrors := CheckForGroupErrors(routes)
+	if len(groupErrors) != 1 || groupErrors[0].Kind != OverlappingRoutes || groupErrors[0].Line != 3 || groupErrors[0].OtherLine != 4 {
+		t.Errorf("Expected overlap between lines 3 and 4, got %+v\n", groupErrors)
+	}
+}
+
+func debugFormatOpenAPIEntry(e *RouteFileEntry) string {
+	return fmt.Sprintf("%v %v %v %v %v", e.line, e.name, stringSetToList(e.methods), stringSetToList(e.tags), debugPrintParsedRoute(e.pattern))
+}
@@ -4,6 +4,10 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"unicode"
@@ -15,20 +19,28 @@ import (
 type routeElementKind int
 
 const (
-	illegalCodePoint       routeElementKind = iota
-	illegalQuestionMark    routeElementKind = iota
-	illegalHash            routeElementKind = iota
-	illegalWhitespace      routeElementKind = iota
-	illegalCharInParamName routeElementKind = iota
-	illegalBackslashEscape routeElementKind = iota
-	slash                  routeElementKind = iota
-	constant               routeElementKind = iota
-	parameter              routeElementKind = iota
-	integerParameter       routeElementKind = iota
-	restParameter          routeElementKind = iota
-	singleGlob             routeElementKind = iota
-	doubleGlob             routeElementKind = iota
-	noTrailingSlash        routeElementKind = iota
+	illegalCodePoint        routeElementKind = iota
+	illegalQuestionMark     routeElementKind = iota
+	illegalHash             routeElementKind = iota
+	illegalWhitespace       routeElementKind = iota
+	illegalCharInParamName  routeElementKind = iota
+	illegalBackslashEscape  routeElementKind = iota
+	illegalEnumeratedParam  routeElementKind = iota
+	illegalConstrainedKind  routeElementKind = iota
+	illegalParamRegexp      routeElementKind = iota
+	illegalParamRegexpMatch routeElementKind = iota
+	illegalAlternation      routeElementKind = iota
+	slash                   routeElementKind = iota
+	constant                routeElementKind = iota
+	parameter               routeElementKind = iota
+	integerParameter        routeElementKind = iota
+	restParameter           routeElementKind = iota
+	singleGlob              routeElementKind = iota
+	doubleGlob              routeElementKind = iota
+	noTrailingSlash         routeElementKind = iota
+	optionalStart           routeElementKind = iota
+	optionalEnd             routeElementKind = iota
+	alternation             routeElementKind = iota
 )
 
 func (k routeElementKind) String() string {
@@ -45,6 +57,16 @@ func (k routeElementKind) String() string {
 		return "<illegal-param-name-char>"
 	case illegalBackslashEscape:
 		return "<illegal-backslash-escape>"
+	case illegalEnumeratedParam:
+		return "<illegal-enumerated-param>"
+	case illegalConstrainedKind:
+		return "<illegal-constrained-kind>"
+	case illegalParamRegexp:
+		return "<illegal-param-regexp>"
+	case illegalParamRegexpMatch:
+		return "<illegal-param-regexp-match>"
+	case illegalAlternation:
+		return "<illegal-alternation>"
 	case slash:
 		return "/"
 	case constant:
@@ -61,6 +83,12 @@ func (k routeElementKind) String() string {
 		return "**"
 	case noTrailingSlash:
 		return "!/"
+	case optionalStart:
+		return "["
+	case optionalEnd:
+		return "]"
+	case alternation:
+		return "|"
 	}
 	panic(fmt.Sprintf("Unrecognized routeElementKind %v", int(k)))
 }
@@ -70,6 +98,15 @@ type routeElement struct {
 	value string
 	line  int // used only for json route file parses
 	col   int
+	// For parameters written as ':{name in a,b,c}', the values the parameter
+	// is restricted to. For alternations written as '(a|b|c)', the
+	// alternatives. (A constant with values is one of the alternatives of an
+	// alternation, chosen when the route was expanded.)
+	values []string
+	// For parameters written as ':{name ~ regexp}', the regexp.
+	regexp string
+	// For parameters written as ':type:name', the name of the type.
+	paramType string
 }
 
 func badCodePoint(r rune) bool {
@@ -88,12 +125,42 @@ func parseRoute(route string) []routeElement {
 
 	// all special chars are ASCII so we can iterate by byte
 	currentElem := routeElement{}
+	optionalDepth := 0
 	for i := 0; i < len(route); {
 		b := route[i]
 
 		currentElem = routeElement{}
 		currentElem.col = i
 		startI := i
+
+		// A '[' starts an optional group only if it's followed by a '/', and a
+		// ']' ends one only if there's a group to end. Otherwise they're ordinary
+		// constant characters.
+		if startsOptionalGroup(route, i) {
+			i++
+			optionalDepth++
+			elems = append(elems, routeElement{kind: optionalStart, col: startI})
+			continue
+		}
+		if b == ']' && optionalDepth > 0 {
+			i++
+			optionalDepth--
+			elems = append(elems, routeElement{kind: optionalEnd, col: startI})
+			continue
+		}
+		// Likewise, a '(' starts an alternation only if it's followed by a '|'
+		// before the next ')' and there's no '/' in between.
+		if end := alternationEnd(route, i); end != -1 {
+			i = end + 1
+			currentElem.kind = alternation
+			currentElem.values = strings.Split(route[startI+1:end], "|")
+			if !validAlternatives(currentElem.values) {
+				elems = append(elems, routeElement{kind: illegalAlternation, col: startI})
+			}
+			elems = append(elems, currentElem)
+			continue
+		}
+
 		switch b {
 		case '\x00', '\x01', '\x02', '\x03', '\x04', '\x05', '\x06', '\x07', '\x08', '\x0B', '\x0C', '\x0E', '\x0F', '\x10', '\x11', '\x12', '\x13', '\x14', '\x15', '\x16', '\x17', '\x18', '\x19', '\x1A', '\x1B', '\x1C', '\x1D', '\x1E', '\x1F':
 			i++
@@ -125,6 +192,11 @@ func parseRoute(route string) []routeElement {
 				currentElem.kind = singleGlob
 			}
 		case ':':
+			typ := paramTypePrefix(route[i+1:])
+			i += len(typ)
+			if typ != "" {
+				i++
+			}
 			isInteger := false
 			isRest := false
 			if i+1 < len(route) && route[i+1] == '#' {
@@ -146,15 +218,19 @@ func parseRoute(route string) []routeElement {
 				}
 
 				badChar := false
+				contentStart := i
+				contentEnd := len(route)
+				firstBadEscape := -1
 				for i < len(route) {
 					if route[i] == '}' {
+						contentEnd = i
 						i++
 						break
 					}
 					if route[i] == '\\' && i+1 < len(route) {
 						i++
-						if route[i] != '\\' && route[i] != '}' && route[i] != '#' {
-							badEscape = true
+						if route[i] != '\\' && route[i] != '}' && route[i] != '#' && firstBadEscape == -1 {
+							firstBadEscape = i
 						}
 					}
 					r, l := utf8.DecodeRuneInString(route[i:])
@@ -175,9 +251,41 @@ func parseRoute(route string) []routeElement {
 						currentElem.kind = restParameter
 					}
 					currentElem.value = sb.String()
+					if name, values, ok := strings.Cut(currentElem.value, " in "); ok {
+						currentElem.value = strings.Trim(name, " ")
+						currentElem.values = parseEnumeratedValues(values)
+						if currentElem.value == "" || currentElem.values == nil {
+							elems = append(elems, routeElement{kind: illegalEnumeratedParam, col: startI})
+						}
+						if isInteger || isRest {
+							elems = append(elems, routeElement{kind: illegalConstrainedKind, col: startI})
+						}
+					} else if rawName, re, ok := strings.Cut(route[contentStart:contentEnd], " ~ "); ok {
+						// The regexp is taken from the raw text, as it may contain
+						// backslash escapes.
+						name, _, _ := strings.Cut(currentElem.value, " ~ ")
+						currentElem.value = strings.Trim(name, " ")
+						currentElem.regexp = strings.Trim(re, " ")
+						if currentElem.value == "" || currentElem.regexp == "" {
+							elems = append(elems, routeElement{kind: illegalParamRegexp, col: startI})
+						} else if _, errKind, ok := translateParamRegexp(currentElem.regexp); !ok {
+							if errKind == BadRegexpInParameter {
+								elems = append(elems, routeElement{kind: illegalParamRegexpMatch, col: startI})
+							} else {
+								elems = append(elems, routeElement{kind: illegalParamRegexp, col: startI})
+							}
+						}
+						if isInteger || isRest {
+							elems = append(elems, routeElement{kind: illegalConstrainedKind, col: startI})
+						}
+						if firstBadEscape >= contentStart+len(rawName) {
+							firstBadEscape = -1
+						}
+					}
 				}
+				badEscape = firstBadEscape != -1
 				if badChar {
-					elems = append(elems, routeElement{illegalCharInParamName, "", 0, startI})
+					elems = append(elems, routeElement{kind: illegalCharInParamName, col: startI})
 				}
 			} else {
 				for i < len(route) {
@@ -211,24 +319,35 @@ func parseRoute(route string) []routeElement {
 					currentElem.value = sb.String()
 				}
 			}
+			if typ != "" {
+				switch currentElem.kind {
+				case parameter:
+					currentElem.paramType = typ
+					if currentElem.values != nil || currentElem.regexp != "" {
+						elems = append(elems, routeElement{kind: illegalConstrainedKind, col: startI})
+					}
+				case constant:
+					currentElem.value = route[startI:i]
+				}
+			}
 			if badEscape {
-				elems = append(elems, routeElement{illegalBackslashEscape, "", 0, i})
+				elems = append(elems, routeElement{kind: illegalBackslashEscape, col: i})
 			}
 		default:
 			currentElem.kind = constant
 			var sb strings.Builder
-			for i < len(route) && route[i] != '/' && route[i] != '!' && route[i] != '*' && route[i] != ':' && route[i] != '?' && route[i] != '#' {
+			for i < len(route) && route[i] != '/' && route[i] != '!' && route[i] != '*' && route[i] != ':' && route[i] != '?' && route[i] != '#' && !startsOptionalGroup(route, i) && (route[i] != ']' || optionalDepth == 0) && alternationEnd(route, i) == -1 {
 				if route[i] == '\\' {
 					i++
 					if i == len(route) {
 						sb.WriteByte('\\')
-					} else if route[i] == ':' || route[i] == '!' || route[i] == '[' || route[i] == ']' || route[i] == '*' || route[i] == '\\' {
+					} else if route[i] == ':' || route[i] == '!' || route[i] == '[' || route[i] == ']' || route[i] == '(' || route[i] == ')' || route[i] == '|' || route[i] == '*' || route[i] == '^' || route[i] == '@' || route[i] == '\\' {
 						sb.WriteByte(route[i])
 						i++
 					} else {
 						currentElem.value = sb.String()
 						elems = append(elems, currentElem)
-						elems = append(elems, routeElement{illegalBackslashEscape, "", 0, i})
+						elems = append(elems, routeElement{kind: illegalBackslashEscape, col: i})
 						currentElem.kind = constant
 						currentElem.value = string(route[i])
 						sb.Reset()
@@ -239,14 +358,14 @@ func parseRoute(route string) []routeElement {
 					if unicode.IsSpace(r) {
 						currentElem.value = sb.String()
 						elems = append(elems, currentElem)
-						elems = append(elems, routeElement{illegalWhitespace, "", 0, i})
+						elems = append(elems, routeElement{kind: illegalWhitespace, col: i})
 						currentElem.kind = constant
 						currentElem.value = ""
 						sb.Reset()
 					} else if badCodePoint(r) {
 						currentElem.value = sb.String()
 						elems = append(elems, currentElem)
-						elems = append(elems, routeElement{illegalCodePoint, "", 0, i})
+						elems = append(elems, routeElement{kind: illegalCodePoint, col: i})
 						currentElem.kind = constant
 						currentElem.value = ""
 						sb.Reset()
@@ -264,6 +383,71 @@ func parseRoute(route string) []routeElement {
 	return elems
 }
 
+func startsOptionalGroup(route string, i int) bool {
+	return route[i] == '[' && i+1 < len(route) && route[i+1] == '/'
+}
+
+// alternationEnd returns the index of the ')' that ends the alternation
+// starting at index i of the route, or -1 if there's no alternation there.
+func alternationEnd(route string, i int) int {
+	if route[i] != '(' {
+		return -1
+	}
+	hasBar := false
+	for j := i + 1; j < len(route); j++ {
+		switch route[j] {
+		case '|':
+			hasBar = true
+		case ')':
+			if hasBar {
+				return j
+			}
+			return -1
+		case '/', '(', '\\':
+			return -1
+		}
+	}
+	return -1
+}
+
+// validAlternatives checks that the alternatives of an alternation are
+// distinct and that each is nonempty and contains no character that can't
+// appear in a path segment.
+func validAlternatives(alternatives []string) bool {
+	for i, a := range alternatives {
+		if !validEnumeratedValue(a) || slices.Contains(alternatives[:i], a) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseEnumeratedValues parses the comma-separated list of values of an
+// enumerated parameter. It returns nil if any of the values is empty or
+// contains a character that can't appear in a path segment.
+func parseEnumeratedValues(s string) []string {
+	values := strings.Split(s, ",")
+	for i := range values {
+		values[i] = strings.Trim(values[i], " ")
+		if !validEnumeratedValue(values[i]) {
+			return nil
+		}
+	}
+	return values
+}
+
+func validEnumeratedValue(v string) bool {
+	if v == "" {
+		return false
+	}
+	for _, r := range v {
+		if r == '/' || r == '?' || r == '#' || unicode.IsSpace(r) || badCodePoint(r) {
+			return false
+		}
+	}
+	return true
+}
+
 func isParamOrGlob(kind routeElementKind) bool {
 	switch kind {
 	case parameter, integerParameter, restParameter, singleGlob, doubleGlob:
@@ -279,6 +463,21 @@ func validateRouteElems(initialIndent, indent int, elems []routeElement) []Route
 
 	var errors []RouteErrorKind
 
+	if !validOptionalGroups(elems) {
+		errors = append(errors, BadOptionalGroup)
+	}
+	if alternationInOptionalGroup(elems) {
+		errors = append(errors, BadAlternation)
+	}
+
+	// The remaining checks apply to the route as it is when all of its optional
+	// groups are present.
+	firstElem := elems[0]
+	elems = withoutOptionalGroupMarkers(elems)
+	if len(elems) == 0 {
+		return append(errors, MissingNameOrRoute)
+	}
+
 	for i := 0; i < len(elems)-1; i++ {
 		if elems[i].kind == slash && elems[i+1].kind == slash {
 			errors = append(errors, MultipleSlashesInARow)
@@ -290,7 +489,11 @@ func validateRouteElems(initialIndent, indent int, elems []routeElement) []Route
 		}
 	}
 
-	if (initialIndent == -1 || indent == initialIndent) && elems[0].kind != slash {
+	if hasDuplicateParamName(elems) {
+		errors = append(errors, DuplicateParameterName)
+	}
+
+	if (initialIndent == -1 || indent == initialIndent) && firstElem.kind != slash {
 		errors = append(errors, RootMustStartWithSlash)
 	}
 	if elems[len(elems)-1].kind == noTrailingSlash {
@@ -304,14 +507,94 @@ func validateRouteElems(initialIndent, indent int, elems []routeElement) []Route
 	return errors
 }
 
+// hasDuplicateParamName checks whether two parameters have the same name, as
+// only one of their values could be given in the route's result.
+func hasDuplicateParamName(elems []routeElement) bool {
+	names := make(map[string]struct{})
+	for _, e := range elems {
+		switch e.kind {
+		case parameter, integerParameter, restParameter:
+			if _, ok := names[e.value]; ok {
+				return true
+			}
+			names[e.value] = struct{}{}
+		}
+	}
+	return false
+}
+
+// validOptionalGroups checks that optional groups are balanced, that each
+// group starts with a '/' but doesn't end with one, and that each group follows
+// some non-optional part of the pattern other than a '/'.
+func validOptionalGroups(elems []routeElement) bool {
+	depth := 0
+	for i, e := range elems {
+		switch e.kind {
+		case optionalStart:
+			if i == 0 || i+1 == len(elems) || elems[i-1].kind == slash || elems[i+1].kind != slash {
+				return false
+			}
+			depth++
+		case optionalEnd:
+			if depth == 0 || elems[i-1].kind == slash {
+				return false
+			}
+			depth--
+		case noTrailingSlash:
+			if depth > 0 {
+				return false
+			}
+		}
+	}
+	return depth == 0
+}
+
+// alternationInOptionalGroup checks whether an alternation appears in an
+// optional group. This isn't permitted, as the route's alternatives would
+// overlap whenever the group is absent.
+func alternationInOptionalGroup(elems []routeElement) bool {
+	depth := 0
+	for _, e := range elems {
+		switch e.kind {
+		case optionalStart:
+			depth++
+		case optionalEnd:
+			depth--
+		case alternation:
+			if depth > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func withoutOptionalGroupMarkers(elems []routeElement) []routeElement {
+	if !slices.ContainsFunc(elems, isOptionalGroupMarker) {
+		return elems
+	}
+	return slices.DeleteFunc(slices.Clone(elems), isOptionalGroupMarker)
+}
+
+func isOptionalGroupMarker(e routeElement) bool {
+	return e.kind == optionalStart || e.kind == optionalEnd
+}
+
 type RouteFileEntry struct {
-	indent   int
-	name     string
-	pattern  []routeElement
-	line     int
-	terminal bool // if false, the route exists only as a parent of other routes
-	tags     map[string]struct{}
-	methods  map[string]struct{}
+	indent      int
+	name        string
+	pattern     []routeElement
+	line        int
+	terminal    bool // if false, the route exists only as a parent of other routes
+	tags        map[string]struct{}
+	localTags   map[string]struct{} // tags that apply only to the route itself
+	removedTags map[string]struct{} // inherited tags that don't apply to the route or its children
+	meta        map[string]string
+	methods     map[string]struct{}
+	priority    int
+	hosts       []string // the hosts the route and its children are restricted to (any host if empty)
+	include     string   // if nonempty, the entry is an include directive for the given path (or mounts the path under the route if name is nonempty)
+	filename    string   // the file the entry was included from, if any
 }
 
 type RouteErrorKind int
@@ -356,7 +639,29 @@ const (
 	BadFirstMemberOfJSONRouteFilePatternElement
 	UnexpectedJSONRouteFilePatternElementMember
 	JSONRouteFilePatternElementParameterNameMustBeString
+	UnexpectedValueInOpenAPIDocument
+	OpenAPIDocumentMissingPaths
+	UnresolvableRefInOpenAPIDocument
+	BadPathInOpenAPIDocument
+	BadPriority
+	UnenforceablePriority
+	BadEnumeratedParameter
+	ConstrainedParameterMustBeString
+	UnsupportedRegexpInParameter
+	BadRegexpInParameter
+	BadOptionalGroup
+	BadAlternation
+	IncludeCycle
+	IndentUnderInclude
+	BadMetadata
+	BadTagModifier
+	BadHost
+	DuplicateParameterName
+	TooManyAlternationExpansions
 	WarningBigGroup = iota | RouteWarning
+	WarningRestParameterInOpenAPI
+	WarningGlobInOpenAPI
+	WarningMethodNotInOpenAPI
 )
 
 type RouteError struct {
@@ -364,11 +669,14 @@ type RouteError struct {
 	Line          int
 	Col           int
 	DuplicateName string
+	Method        string
+	Witness       string // for OverlappingRoutes, a URL path matched by both routes
 	OtherLine     int
 	IOError       error
 	Filenames     []string
 	Group         []RouteWithParents
 	JsonError     j.Token
+	Include       string // for IncludeCycle, the file that includes itself
 }
 
 func (e RouteError) Error() string {
@@ -382,6 +690,9 @@ func (e RouteError) Error() string {
 		desc = "pattern at root level must start with '/'"
 	case OverlappingRoutes:
 		desc = "routes overlap"
+		if e.Witness != "" {
+			desc += fmt.Sprintf("; both match %v", e.Witness)
+		}
 	case MisplacedDot:
 		desc = "misplaced '.': should come immediately after parent route and be indented under it"
 	case RouteContainsBadCodePoint:
@@ -444,6 +755,47 @@ func (e RouteError) Error() string {
 		desc = "Unexpected pattern element member"
 	case JSONRouteFilePatternElementParameterNameMustBeString:
 		desc = "Parameter name must be string"
+	case UnexpectedValueInOpenAPIDocument:
+		desc = "Unexpected value in OpenAPI document"
+	case OpenAPIDocumentMissingPaths:
+		desc = "OpenAPI document has no paths object"
+	case UnresolvableRefInOpenAPIDocument:
+		desc = "Unresolvable $ref in OpenAPI document"
+	case BadPathInOpenAPIDocument:
+		desc = "Bad path template in OpenAPI document"
+	case BadPriority:
+		desc = "priority must be an integer"
+	case BadEnumeratedParameter:
+		desc = "enumerated parameter must have the form ':{name in value1,value2,...}', where each value is nonempty and contains no '/', '?', '#' or whitespace"
+	case UnsupportedRegexpInParameter:
+		desc = "parameter regexp must have the form ':{name ~ regexp}', where the regexp contains only ASCII characters, '.', character classes, groups, '|', '*', '+', '?' and backslash escapes of punctuation"
+	case BadRegexpInParameter:
+		desc = "parameter regexp may not match the empty string or any string containing '/', '?' or '#'"
+	case BadOptionalGroup:
+		desc = "an optional group must be written '[/...]' after some non-optional part of the pattern, must be closed by ']' and may not end with '/'"
+	case BadAlternation:
+		desc = "an alternation must have the form '(value1|value2|...)', where the values are distinct and each is nonempty and contains no '/', '?', '#' or whitespace, and may not appear in an optional group"
+	case IncludeCycle:
+		desc = fmt.Sprintf("file '%v' includes itself", e.Include)
+	case BadMetadata:
+		desc = "metadata must have the form 'key=value', where the key is nonempty and is given only once for the route"
+	case BadTagModifier:
+		desc = "a tag prefixed with '.' (applying only to the route) or '-' (removing an inherited tag) must be nonempty"
+	case BadHost:
+		desc = "hosts must be host names (optionally beginning with '*.' to match any single label) separated by commas"
+	case DuplicateParameterName:
+		desc = "a parameter name may be used only once in a route, including in optional groups and enumerated parameters"
+	case TooManyAlternationExpansions:
+		desc = fmt.Sprintf("the alternations in the route and its parents would expand to more than %v routes; use fewer alternatives or split the route", MaxAlternationExpansions)
+	case IndentUnderInclude:
+		desc = "lines may not be indented under an include or mount directive"
+	case ConstrainedParameterMustBeString:
+		desc = "only untyped string parameters may be restricted to a set of values or a regexp"
+	case UnenforceablePriority:
+		desc = "routes overlap and the higher priority route cannot take precedence, as a route nested alongside the lower priority route has a priority at least as high"
+		if e.Witness != "" {
+			desc += fmt.Sprintf("; both match %v", e.Witness)
+		}
 	case InvalidJsonInJSONRouteFile:
 		desc = "Invalid JSON"
 		if e := e.JsonError.AsError(); e != nil {
@@ -451,6 +803,12 @@ func (e RouteError) Error() string {
 		}
 	case WarningBigGroup:
 		desc = "Big group"
+	case WarningRestParameterInOpenAPI:
+		desc = "route contains a rest parameter, which has no OpenAPI equivalent; route omitted from OpenAPI output"
+	case WarningGlobInOpenAPI:
+		desc = "route contains a wildcard, which has no OpenAPI equivalent; route omitted from OpenAPI output"
+	case WarningMethodNotInOpenAPI:
+		desc = fmt.Sprintf("method %v is not supported by OpenAPI; omitted from OpenAPI output", e.Method)
 	default:
 		panic(fmt.Sprintf("unrecognized routeRrrorKind %v", int(e.Kind)))
 	}
@@ -472,6 +830,17 @@ func formatErrorMessage(e RouteError, desc string) string {
 		} else {
 			msg = e.Filenames[0] + ":" + msg
 		}
+		// Any further filenames give the chain of files that include the file.
+		if len(e.Filenames) > 1 {
+			includers := make([]string, 0, len(e.Filenames)-1)
+			for _, f := range e.Filenames[1:] {
+				if f == "" {
+					f = "stdin"
+				}
+				includers = append(includers, f)
+			}
+			msg += " (included from " + strings.Join(includers, ", included from ") + ")"
+		}
 	} else if e.OtherLine != 0 && len(e.Filenames) == 2 {
 		msg = fmt.Sprintf("%v:%v: (and %v:%v): %v", e.Filenames[0], e.Line, e.Filenames[1], e.OtherLine, desc)
 	} else {
@@ -559,7 +928,7 @@ func ParseRouteFile(input io.Reader, casePolicy CasePolicy) ([]RouteFileEntry, [
 
 		// Is it '.'?
 		if isDot(wholeLine) {
-			if len(entries) == 0 || entries[len(entries)-1].indent >= indent {
+			if len(entries) == 0 || entries[len(entries)-1].indent >= indent || entries[len(entries)-1].include != "" {
 				errors = append(errors, routeError(MisplacedDot, sourceLine, -1))
 			}
 			dotLevel = indent
@@ -569,11 +938,40 @@ func ParseRouteFile(input io.Reader, casePolicy CasePolicy) ([]RouteFileEntry, [
 		}
 
 		if len(entries) != 0 && entries[len(entries)-1].indent < indent && dotLevel < indent {
+			if entries[len(entries)-1].include != "" {
+				errors = append(errors, routeError(IndentUnderInclude, sourceLine, -1))
+				continue
+			}
 			entries[len(entries)-1].terminal = false
 		}
 
-		var nameB strings.Builder
+		notionalIndent := indent
+		if indent == initialIndent {
+			// Ensure initial indent is consistent across files
+			notionalIndent = 0
+		}
+
+		if path, ok := getInclude(wholeLine[indent:]); ok {
+			entries = append(entries, RouteFileEntry{
+				indent:  notionalIndent,
+				line:    firstSourceLineOfSplice,
+				include: path,
+			})
+			lineStarts = lineStarts[:0]
+			continue
+		}
+
+		// A mount directive is a route line with a 'mount' keyword at the start
+		// and a 'from path' clause at the end.
 		i := indent
+		mountPath := ""
+		if routeStart, routeEnd, path, ok := getMount(wholeLine[indent:]); ok {
+			i += routeStart
+			wholeLine = stripTrailingWhitespace(wholeLine[:indent+routeEnd])
+			mountPath = path
+		}
+
+		var nameB strings.Builder
 		for i < len(wholeLine) {
 			if wholeLine[i] == '\\' {
 				if i+1 < len(wholeLine) {
@@ -677,10 +1075,56 @@ func ParseRouteFile(input io.Reader, casePolicy CasePolicy) ([]RouteFileEntry, [
 			i += sz
 		}
 
+		// A '^' followed by an integer and then by whitespace gives the priority
+		// of the route.
+		priority := 0
+		if priorityStr, ok := getAnnotation(wholeLine[i:], '^'); ok {
+			p, err := strconv.Atoi(priorityStr)
+			if err != nil {
+				errors = append(errors, routeError(BadPriority, sourceLine, physicalLineColumn(lineStarts, i)))
+			}
+			priority = p
+			i += len(priorityStr) + 1
+			for i < len(wholeLine) {
+				rn, sz := utf8.DecodeRuneInString(wholeLine[i:])
+				if !unicode.IsSpace(rn) {
+					break
+				}
+				i += sz
+			}
+		}
+
+		// A '@' followed by a comma-separated list of hosts and then by
+		// whitespace restricts the route and its children to the given hosts.
+		var hosts []string
+		if hostsStr, ok := getAnnotation(wholeLine[i:], '@'); ok {
+			var hostsOk bool
+			hosts, hostsOk = parseHosts(hostsStr)
+			if !hostsOk {
+				errors = append(errors, routeError(BadHost, sourceLine, physicalLineColumn(lineStarts, i)))
+			}
+			i += len(hostsStr) + 1
+			for i < len(wholeLine) {
+				rn, sz := utf8.DecodeRuneInString(wholeLine[i:])
+				if !unicode.IsSpace(rn) {
+					break
+				}
+				i += sz
+			}
+		}
+
 		patternString := wholeLine[i:]
 		patternStart := i
 		tags, tagsStart := getTags(patternString)
 		patternString = patternString[0:tagsStart]
+		tags, localTags, removedTags, modifiersOk := splitTagModifiers(tags)
+		if !modifiersOk {
+			errors = append(errors, routeError(BadTagModifier, sourceLine, -1))
+		}
+		tags, meta, metaOk := splitMetadata(tags)
+		if !metaOk {
+			errors = append(errors, routeError(BadMetadata, sourceLine, -1))
+		}
 
 		pattern := parseRoute(patternString)
 
@@ -701,6 +1145,12 @@ func ParseRouteFile(input io.Reader, casePolicy CasePolicy) ([]RouteFileEntry, [
 						errors = append(errors, routeError(UpperCaseCharInRoute, sourceLine, physicalLineColumn(lineStarts, colZeroOffset)+1))
 					}
 				}
+			case parameter, alternation:
+				if casePolicy == DisallowUpperCase {
+					if slices.ContainsFunc(elem.values, hasNonLowerCase) || hasNonLowerCase(elem.regexp) {
+						errors = append(errors, routeError(UpperCaseCharInRoute, sourceLine, physicalLineColumn(lineStarts, elem.col+patternStart)+1))
+					}
+				}
 			case illegalCodePoint:
 				errors = append(errors, routeError(RouteContainsBadCodePoint, sourceLine, physicalLineColumn(lineStarts, elem.col+patternStart)))
 			case illegalQuestionMark:
@@ -713,23 +1163,33 @@ func ParseRouteFile(input io.Reader, casePolicy CasePolicy) ([]RouteFileEntry, [
 				errors = append(errors, routeError(IllegalCharInParamName, sourceLine, physicalLineColumn(lineStarts, elem.col+patternStart)))
 			case illegalBackslashEscape:
 				errors = append(errors, routeError(IllegalBackslashEscape, sourceLine, physicalLineColumn(lineStarts, elem.col+patternStart)))
+			case illegalEnumeratedParam:
+				errors = append(errors, routeError(BadEnumeratedParameter, sourceLine, physicalLineColumn(lineStarts, elem.col+patternStart)))
+			case illegalConstrainedKind:
+				errors = append(errors, routeError(ConstrainedParameterMustBeString, sourceLine, physicalLineColumn(lineStarts, elem.col+patternStart)))
+			case illegalParamRegexp:
+				errors = append(errors, routeError(UnsupportedRegexpInParameter, sourceLine, physicalLineColumn(lineStarts, elem.col+patternStart)))
+			case illegalParamRegexpMatch:
+				errors = append(errors, routeError(BadRegexpInParameter, sourceLine, physicalLineColumn(lineStarts, elem.col+patternStart)))
+			case illegalAlternation:
+				errors = append(errors, routeError(BadAlternation, sourceLine, physicalLineColumn(lineStarts, elem.col+patternStart)))
 			}
 		}
 
-		notionalIndent := indent
-		if indent == initialIndent {
-			// Ensure initial indent is consistent across files
-			notionalIndent = 0
-		}
-
 		entries = append(entries, RouteFileEntry{
-			indent:   notionalIndent,
-			name:     name,
-			pattern:  pattern,
-			line:     firstSourceLineOfSplice,
-			terminal: true,
-			tags:     tags,
-			methods:  methods,
+			indent:      notionalIndent,
+			name:        name,
+			pattern:     pattern,
+			line:        firstSourceLineOfSplice,
+			terminal:    mountPath == "",
+			tags:        tags,
+			localTags:   localTags,
+			removedTags: removedTags,
+			meta:        meta,
+			methods:     methods,
+			priority:    priority,
+			hosts:       hosts,
+			include:     mountPath,
 		})
 
 		lineStarts = lineStarts[:0]
@@ -742,6 +1202,39 @@ func ParseRouteFile(input io.Reader, casePolicy CasePolicy) ([]RouteFileEntry, [
 	return entries, errors
 }
 
+// getInclude recognizes an include directive of the form 'include path' and
+// returns the path.
+func getInclude(line string) (string, bool) {
+	rest, ok := strings.CutPrefix(line, "include")
+	if !ok || rest == "" || (rest[0] != ' ' && rest[0] != '\t') {
+		return "", false
+	}
+	path := strings.TrimSpace(rest)
+	return path, path != ""
+}
+
+// getMount recognizes a mount directive of the form
+// 'mount name [methods] pattern [tags] from path'. It returns the offsets of the
+// start of the route name and of the 'from' keyword, and the path.
+func getMount(line string) (int, int, string, bool) {
+	rest, ok := strings.CutPrefix(line, "mount")
+	if !ok || rest == "" || (rest[0] != ' ' && rest[0] != '\t') {
+		return 0, 0, "", false
+	}
+	routeStart := len(line) - len(strings.TrimLeft(rest, " \t"))
+	for i := len(line) - len("from "); i > routeStart; i-- {
+		if strings.HasPrefix(line[i:], "from") && isTabOrSpace(line[i-1]) && isTabOrSpace(line[i+len("from")]) {
+			path := strings.TrimSpace(line[i+len("from"):])
+			return routeStart, i, path, path != ""
+		}
+	}
+	return 0, 0, "", false
+}
+
+func isTabOrSpace(c byte) bool {
+	return c == ' ' || c == '\t'
+}
+
 func physicalLineColumn(lineStarts []int, offset int) int {
 	if len(lineStarts) == 0 || lineStarts[0] != 0 {
 		panic("Bad value for lineStarts")
@@ -767,7 +1260,42 @@ func physicalLineColumn(lineStarts []int, offset int) int {
 	return search(0, len(lineStarts)-1)
 }
 
+// ParseOptions gives the formats of the files passed to
+// ParseRouteFilesWithOptions, the case policy for parsing them, and how to
+// read included files.
+type ParseOptions struct {
+	// Files before index JSONStart are in the text format.
+	JSONStart int
+	// Files from JSONStart up to OpenAPIStart are in the JSON format, and files
+	// from OpenAPIStart on are OpenAPI documents.
+	OpenAPIStart int
+	CasePolicy   CasePolicy
+	// WithReader calls its second argument with a reader for the given included
+	// file. If it is nil then included files are read from the file system.
+	WithReader func(string, func(io.Reader)) error
+}
+
+// ParseRouteFiles parses route files concurrently. Files before index jsonStart
+// are in the text format and the remaining files are in the JSON format.
+// Included files are read from the file system.
 func ParseRouteFiles(inputFiles []string, inputReaders []io.Reader, jsonStart int, casePolicy CasePolicy) ([][]RouteFileEntry, []RouteError) {
+	return ParseRouteFilesWithOptions(inputFiles, inputReaders, ParseOptions{
+		JSONStart:    jsonStart,
+		OpenAPIStart: len(inputReaders),
+		CasePolicy:   casePolicy,
+	})
+}
+
+// ParseRouteFilesWithOptions is like ParseRouteFiles, but it also accepts
+// OpenAPI documents and custom readers for included files (see ParseOptions).
+// Included files are spliced into the entries of the files that include them.
+func ParseRouteFilesWithOptions(inputFiles []string, inputReaders []io.Reader, options ParseOptions) ([][]RouteFileEntry, []RouteError) {
+	jsonStart, openAPIStart, casePolicy := options.JSONStart, options.OpenAPIStart, options.CasePolicy
+	withReader := options.WithReader
+	if withReader == nil {
+		withReader = readFile
+	}
+
 	if len(inputFiles) != len(inputReaders) {
 		panic("Bad arguments passed to 'ParseRouteFiles': inputFiles and inputReaders must have same length")
 	}
@@ -784,7 +1312,9 @@ func ParseRouteFiles(inputFiles []string, inputReaders []io.Reader, jsonStart in
 			defer wg.Done()
 			var ent []RouteFileEntry
 			var es []RouteError
-			if i >= jsonStart {
+			if i >= openAPIStart {
+				ent, es = ParseOpenAPIFile(r, casePolicy)
+			} else if i >= jsonStart {
 				ent, es = ParseJsonRouteFile(r, casePolicy)
 			} else {
 				ent, es = ParseRouteFile(r, casePolicy)
@@ -792,6 +1322,13 @@ func ParseRouteFiles(inputFiles []string, inputReaders []io.Reader, jsonStart in
 			for j := range es {
 				es[j].Filenames = []string{inputFiles[i]}
 			}
+			if len(es) == 0 && i < openAPIStart {
+				parse := ParseRouteFile
+				if i >= jsonStart {
+					parse = ParseJsonRouteFile
+				}
+				ent, es = spliceIncludes(ent, []string{inputFiles[i]}, parse, casePolicy, withReader)
+			}
 			entriesPerFile[i] = ent
 			allErrors[i] = es
 		}()
@@ -802,6 +1339,95 @@ func ParseRouteFiles(inputFiles []string, inputReaders []io.Reader, jsonStart in
 	return entriesPerFile, flatten(allErrors)
 }
 
+func readFile(filename string, f func(io.Reader)) error {
+	inf, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer inf.Close()
+	f(inf)
+	return nil
+}
+
+// spliceIncludes replaces each include directive in entries with the entries
+// of the included file, which is in the same format as the including file. The
+// included entries are placed at the indentation of the directive, so that
+// they inherit the path prefix and tags of the surrounding routes. The entries
+// of a mounted file are placed under the route of the mount directive. Paths are
+// resolved relative to the directory of the including file. The chain gives
+// the file that the entries come from followed by the files that
+// (transitively) include it.
+func spliceIncludes(entries []RouteFileEntry, chain []string, parse func(io.Reader, CasePolicy) ([]RouteFileEntry, []RouteError), casePolicy CasePolicy, withReader func(string, func(io.Reader)) error) ([]RouteFileEntry, []RouteError) {
+	var spliced []RouteFileEntry
+	var errors []RouteError
+
+	for _, entry := range entries {
+		if entry.include == "" {
+			spliced = append(spliced, entry)
+			continue
+		}
+
+		// A mount directive places the included entries under its route.
+		indent := entry.indent
+		if entry.name != "" {
+			mounted := entry
+			mounted.include = ""
+			spliced = append(spliced, mounted)
+			indent++
+		}
+
+		filename := filepath.Clean(entry.include)
+		if !filepath.IsAbs(filename) {
+			filename = filepath.Join(filepath.Dir(chain[0]), filename)
+		}
+		if slices.ContainsFunc(chain, func(f string) bool { return filepath.Clean(f) == filename }) {
+			errors = append(errors, RouteError{
+				Kind:      IncludeCycle,
+				Line:      entry.line,
+				Col:       -1,
+				Filenames: chain,
+				Include:   filename,
+			})
+			continue
+		}
+
+		includedChain := append([]string{filename}, chain...)
+		var included []RouteFileEntry
+		var es []RouteError
+		err := withReader(filename, func(r io.Reader) {
+			included, es = parse(r, casePolicy)
+		})
+		if err != nil {
+			errors = append(errors, RouteError{
+				Kind:      IOError,
+				Line:      entry.line,
+				Col:       -1,
+				IOError:   err,
+				Filenames: chain,
+			})
+			continue
+		}
+		if len(es) == 0 {
+			included, es = spliceIncludes(included, includedChain, parse, casePolicy, withReader)
+		} else {
+			for j := range es {
+				es[j].Filenames = includedChain
+			}
+		}
+		errors = append(errors, es...)
+
+		for _, inc := range included {
+			inc.indent += indent
+			if inc.filename == "" {
+				inc.filename = filename
+			}
+			spliced = append(spliced, inc)
+		}
+	}
+
+	return spliced, errors
+}
+
 func stripLeadingWhitespace(line string) string {
 	for i := 0; i < len(line); {
 		rn, sz := utf8.DecodeRuneInString(line[i:])
@@ -933,9 +1559,9 @@ func getTags(routeString string) (map[string]struct{}, int) {
 		}
 	}
 
-	if ti < 0 {
+	if ti < 0 || startsOptionalGroup(routeString, ti) {
 		// False alarm - the closing ']' made it look as if there were tags, but
-		// there weren't.
+		// there weren't (or it closed an optional group).
 		return map[string]struct{}{}, len(routeString)
 	}
 
@@ -950,6 +1576,115 @@ func getTags(routeString string) (map[string]struct{}, int) {
 	return tags, ti
 }
 
+// splitTagModifiers separates the tags in a list of tags that are prefixed with
+// '.' (applying only to the route itself) or '-' (removing an inherited tag)
+// from the tags that are inherited as usual. It returns false if a prefixed tag
+// is empty.
+func splitTagModifiers(allTags map[string]struct{}) (map[string]struct{}, map[string]struct{}, map[string]struct{}, bool) {
+	tags := make(map[string]struct{}, len(allTags))
+	var localTags, removedTags map[string]struct{}
+	ok := true
+	for t := range allTags {
+		if t[0] != '.' && t[0] != '-' {
+			tags[t] = struct{}{}
+			continue
+		}
+		if len(t) == 1 {
+			ok = false
+		} else if t[0] == '.' {
+			if localTags == nil {
+				localTags = make(map[string]struct{})
+			}
+			localTags[t[1:]] = struct{}{}
+		} else {
+			if removedTags == nil {
+				removedTags = make(map[string]struct{})
+			}
+			removedTags[t[1:]] = struct{}{}
+		}
+	}
+	return tags, localTags, removedTags, ok
+}
+
+// splitMetadata separates the 'key=value' items of metadata in a list of tags
+// from the tags proper. It returns false if a key is empty or is given more
+// than once.
+func splitMetadata(tagsAndMeta map[string]struct{}) (map[string]struct{}, map[string]string, bool) {
+	tags := make(map[string]struct{}, len(tagsAndMeta))
+	var meta map[string]string
+	ok := true
+	for _, t := range stringSetToList(tagsAndMeta) {
+		key, value, isMeta := strings.Cut(t, "=")
+		if !isMeta {
+			tags[t] = struct{}{}
+			continue
+		}
+		if _, dup := meta[key]; dup || key == "" {
+			ok = false
+		}
+		if meta == nil {
+			meta = make(map[string]string)
+		}
+		meta[key] = value
+	}
+	return tags, meta, ok
+}
+
+// getAnnotation returns the text following the marker ('^' for a priority or
+// '@' for a list of hosts) of an annotation at the start of the given string.
+// A marker starts an annotation only if the annotation is followed by
+// whitespace and then by the route pattern.
+func getAnnotation(s string, marker byte) (string, bool) {
+	if len(s) == 0 || s[0] != marker {
+		return "", false
+	}
+	for i, c := range s {
+		if unicode.IsSpace(c) {
+			return s[1:i], !isBlank(s[i:])
+		}
+	}
+	return "", false
+}
+
+// parseHosts parses a comma-separated list of host patterns. Host names are
+// case-insensitive, so the patterns are normalized to lower case.
+func parseHosts(s string) ([]string, bool) {
+	var hosts []string
+	for _, h := range strings.Split(s, ",") {
+		h = strings.ToLower(h)
+		if !validHostPattern(h) {
+			return nil, false
+		}
+		if !slices.Contains(hosts, h) {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts, true
+}
+
+// validHostPattern checks that a host pattern is a sequence of dot-separated
+// labels containing only lower case ASCII letters, digits and '-', except that
+// the first label may be '*' (matching any single label).
+func validHostPattern(h string) bool {
+	if rest, ok := strings.CutPrefix(h, "*."); ok {
+		h = rest
+	}
+	if h == "" {
+		return false
+	}
+	for _, label := range strings.Split(h, ".") {
+		if label == "" {
+			return false
+		}
+		for _, c := range label {
+			if !((c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
 func isDot(input string) bool {
 	for i, c := range input {
 		if unicode.IsSpace(c) {
@@ -978,6 +1713,10 @@ func isBlank(s string) bool {
 	return true
 }
 
+func hasNonLowerCase(s string) bool {
+	return containsNonLowerCase(s) != -1
+}
+
 func containsNonLowerCase(s string) int {
 	i := 0
 	for {
@@ -1024,8 +1763,16 @@ func debugPrintParsedRoute(route []routeElement) string {
 			sb.WriteString(elem.value)
 			sb.WriteRune('\'')
 		case parameter:
-			sb.WriteString("${")
+			sb.WriteString("$" + elem.paramType + "{")
 			sb.WriteString(elem.value)
+			if elem.values != nil {
+				sb.WriteString(" in ")
+				sb.WriteString(strings.Join(elem.values, ","))
+			}
+			if elem.regexp != "" {
+				sb.WriteString(" ~ ")
+				sb.WriteString(elem.regexp)
+			}
 			sb.WriteRune('}')
 		case integerParameter:
 			sb.WriteString("$#{")
@@ -1041,6 +1788,12 @@ func debugPrintParsedRoute(route []routeElement) string {
 			sb.WriteString("**")
 		case noTrailingSlash:
 			sb.WriteString("!/")
+		case optionalStart:
+			sb.WriteRune('[')
+		case optionalEnd:
+			sb.WriteRune(']')
+		case alternation:
+			sb.WriteString("(" + strings.Join(elem.values, "|") + ")")
 		}
 	}
 
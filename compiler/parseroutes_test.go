@@ -2,8 +2,13 @@ package compiler
 
 import (
 	"bytes"
+	"fmt"
+	"io"
 	"math/rand"
+	"os"
+	"path/filepath"
 	"reflect"
+	"slices"
 	"sort"
 	"strings"
 	"testing"
@@ -31,6 +36,14 @@ func TestParseRoutes(t *testing.T) {
 	testParseRoute(t, "/foo/:#{bar}/amp", "/ 'foo' / $#{bar} / 'amp'")
 	testParseRoute(t, "/foo/:#bar/amp", "/ 'foo' / $#{bar} / 'amp'")
 	testParseRoute(t, "/foo/:\\#bar/amp", "/ 'foo' / ${#bar} / 'amp'")
+	testParseRoute(t, "/foo/:{lang in en,fr}/amp", "/ 'foo' / ${lang in en,fr} / 'amp'")
+	testParseRoute(t, "/foo/:{ lang  in en , fr }", "/ 'foo' / ${lang in en,fr}")
+	testParseRoute(t, "/foo/:{id ~ [0-9a-f]+}/amp", "/ 'foo' / ${id ~ [0-9a-f]+} / 'amp'")
+	testParseRoute(t, "/foo/:{id ~ a\\.b}", "/ 'foo' / ${id ~ a\\.b}")
+	testParseRoute(t, "/foo/:uuid:id/amp", "/ 'foo' / $uuid{id} / 'amp'")
+	testParseRoute(t, "/foo/:date:{the day}", "/ 'foo' / $date{the day}")
+	testParseRoute(t, "/foo/:nope:id", "/ 'foo' / ${nope} ${id}")
+	testParseRoute(t, "/foo/:uuid:", "/ 'foo' / ${uuid} ':'")
 	testParseRoute(t, "/foo/:", "/ 'foo' / ':'")
 	testParseRoute(t, "/foo/:#", "/ 'foo' / ':#'")
 	testParseRoute(t, "/foo/\\[", "/ 'foo' / '['")
@@ -43,6 +56,55 @@ func TestParseRoutes(t *testing.T) {
 	testParseRoute(t, "/foo/\\*boo\\*", "/ 'foo' / '*boo*'")
 	testParseRoute(t, "/foo/**", "/ 'foo' / **")
 	testParseRoute(t, "/foo/\\*", "/ 'foo' / '*'")
+	testParseRoute(t, "/posts[/page/:#n]", "/ 'posts' [ / 'page' / $#{n} ]")
+	testParseRoute(t, "/a[/b[/:c]]/d", "/ 'a' [ / 'b' [ / ${c} ] ] / 'd'")
+	testParseRoute(t, "/foo[bar]", "/ 'foo[bar]'")
+	testParseRoute(t, "/(photos|images)/:id", "/ (photos|images) / ${id}")
+	testParseRoute(t, "/foo(a|b|c)bar", "/ 'foo' (a|b|c) 'bar'")
+	testParseRoute(t, "/foo(bar)", "/ 'foo(bar)'")
+	testParseRoute(t, "/foo(a/b|c)", "/ 'foo(a' / 'b|c)'")
+	testParseRoute(t, "/foo\\(a|b)", "/ 'foo(a|b)'")
+}
+
+func TestParseRouteIllegalAlternation(t *testing.T) {
+	for _, route := range []string{"/foo/(a|)", "/foo/(a|a)", "/foo/(|)"} {
+		elems := parseRoute(route)
+		if len(elems) != 5 || elems[3].kind != illegalAlternation {
+			t.Errorf("Unexpected result for %v: %+v", route, elems)
+		}
+	}
+	if errs := validateRouteElems(0, 0, parseRoute("/foo[/(a|b)]")); !slices.Contains(errs, BadAlternation) {
+		t.Errorf("Expected BadAlternation for alternation in optional group, got %+v\n", errs)
+	}
+}
+
+func TestValidateOptionalGroups(t *testing.T) {
+	for _, route := range []string{"/posts[/page/:#n]", "/a[/b[/:c]]/d", "/a[/b]/c!/"} {
+		if errs := validateRouteElems(0, 0, parseRoute(route)); len(errs) != 0 {
+			t.Errorf("Unexpected errors for %v: %+v\n", route, errs)
+		}
+	}
+	for _, route := range []string{"[/posts]", "/posts[/page", "/posts/[/page]", "/posts[/page/]", "/posts[/page!/]"} {
+		errs := validateRouteElems(0, 0, parseRoute(route))
+		if !slices.Contains(errs, BadOptionalGroup) {
+			t.Errorf("Expected BadOptionalGroup for %v, got %+v\n", route, errs)
+		}
+	}
+}
+
+func TestParseRouteFileDuplicateParameterNameInOptionalGroup(t *testing.T) {
+	const routeFile = "a /a[/:x]/:x\nb /b[/:x[/:#x]]\nc /c[/:x]/d[/:y]\n"
+
+	_, errs := ParseRouteFile(strings.NewReader(routeFile), DisallowUpperCase)
+	if len(errs) != 2 {
+		t.Fatalf("Expecting 2 errors, got %v: %+v\n", len(errs), errs)
+	}
+	if errs[0].Kind != DuplicateParameterName || errs[0].Line != 1 {
+		t.Errorf("Expected DuplicateParameterName at line 1, got %+v\n", errs[0])
+	}
+	if errs[1].Kind != DuplicateParameterName || errs[1].Line != 2 {
+		t.Errorf("Expected DuplicateParameterName at line 2, got %+v\n", errs[1])
+	}
 }
 
 func TestParseRouteIllegalBackslash(t *testing.T) {
@@ -52,6 +114,32 @@ func TestParseRouteIllegalBackslash(t *testing.T) {
 	}
 }
 
+func TestParseRouteIllegalEnumeratedParam(t *testing.T) {
+	elems := parseRoute("/foo/:{lang in en,}")
+	if len(elems) != 5 || elems[3].kind != illegalEnumeratedParam {
+		t.Fatalf("Unexpected result %+v", elems)
+	}
+	elems = parseRoute("/foo/:#{n in 1,2}")
+	if len(elems) != 5 || elems[3].kind != illegalConstrainedKind {
+		t.Fatalf("Unexpected result %+v", elems)
+	}
+}
+
+func TestParseRouteIllegalParamRegexp(t *testing.T) {
+	elems := parseRoute("/foo/:{id ~ \\d+}")
+	if len(elems) != 5 || elems[3].kind != illegalParamRegexp {
+		t.Fatalf("Unexpected result %+v", elems)
+	}
+	elems = parseRoute("/foo/:{id ~ [a-z]*}")
+	if len(elems) != 5 || elems[3].kind != illegalParamRegexpMatch {
+		t.Fatalf("Unexpected result %+v", elems)
+	}
+	elems = parseRoute("/foo/:**{id ~ [a-z]+}")
+	if len(elems) != 5 || elems[3].kind != illegalConstrainedKind {
+		t.Fatalf("Unexpected result %+v", elems)
+	}
+}
+
 func testParseRoute(t *testing.T, route, expectedOutput string) {
 	output := debugPrintParsedRoute(parseRoute(route))
 	if output != expectedOutput {
@@ -256,6 +344,39 @@ func TestParseRouteFileDontAllowUpperCase(t *testing.T) {
 	}
 }
 
+func TestParseRouteFileEnumeratedParameterErrors(t *testing.T) {
+	const routeFile = "a /a/:{x in foo,b/r}\nb /b/:**{x in foo}\nc /c/:{x in foo,Bar}"
+
+	_, errs := ParseRouteFile(strings.NewReader(routeFile), DisallowUpperCase)
+	if len(errs) != 3 {
+		t.Fatalf("Expecting 3 errors, got %v: %+v\n", len(errs), errs)
+	}
+	if errs[0].Kind != BadEnumeratedParameter || errs[0].Line != 1 || errs[0].Col != 5 {
+		t.Errorf("Expected BadEnumeratedParameter at line 1 col 5, got %+v\n", errs[0])
+	}
+	if errs[1].Kind != ConstrainedParameterMustBeString || errs[1].Line != 2 {
+		t.Errorf("Expected ConstrainedParameterMustBeString at line 2, got %+v\n", errs[1])
+	}
+	if errs[2].Kind != UpperCaseCharInRoute || errs[2].Line != 3 {
+		t.Errorf("Expected UpperCaseCharInRoute at line 3, got %+v\n", errs[2])
+	}
+}
+
+func TestParseRouteFileDuplicateEnumeratedParameterName(t *testing.T) {
+	const routeFile = "a /:{lang in en,fr}/:lang\nb /b/:{x in foo}/:{x in bar}\nc /c/:{lang in en,fr}/:page\n"
+
+	_, errs := ParseRouteFile(strings.NewReader(routeFile), DisallowUpperCase)
+	if len(errs) != 2 {
+		t.Fatalf("Expecting 2 errors, got %v: %+v\n", len(errs), errs)
+	}
+	if errs[0].Kind != DuplicateParameterName || errs[0].Line != 1 {
+		t.Errorf("Expected DuplicateParameterName at line 1, got %+v\n", errs[0])
+	}
+	if errs[1].Kind != DuplicateParameterName || errs[1].Line != 2 {
+		t.Errorf("Expected DuplicateParameterName at line 2, got %+v\n", errs[1])
+	}
+}
+
 func TestParseRouteFileDontAllowUnderintentingNotFooledByBlankLines(t *testing.T) {
 	const routeFile = "   \n    \na /foo\nb /bar\n"
 
@@ -314,6 +435,238 @@ func TestParseRouteFileEmptyTags(t *testing.T) {
 	}
 }
 
+func TestParseRouteFilePriority(t *testing.T) {
+	const routeFile = "route1 ^1 /foo/new\nroute2 [GET,POST]  ^-2 /foo/:id [tag]\nroute3 /foo/bar\n  route4 \\^1 [tag]\n"
+
+	entries, errs := ParseRouteFile(strings.NewReader(routeFile), DisallowUpperCase)
+	if len(errs) != 0 {
+		t.Fatalf("Expecting 0 errors, got %v: %+v\n", len(errs), errs)
+	}
+	if len(entries) != 4 {
+		t.Fatalf("Expecting 4 entries, got %+v\n", entries)
+	}
+	for i, expected := range []int{1, -2, 0, 0} {
+		if entries[i].priority != expected {
+			t.Errorf("Expecting priority %v for entry %v, got %v\n", expected, i, entries[i].priority)
+		}
+	}
+	if debugPrintParsedRoute(entries[1].pattern) != "/ 'foo' / ${id}" || len(entries[1].methods) != 2 || len(entries[1].tags) != 1 {
+		t.Errorf("Unexpected parse of route with methods, priority and tags: %+v\n", entries[1])
+	}
+	if debugPrintParsedRoute(entries[3].pattern) != "'^1'" || len(entries[3].tags) != 1 {
+		t.Errorf("Unexpected parse of route with escaped '^': %+v\n", entries[3])
+	}
+
+	_, errs = ParseRouteFile(strings.NewReader("route1 ^high /foo\nroute2 ^ /bar\n"), DisallowUpperCase)
+	if len(errs) != 2 || errs[0].Kind != BadPriority || errs[1].Kind != BadPriority {
+		t.Errorf("Expecting two 'BadPriority' errors, got %+v\n", errs)
+	}
+}
+
+func TestParseRouteFileMetadata(t *testing.T) {
+	entries, errs := ParseRouteFile(strings.NewReader("route1 /foo [api, auth=admin, x=a=b, empty=]\n"), DisallowUpperCase)
+	if len(errs) != 0 {
+		t.Fatalf("Expecting 0 errors, got %+v\n", errs)
+	}
+	if len(entries) != 1 || !reflect.DeepEqual(entries[0].tags, map[string]struct{}{"api": {}}) || !reflect.DeepEqual(entries[0].meta, map[string]string{"auth": "admin", "x": "a=b", "empty": ""}) {
+		t.Errorf("Unexpected tags and metadata %+v\n", entries)
+	}
+
+	_, errs = ParseRouteFile(strings.NewReader("route1 /foo [=admin]\nroute2 /bar [auth=a, auth=b]\n"), DisallowUpperCase)
+	if len(errs) != 2 || errs[0].Kind != BadMetadata || errs[1].Kind != BadMetadata {
+		t.Errorf("Expecting two 'BadMetadata' errors, got %+v\n", errs)
+	}
+}
+
+func TestParseRouteFileTagModifiers(t *testing.T) {
+	entries, errs := ParseRouteFile(strings.NewReader("route1 /foo [api, .public, -auth, -admin, .x=y]\n"), DisallowUpperCase)
+	if len(errs) != 0 {
+		t.Fatalf("Expecting 0 errors, got %+v\n", errs)
+	}
+	e := entries[0]
+	if !reflect.DeepEqual(e.tags, map[string]struct{}{"api": {}}) || !reflect.DeepEqual(e.localTags, map[string]struct{}{"public": {}, "x=y": {}}) || !reflect.DeepEqual(e.removedTags, map[string]struct{}{"auth": {}, "admin": {}}) || e.meta != nil {
+		t.Errorf("Unexpected tags %+v\n", e)
+	}
+
+	_, errs = ParseRouteFile(strings.NewReader("route1 /foo [.]\nroute2 /bar [api, -]\n"), DisallowUpperCase)
+	if len(errs) != 2 || errs[0].Kind != BadTagModifier || errs[1].Kind != BadTagModifier {
+		t.Errorf("Expecting two 'BadTagModifier' errors, got %+v\n", errs)
+	}
+}
+
+func TestParseRouteFileHosts(t *testing.T) {
+	const routeFile = "route1 @api.example.com /foo\nroute2 [GET,POST] ^1 @*.Example.com,example.com,example.com /bar [tag]\n  route3 \\@foo\n"
+
+	entries, errs := ParseRouteFile(strings.NewReader(routeFile), DisallowUpperCase)
+	if len(errs) != 0 {
+		t.Fatalf("Expecting 0 errors, got %+v\n", errs)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("Expecting 3 entries, got %+v\n", entries)
+	}
+	for i, expected := range [][]string{{"api.example.com"}, {"*.example.com", "example.com"}, nil} {
+		if !slices.Equal(entries[i].hosts, expected) {
+			t.Errorf("Expecting hosts %v for entry %v, got %v\n", expected, i, entries[i].hosts)
+		}
+	}
+	if debugPrintParsedRoute(entries[1].pattern) != "/ 'bar'" || entries[1].priority != 1 || len(entries[1].methods) != 2 || len(entries[1].tags) != 1 {
+		t.Errorf("Unexpected parse of route with methods, priority, hosts and tags: %+v\n", entries[1])
+	}
+	if debugPrintParsedRoute(entries[2].pattern) != "'@foo'" {
+		t.Errorf("Unexpected parse of route with escaped '@': %+v\n", entries[2])
+	}
+
+	_, errs = ParseRouteFile(strings.NewReader("route1 @foo..com /foo\nroute2 @foo.*.com /bar\nroute3 @foo.com, /amp\nroute4 @foo.com:80 /baz\n"), DisallowUpperCase)
+	if len(errs) != 4 || slices.ContainsFunc(errs, func(e RouteError) bool { return e.Kind != BadHost }) {
+		t.Errorf("Expecting four 'BadHost' errors, got %+v\n", errs)
+	}
+}
+
+func TestParseRouteFileInclude(t *testing.T) {
+	const routeFile = "root /\n  include  sub/routes \n  include\n"
+
+	entries, errs := ParseRouteFile(strings.NewReader(routeFile), DisallowUpperCase)
+	if len(errs) != 1 || errs[0].Kind != MissingNameOrRoute {
+		t.Fatalf("Expecting one 'MissingNameOrRoute' error for 'include' with no path, got %+v\n", errs)
+	}
+	if len(entries) != 2 || entries[1].include != "sub/routes" || entries[1].indent != 2 || entries[0].terminal {
+		t.Fatalf("Unexpected entries %+v\n", entries)
+	}
+
+	_, errs = ParseRouteFile(strings.NewReader("include foo\n  bar /bar\n  .\n"), DisallowUpperCase)
+	if len(errs) != 2 || errs[0].Kind != IndentUnderInclude || errs[1].Kind != MisplacedDot {
+		t.Fatalf("Expecting 'IndentUnderInclude' and 'MisplacedDot' errors, got %+v\n", errs)
+	}
+}
+
+func TestParseRouteFileMount(t *testing.T) {
+	const routeFile = "mount managers [GET,POST] /managers [managers]  from  managers.routes\nmount from /from from from\n"
+
+	entries, errs := ParseRouteFile(strings.NewReader(routeFile), DisallowUpperCase)
+	if len(errs) != 0 {
+		t.Fatalf("Expecting 0 errors, got %+v\n", errs)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expecting 2 entries, got %+v\n", entries)
+	}
+	e := entries[0]
+	if e.name != "managers" || debugPrintParsedRoute(e.pattern) != "/ 'managers'" || len(e.methods) != 2 || len(e.tags) != 1 || e.include != "managers.routes" || e.terminal {
+		t.Errorf("Unexpected mount entry %+v\n", e)
+	}
+	e = entries[1]
+	if e.name != "from" || debugPrintParsedRoute(e.pattern) != "/ 'from'" || e.include != "from" {
+		t.Errorf("Unexpected mount entry %+v\n", e)
+	}
+
+	_, errs = ParseRouteFile(strings.NewReader("mount managers /managers from x\n  foo /foo\nmount managers from x\n"), DisallowUpperCase)
+	if len(errs) != 2 || errs[0].Kind != IndentUnderInclude || errs[1].Kind != MissingNameOrRoute {
+		t.Fatalf("Expecting 'IndentUnderInclude' and 'MissingNameOrRoute' errors, got %+v\n", errs)
+	}
+}
+
+func TestParseRouteFiles(t *testing.T) {
+	entries, errs := ParseRouteFiles(
+		[]string{"main", "main.json"},
+		[]io.Reader{
+			strings.NewReader("foo /foo\n"),
+			strings.NewReader(`[{"name": "bar", "pattern": "/bar"}]`),
+		},
+		1, DisallowUpperCase,
+	)
+	if len(errs) != 0 {
+		t.Fatalf("Unexpected errors %+v\n", errs)
+	}
+	if len(entries) != 2 || len(entries[0]) != 1 || entries[0][0].name != "foo" || len(entries[1]) != 1 || entries[1][0].name != "bar" {
+		t.Errorf("Unexpected entries %+v\n", entries)
+	}
+}
+
+func TestParseRouteFilesReadsIncludesFromFileSystem(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "sub"), []byte("sub /sub\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, errs := ParseRouteFiles([]string{filepath.Join(dir, "main")}, []io.Reader{strings.NewReader("include sub\n")}, 1, DisallowUpperCase)
+	if len(errs) != 0 {
+		t.Fatalf("Unexpected errors %+v\n", errs)
+	}
+	if len(entries) != 1 || len(entries[0]) != 1 || entries[0][0].name != "sub" {
+		t.Errorf("Unexpected entries %+v\n", entries)
+	}
+}
+
+func TestParseRouteFilesSplicesIncludes(t *testing.T) {
+	files := map[string]string{
+		"dir/sub":       "  a /a [x]\n    b /b\n  include ../other\n",
+		"other":         "c /c\n",
+		"dir/cycle":     "include ../cycle\n",
+		"cycle":         "d /d\ninclude dir/cycle\n",
+		"dir/bad-sub":   "bad\n",
+		"json/sub.json": `[{"name": "e", "terminal": true, "pattern": "/e"}]`,
+		"mounted":       "f /f\n  g /g\n",
+		"mounted.json":  `[{"name": "f", "pattern": "/f"}, [{"name": "g", "terminal": true, "pattern": "/g"}]]`,
+	}
+	withReader := func(filename string, f func(io.Reader)) error {
+		contents, ok := files[filename]
+		if !ok {
+			return fmt.Errorf("no such file %v", filename)
+		}
+		f(strings.NewReader(contents))
+		return nil
+	}
+
+	entries, errs := ParseRouteFilesWithOptions(
+		[]string{"main", "json/main.json"},
+		[]io.Reader{
+			strings.NewReader("root /\n    include dir/sub\nafter /after\nmount m /m from mounted\n"),
+			strings.NewReader(`[{"name": "root", "pattern": "/"}, [{"include": "sub.json"}], {"name": "m", "pattern": "/m", "mount": "../mounted.json"}]`),
+		},
+		ParseOptions{JSONStart: 1, OpenAPIStart: 2, CasePolicy: DisallowUpperCase, WithReader: withReader},
+	)
+	if len(errs) != 0 {
+		t.Fatalf("Unexpected errors %+v\n", errs)
+	}
+	var got []string
+	for _, file := range entries {
+		for _, e := range file {
+			got = append(got, fmt.Sprintf("%v %v %v %v", e.indent, e.name, e.filename, e.line))
+		}
+	}
+	expected := []string{
+		"0 root  1",
+		"4 a dir/sub 1",
+		"8 b dir/sub 2",
+		"4 c other 1",
+		"0 after  3",
+		"0 m  4",
+		"1 f mounted 1",
+		"3 g mounted 2",
+		"0 root  1",
+		"1 e json/sub.json 1",
+		"0 m  1",
+		"1 f mounted.json 1",
+		"2 g mounted.json 1",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Unexpected spliced entries %+v\n", got)
+	}
+
+	_, errs = ParseRouteFilesWithOptions([]string{"main"}, []io.Reader{strings.NewReader("include dir/cycle\ninclude dir/bad-sub\ninclude missing\n")}, ParseOptions{JSONStart: 1, OpenAPIStart: 1, CasePolicy: DisallowUpperCase, WithReader: withReader})
+	if len(errs) != 3 {
+		t.Fatalf("Expecting 3 errors, got %+v\n", errs)
+	}
+	if errs[0].Kind != IncludeCycle || errs[0].Include != "dir/cycle" || !reflect.DeepEqual(errs[0].Filenames, []string{"cycle", "dir/cycle", "main"}) {
+		t.Errorf("Unexpected include cycle error %+v\n", errs[0])
+	}
+	if errs[1].Kind != MissingNameOrRoute || !reflect.DeepEqual(errs[1].Filenames, []string{"dir/bad-sub", "main"}) {
+		t.Errorf("Unexpected error in included file %+v\n", errs[1])
+	}
+	if errs[2].Kind != IOError || errs[2].Line != 3 || !reflect.DeepEqual(errs[2].Filenames, []string{"main"}) {
+		t.Errorf("Unexpected error for missing file %+v\n", errs[2])
+	}
+}
+
 func TestParseRouteFileMethodParsing(t *testing.T) {
 	{
 		r, errs := ParseRouteFile(strings.NewReader("foo [GET,POST,PUT] /"), DisallowUpperCase)
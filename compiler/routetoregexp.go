@@ -2,7 +2,11 @@ package compiler
 
 import (
 	"fmt"
+	"maps"
+	"math"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"unicode/utf8"
 )
@@ -11,10 +15,13 @@ type RouteInfo struct {
 	Name     string
 	Line     int
 	Filename string
-	Tags     map[string]struct{}
+	Tags     map[string]struct{} // including tags inherited from parents
+	Meta     map[string]string   // including metadata inherited from parents
+	Hosts    []string            // the hosts the route is restricted to (any host if empty), including hosts inherited from parents
 	Depth    int
 	Terminal bool
 	Methods  map[string]struct{}
+	Priority int
 }
 
 type CompiledRoute struct {
@@ -39,6 +46,16 @@ type routeFamily struct {
 	nonparamGroupNumbers []int
 	nLevels              int
 	matchRegexp          string
+	methodFamilies       []methodFamily
+}
+
+// A methodFamily is the subset of a family's members that accept a given
+// method. These are needed because routes in the same family may overlap so
+// long as their methods don't, in which case the family's match regexp can
+// only ever identify the first of the overlapping members.
+type methodFamily struct {
+	method string
+	family routeFamily
 }
 
 type routeGroupMember struct {
@@ -51,6 +68,15 @@ type routeRegexps struct {
 	constantPortionRegexp  string
 	constantPortionNGroups int
 	families               []routeFamily
+	hosts                  []hostRouteRegexps
+}
+
+// A hostRouteRegexps gives the regexps for the routes that accept a given host
+// pattern, or for the routes that accept any host if the pattern is empty.
+// These are needed because routes may overlap so long as their hosts don't.
+type hostRouteRegexps struct {
+	host string
+	routeRegexps
 }
 
 type RouteWithParents struct {
@@ -76,6 +102,10 @@ func routeToRegexps(elems []routeElement) RouteRegexp {
 	paramGroupNumbers := make(map[string]int)
 	groupI := 1
 	constantPortionNGroups := 0
+	// The constant parts of optional groups aren't included in the constant
+	// portion, as a URL's constant portion would otherwise depend on whether the
+	// groups are present.
+	optionalDepth := 0
 	for i, elem := range elems {
 		switch elem.kind {
 		case slash:
@@ -83,6 +113,10 @@ func routeToRegexps(elems []routeElement) RouteRegexp {
 				continue
 			}
 			re.WriteString("\\/+")
+			if optionalDepth > 0 {
+				cp.WriteString("\\/+")
+				continue
+			}
 			cp.WriteString("(\\/)\\/*")
 			constantPortionNGroups++
 			constantPortion.WriteRune('/')
@@ -92,6 +126,10 @@ func routeToRegexps(elems []routeElement) RouteRegexp {
 			constishSuffix.WriteRune('/')
 		case constant:
 			regexEscape(elem.value, &re)
+			if optionalDepth > 0 {
+				regexEscape(elem.value, &cp)
+				continue
+			}
 
 			constantPortion.WriteString(elem.value)
 			constantPortionI++
@@ -110,12 +148,41 @@ func routeToRegexps(elems []routeElement) RouteRegexp {
 			}
 			constishSuffix.WriteString(elem.value)
 		case parameter:
-			re.WriteString("([^\\/?#]+)")
-			cp.WriteString("[^\\/?#]+")
+			if elem.values != nil {
+				re.WriteByte('(')
+				writeAlternation(elem.values, &re)
+				re.WriteByte(')')
+				cp.WriteString("(?:")
+				writeAlternation(elem.values, &cp)
+				cp.WriteByte(')')
+			} else if elem.paramType != "" {
+				re.WriteString("(" + paramTypes[elem.paramType].regexp + ")")
+				cp.WriteString("(?:" + paramTypes[elem.paramType].regexp + ")")
+			} else if elem.regexp != "" {
+				// The regexp has already been checked by the parser.
+				translated, _, _ := translateParamRegexp(elem.regexp)
+				re.WriteString("(" + translated + ")")
+				cp.WriteString("(?:" + translated + ")")
+			} else {
+				re.WriteString("([^\\/?#]+)")
+				cp.WriteString("[^\\/?#]+")
+			}
 			paramGroupNumbers[elem.value] = groupI
 			groupI++
 			inConstishPrefix = false
 			constishSuffix.Reset()
+		case alternation:
+			// Routes are expanded so that each alternative of an alternation is
+			// a constant (see expandAlternationRoutes), so this is only used for
+			// templates.
+			re.WriteString("(?:")
+			writeAlternation(elem.values, &re)
+			re.WriteByte(')')
+			cp.WriteString("(?:")
+			writeAlternation(elem.values, &cp)
+			cp.WriteByte(')')
+			inConstishPrefix = false
+			constishSuffix.Reset()
 		case integerParameter:
 			re.WriteString("(-?[0-9]+)")
 			cp.WriteString("-?[0-9]+")
@@ -166,6 +233,17 @@ func routeToRegexps(elems []routeElement) RouteRegexp {
 			if i+1 != len(elems) {
 				panic("What's a 'no trailing slash' element doing here?!")
 			}
+		case optionalStart:
+			re.WriteString("(?:")
+			cp.WriteString("(?:")
+			optionalDepth++
+			inConstishPrefix = false
+			constishSuffix.Reset()
+		case optionalEnd:
+			re.WriteString(")?")
+			cp.WriteString(")?")
+			optionalDepth--
+			constishSuffix.Reset()
 		}
 	}
 
@@ -201,6 +279,15 @@ func routeToRegexps(elems []routeElement) RouteRegexp {
 	}
 }
 
+func writeAlternation(values []string, sb *strings.Builder) {
+	for i, v := range values {
+		if i != 0 {
+			sb.WriteByte('|')
+		}
+		regexEscape(v, sb)
+	}
+}
+
 func regexEscape(str string, sb *strings.Builder) {
 	for i := range str {
 		c := str[i]
@@ -234,9 +321,16 @@ func ProcessRouteFiles(files [][]RouteFileEntry, filenames []string, nameSeparat
 		name      string
 		baseRoute []routeElement
 		indent    int
+		tags      map[string]struct{} // the tags inherited by children
+		meta      map[string]string
+		hosts     []string
+		// the number of routes that the route expands to (see
+		// expandAlternationRoutes), counting the alternations of its parents
+		expansions int
 	}
 
 	levels := make([]level, 0)
+	tooManyExpansions := false
 
 	for fi, file := range files {
 		for ei, entry := range file {
@@ -263,8 +357,56 @@ func ProcessRouteFiles(files [][]RouteFileEntry, filenames []string, nameSeparat
 			nameB.WriteString(entry.name)
 
 			name := nameB.String()
+			filename := filenames[fi]
+			if entry.filename != "" {
+				filename = entry.filename
+			}
 			if entry.terminal {
-				terminalLines[name] = append(terminalLines[name], tne{filenames[fi], entry.line, fi, ei})
+				terminalLines[name] = append(terminalLines[name], tne{filename, entry.line, fi, ei})
+			}
+
+			// Tags are inherited from parents, except for tags that apply only
+			// to the parent and tags that the child removes. Metadata is
+			// inherited from parents, with children overriding the values of
+			// their parents. A route's hosts replace those of its parents.
+			inheritedTags := make(map[string]struct{})
+			meta := make(map[string]string)
+			if len(levels) > 0 {
+				maps.Copy(inheritedTags, levels[len(levels)-1].tags)
+				maps.Copy(meta, levels[len(levels)-1].meta)
+			}
+			for t := range entry.removedTags {
+				delete(inheritedTags, t)
+			}
+			maps.Copy(inheritedTags, entry.tags)
+			tags := maps.Clone(inheritedTags)
+			maps.Copy(tags, entry.localTags)
+			maps.Copy(meta, entry.meta)
+			hosts := entry.hosts
+			if len(hosts) == 0 && len(levels) > 0 {
+				hosts = levels[len(levels)-1].hosts
+			}
+
+			parentExpansions := 1
+			if len(levels) > 0 {
+				parentExpansions = levels[len(levels)-1].expansions
+			}
+			expansions := parentExpansions
+			for _, e := range entry.pattern {
+				if e.kind == alternation {
+					expansions *= len(e.values)
+				}
+			}
+			// Only report the first route in a subtree that exceeds the limit,
+			// as its children also exceed it.
+			if expansions > MaxAlternationExpansions && parentExpansions <= MaxAlternationExpansions {
+				tooManyExpansions = true
+				errors = append(errors, RouteError{
+					Kind:      TooManyAlternationExpansions,
+					Line:      entry.line,
+					Col:       -1,
+					Filenames: []string{filename},
+				})
 			}
 
 			cri := routeToRegexps(entry.pattern)
@@ -272,15 +414,18 @@ func ProcessRouteFiles(files [][]RouteFileEntry, filenames []string, nameSeparat
 				Info: RouteInfo{Name: name,
 					Depth:    len(levels),
 					Line:     entry.line,
-					Filename: filenames[fi],
-					Tags:     entry.tags,
+					Filename: filename,
+					Tags:     tags,
+					Meta:     meta,
+					Hosts:    hosts,
 					Methods:  entry.methods,
 					Terminal: entry.terminal,
+					Priority: entry.priority,
 				},
 				Compiled: cri,
 			}
 
-			levels = append(levels, level{entry.name, entry.pattern, entry.indent})
+			levels = append(levels, level{entry.name, entry.pattern, entry.indent, inheritedTags, meta, hosts, min(expansions, MaxAlternationExpansions+1)})
 
 			routes = append(routes, ri)
 		}
@@ -288,7 +433,42 @@ func ProcessRouteFiles(files [][]RouteFileEntry, filenames []string, nameSeparat
 
 	errors = append(errors, checkNonadjacentNamesakes(terminalLines, linesWithEntries)...)
 
-	return routes, errors
+	if tooManyExpansions {
+		return routes, errors
+	}
+	return expandAlternationRoutes(routes), errors
+}
+
+// expandAlternationRoutes replaces each route containing an alternation with a
+// route for each of the alternatives, in which the alternation is replaced by
+// a constant. The children of the route are duplicated for each alternative.
+// Each alternative thus gets its own constant portion, and routes can be
+// grouped by their constish prefixes and suffixes as usual.
+func expandAlternationRoutes(routes []CompiledRoute) []CompiledRoute {
+	var expanded []CompiledRoute
+	for i := 0; i < len(routes); i++ {
+		r := &routes[i]
+		ai := slices.IndexFunc(r.Compiled.Elems, func(e routeElement) bool { return e.kind == alternation })
+		if ai == -1 {
+			expanded = append(expanded, *r)
+			continue
+		}
+
+		end := i + 1
+		for end < len(routes) && routes[end].Info.Depth > r.Info.Depth {
+			end++
+		}
+		for _, alt := range r.Compiled.Elems[ai].values {
+			elems := slices.Clone(r.Compiled.Elems)
+			elems[ai] = routeElement{kind: constant, value: alt, values: elems[ai].values}
+			variant := *r
+			variant.Compiled = routeToRegexps(elems)
+			subtree := append([]CompiledRoute{variant}, routes[i+1:end]...)
+			expanded = append(expanded, expandAlternationRoutes(subtree)...)
+		}
+		i = end - 1
+	}
+	return expanded
 }
 
 func CheckForGroupErrors(routes []CompiledRoute) (errors []RouteError) {
@@ -299,7 +479,7 @@ func CheckForGroupErrors(routes []CompiledRoute) (errors []RouteError) {
 		}
 	})
 	groupedRoutes := GroupRoutes(terminals)
-	errors = append(errors, checkForOverlaps(groupedRoutes)...)
+	errors = append(errors, checkForOverlaps(groupedRoutes, subtreePriorities(getConstantPortionTree(routes)))...)
 
 	for _, rwps := range groupedRoutes {
 		if len(rwps) > BiggestOverlapGroupAllowedBeforeWarning {
@@ -369,9 +549,15 @@ func checkNonadjacentNamesakes(terminalLines map[string][]tne, linesWithEntries
 
 type overlapBetween struct {
 	route1, route2 *CompiledRoute
+	witness        string // a URL path matched by both routes
+	kind           RouteErrorKind
 }
 
 const BiggestOverlapGroupAllowedBeforeWarning = 5
+
+// MaxAlternationExpansions is the maximum number of routes that the
+// alternations in a route and its parents may expand to.
+const MaxAlternationExpansions = 64
 const MaxOverlapGroupErrors = 10
 
 func GroupRoutes(rwps []RouteWithParents) [][]RouteWithParents {
@@ -383,18 +569,19 @@ func GroupRoutes(rwps []RouteWithParents) [][]RouteWithParents {
 	return byPrefixAndSuffix
 }
 
-func checkForOverlaps(grouped [][]RouteWithParents) []RouteError {
+func checkForOverlaps(grouped [][]RouteWithParents, priorities map[*CompiledRoute]int) []RouteError {
 	var errors []RouteError
 	for _, routes := range grouped {
-		os := checkForOverlapsWithinGroup(routes)
+		os := checkForOverlapsWithinGroup(routes, priorities)
 
 		for _, o := range os {
 			errors = append(errors, RouteError{
-				Kind:      OverlappingRoutes,
+				Kind:      o.kind,
 				Line:      o.route1.Info.Line,
 				Col:       -1,
 				OtherLine: o.route2.Info.Line,
 				Filenames: []string{o.route1.Info.Filename, o.route2.Info.Filename},
+				Witness:   o.witness,
 			})
 		}
 
@@ -406,7 +593,7 @@ func checkForOverlaps(grouped [][]RouteWithParents) []RouteError {
 	return errors
 }
 
-func checkForOverlapsWithinGroup(rwps []RouteWithParents) []overlapBetween {
+func checkForOverlapsWithinGroup(rwps []RouteWithParents, priorities map[*CompiledRoute]int) []overlapBetween {
 	regexps := make([]*node, 0)
 	regexpToInfo := make(map[*node]*CompiledRoute)
 
@@ -448,14 +635,65 @@ func checkForOverlapsWithinGroup(rwps []RouteWithParents) []overlapBetween {
 				break
 			}
 		}
-		if methodInCommon {
-			overlaps = append(overlaps, overlapBetween{regexpToInfo[regexps[oi1]], regexpToInfo[regexps[oi2]]})
+		if !methodInCommon {
+			continue
 		}
+
+		// Likewise if the hosts don't overlap.
+		if !hostsOverlap(ri1.Info.Hosts, ri2.Info.Hosts) {
+			continue
+		}
+
+		// An overlap between routes with different priorities is permitted so
+		// long as the higher priority route will in fact take precedence.
+		kind := OverlappingRoutes
+		if ri1.Info.Priority != ri2.Info.Priority {
+			hi, lo := &rwps[oi1], &rwps[oi2]
+			if ri2.Info.Priority > ri1.Info.Priority {
+				hi, lo = lo, hi
+			}
+			if priorityIsEnforceable(hi, lo, priorities) {
+				continue
+			}
+			kind = UnenforceablePriority
+		}
+
+		witness, _ := overlapWitness(regexps[oi1], regexps[oi2])
+		overlaps = append(overlaps, overlapBetween{ri1, ri2, witness, kind})
 	}
 
 	return overlaps
 }
 
+// priorityIsEnforceable determines whether the route hi takes precedence over
+// the overlapping, lower priority route lo in the generated regexps. If the
+// routes are in the same family then disjoinRegexp orders them by priority.
+// Otherwise, the family is determined by the first matching alternative of the
+// constant portion regexp. Siblings in the constant portion tree are ordered
+// by the highest priority in their subtrees, so hi's ancestor (or hi itself)
+// must have a higher subtree priority than lo's ancestor (or lo itself) at the
+// point where their ancestries diverge.
+func priorityIsEnforceable(hi, lo *RouteWithParents, priorities map[*CompiledRoute]int) bool {
+	if fullConstantPortion(hi.Route, hi.Parents) == fullConstantPortion(lo.Route, lo.Parents) {
+		return true
+	}
+
+	hiChain := append(append([]*CompiledRoute{}, hi.Parents...), hi.Route)
+	loChain := append(append([]*CompiledRoute{}, lo.Parents...), lo.Route)
+	i := 0
+	for i < len(hiChain) && i < len(loChain) && hiChain[i] == loChain[i] {
+		i++
+	}
+	if i == len(hiChain) {
+		// A parent route is tried before its children.
+		return true
+	}
+	if i == len(loChain) {
+		return false
+	}
+	return priorities[hiChain[i]] > priorities[loChain[i]]
+}
+
 func withParentRoutes(routes []CompiledRoute, iter func(*CompiledRoute, []*CompiledRoute)) {
 	lastLevel := 0
 	parentRoutes := make([]*CompiledRoute, 0)
@@ -511,18 +749,7 @@ func familiesByConstantPortion(n *cpNode) []familyWithConstantPortion {
 	constantPortionUpTo := make(map[*CompiledRoute]string)
 
 	withParentRoutesFromTree(n, func(r *CompiledRoute, parents []*CompiledRoute) {
-		var cpb strings.Builder
-		for i, p := range parents {
-			if i != 0 && !isJustSlash(parents[i-1]) {
-				cpb.WriteString("/")
-			}
-			cpb.WriteString(p.Compiled.ConstantPortion)
-		}
-		if len(parents) != 0 && !isJustSlash(parents[len(parents)-1]) {
-			cpb.WriteString("/")
-		}
-		cpb.WriteString(r.Compiled.ConstantPortion)
-		cp := cpb.String()
+		cp := fullConstantPortion(r, parents)
 		constantPortionUpTo[r] = cp
 
 		families[cp] = append(families[cp], RouteWithParents{r, parents})
@@ -543,6 +770,21 @@ func familiesByConstantPortion(n *cpNode) []familyWithConstantPortion {
 	return fwcps
 }
 
+func fullConstantPortion(r *CompiledRoute, parents []*CompiledRoute) string {
+	var cpb strings.Builder
+	for i, p := range parents {
+		if i != 0 && !isJustSlash(parents[i-1]) {
+			cpb.WriteString("/")
+		}
+		cpb.WriteString(p.Compiled.ConstantPortion)
+	}
+	if len(parents) != 0 && !isJustSlash(parents[len(parents)-1]) {
+		cpb.WriteString("/")
+	}
+	cpb.WriteString(r.Compiled.ConstantPortion)
+	return cpb.String()
+}
+
 func filterTreeByTags(n *cpNode, filter *TagExpr) {
 	// Mark all routes to be excluded and remove children of any wholly excluded
 	// subtrees.
@@ -550,7 +792,7 @@ func filterTreeByTags(n *cpNode, filter *TagExpr) {
 	rec = func(n *cpNode) {
 		ci := 0
 		for _, c := range n.children {
-			if EvalTagExpr(filter, c.routeInfo.Info.Tags, c.routeInfo.Info.Methods) {
+			if EvalTagExpr(filter, filterTags(c.routeInfo.Info.Tags, c.routeInfo.Info.Meta), c.routeInfo.Info.Methods) {
 				n.children[ci] = c
 				ci++
 			} else {
@@ -588,6 +830,97 @@ func filterTreeByTags(n *cpNode, filter *TagExpr) {
 }
 
 func GetRouteRegexps(routes []CompiledRoute, filter *TagExpr) routeRegexps {
+	rrs := getRouteRegexps(routes, filter)
+
+	hostSet := make(map[string]struct{})
+	for _, r := range routes {
+		if r.Info.Terminal && EvalTagExpr(filter, filterTags(r.Info.Tags, r.Info.Meta), r.Info.Methods) {
+			for _, h := range r.Info.Hosts {
+				hostSet[h] = struct{}{}
+			}
+		}
+	}
+	if len(hostSet) > 0 {
+		hostSet[""] = struct{}{}
+		for _, h := range stringSetToList(hostSet) {
+			rrs.hosts = append(rrs.hosts, hostRouteRegexps{h, getRouteRegexps(routesForHost(routes, h), filter)})
+		}
+	}
+
+	return rrs
+}
+
+// routesForHost returns the terminal routes that accept the given host pattern
+// (or that accept any host if the pattern is empty) together with their
+// parents.
+func routesForHost(routes []CompiledRoute, host string) []CompiledRoute {
+	accepts := func(r *CompiledRoute) bool {
+		if len(r.Info.Hosts) == 0 {
+			return true
+		}
+		return host != "" && slices.ContainsFunc(r.Info.Hosts, func(h string) bool { return hostPatternMatches(h, host) })
+	}
+
+	// Iterate backwards so that the children of a route are seen before the
+	// route itself. keptBelow[d] records whether a route has been kept at depth
+	// d since the last route at a lower depth.
+	keep := make([]bool, len(routes))
+	var keptBelow []bool
+	for i := len(routes) - 1; i >= 0; i-- {
+		d := routes[i].Info.Depth
+		for len(keptBelow) < d+2 {
+			keptBelow = append(keptBelow, false)
+		}
+		keep[i] = (routes[i].Info.Terminal && accepts(&routes[i])) || keptBelow[d+1]
+		keptBelow[d+1] = false
+		keptBelow[d] = keptBelow[d] || keep[i]
+	}
+
+	var result []CompiledRoute
+	for i := range routes {
+		if keep[i] {
+			r := routes[i]
+			r.Info.Terminal = r.Info.Terminal && accepts(&r)
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+// hostPatternMatches determines whether a host pattern matches a host. The host
+// may itself be a pattern, in which case a wildcard pattern matches it only if
+// the two patterns are the same. A wildcard matches exactly one label, so that
+// '*.example.com' matches 'foo.example.com' but not 'example.com' or
+// 'foo.bar.example.com'.
+func hostPatternMatches(pattern, host string) bool {
+	if pattern == host {
+		return true
+	}
+	suffix, ok := strings.CutPrefix(pattern, "*")
+	if !ok || strings.HasPrefix(host, "*") {
+		return false
+	}
+	label, ok := strings.CutSuffix(host, suffix)
+	return ok && label != "" && !strings.Contains(label, ".")
+}
+
+// hostsOverlap determines whether some host is accepted by routes with both
+// sets of host patterns. A route with no host patterns accepts any host.
+func hostsOverlap(hosts1, hosts2 []string) bool {
+	if len(hosts1) == 0 || len(hosts2) == 0 {
+		return true
+	}
+	for _, h1 := range hosts1 {
+		for _, h2 := range hosts2 {
+			if hostPatternMatches(h1, h2) || hostPatternMatches(h2, h1) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func getRouteRegexps(routes []CompiledRoute, filter *TagExpr) routeRegexps {
 	tree := getConstantPortionTree(routes)
 
 	filterTreeByTags(tree, filter)
@@ -595,9 +928,14 @@ func GetRouteRegexps(routes []CompiledRoute, filter *TagExpr) routeRegexps {
 
 	originalConstantPortionRegexp := getConstantPortionRegexp(tree)
 	parsedConstantPortionRegexp := parseRegexp(originalConstantPortionRegexp)
-	scratchBuffer := make([]byte, 64)
-	sgds := findSingleGroupDisjuncts(parsedConstantPortionRegexp, scratchBuffer)
-	refactorSingleGroupDisjuncts(sgds)
+	// Refactoring single group disjuncts changes the order of disjuncts, which
+	// matters only if some routes take precedence over others that they
+	// overlap with.
+	if samePriority(routes) {
+		scratchBuffer := make([]byte, 64)
+		sgds := findSingleGroupDisjuncts(parsedConstantPortionRegexp, scratchBuffer)
+		refactorSingleGroupDisjuncts(sgds)
+	}
 	constantPortionRegexp := renodeToString(parsedConstantPortionRegexp)
 
 	byCp := familiesByConstantPortion(tree)
@@ -614,24 +952,9 @@ func GetRouteRegexps(routes []CompiledRoute, filter *TagExpr) routeRegexps {
 			continue
 		}
 
-		result := disjoinRegexp(ts)
-
-		members := make([]routeGroupMember, 0)
-		for i := range result.paramGroups {
-			members = append(members, routeGroupMember{
-				name:              result.names[i],
-				paramGroupNumbers: result.paramGroups[i],
-				route:             ts[i],
-			})
-		}
-
-		families = append(families, routeFamily{
-			constantPortion:      cp,
-			members:              members,
-			matchRegexp:          wrapMatchRegexp(result.regex),
-			nonparamGroupNumbers: result.nonparamGroups,
-			nLevels:              result.nLevels,
-		})
+		family := makeRouteFamily(cp, ts)
+		family.methodFamilies = getMethodFamilies(cp, ts, filter)
+		families = append(families, family)
 	}
 
 	return routeRegexps{
@@ -641,6 +964,70 @@ func GetRouteRegexps(routes []CompiledRoute, filter *TagExpr) routeRegexps {
 	}
 }
 
+func samePriority(routes []CompiledRoute) bool {
+	for i := range routes {
+		if routes[i].Info.Priority != routes[0].Info.Priority {
+			return false
+		}
+	}
+	return true
+}
+
+func makeRouteFamily(cp string, ts []*RouteWithParents) routeFamily {
+	result := disjoinRegexp(ts)
+
+	members := make([]routeGroupMember, 0)
+	for i := range result.paramGroups {
+		members = append(members, routeGroupMember{
+			name:              result.names[i],
+			paramGroupNumbers: result.paramGroups[i],
+			route:             result.routes[i],
+		})
+	}
+
+	return routeFamily{
+		constantPortion:      cp,
+		members:              members,
+		matchRegexp:          wrapMatchRegexp(result.regex),
+		nonparamGroupNumbers: result.nonparamGroups,
+		nLevels:              result.nLevels,
+	}
+}
+
+func getMethodFamilies(cp string, ts []*RouteWithParents, filter *TagExpr) []methodFamily {
+	// If every member accepts the same methods then the family's match regexp
+	// already identifies the right member for every method.
+	var firstMethods []string
+	allSame := true
+	byMethod := make(map[string][]*RouteWithParents)
+	for i, t := range ts {
+		ms := stringSetToList(matchingMethods(filter, t.Route.Info.Methods, filterTags(t.Route.Info.Tags, t.Route.Info.Meta)))
+		if i == 0 {
+			firstMethods = ms
+		} else if !slices.Equal(ms, firstMethods) {
+			allSame = false
+		}
+		for _, m := range ms {
+			byMethod[m] = append(byMethod[m], t)
+		}
+	}
+	if allSame {
+		return nil
+	}
+
+	methods := make([]string, 0, len(byMethod))
+	for m := range byMethod {
+		methods = append(methods, m)
+	}
+	sort.Strings(methods)
+
+	mfs := make([]methodFamily, len(methods))
+	for i, m := range methods {
+		mfs[i] = methodFamily{m, makeRouteFamily(cp, byMethod[m])}
+	}
+	return mfs
+}
+
 func getTerminalRoutes(rs []RouteWithParents) []*RouteWithParents {
 	terms := make([]*RouteWithParents, 0)
 	for i, r := range rs {
@@ -665,7 +1052,10 @@ func RouteRegexpsToJSON(rrs *routeRegexps, filter *TagExpr) ([]byte, int) {
 	// allocation.
 
 	out := make([]byte, 0, 1024)
+	return appendRouteRegexpsJSON(out, rrs, filter)
+}
 
+func appendRouteRegexpsJSON(out []byte, rrs *routeRegexps, filter *TagExpr) ([]byte, int) {
 	out = append(out, `{"constantPortionNGroups":`...)
 	out = appendJsonPosInt(out, rrs.constantPortionNGroups)
 	out = append(out, `,"constantPortionRegexp":`...)
@@ -681,64 +1071,274 @@ func RouteRegexpsToJSON(rrs *routeRegexps, filter *TagExpr) ([]byte, int) {
 		nFamiliesOut++
 
 		out = appendJsonString(out, g.constantPortion)
-		out = append(out, `:{"matchRegexp":`...)
-		out = appendJsonString(out, g.matchRegexp)
-		out = append(out, `,"nLevels":`...)
-		out = appendJsonPosInt(out, g.nLevels)
-		out = append(out, `,"nonparamGroupNumbers":[`...)
-		for j, npg := range g.nonparamGroupNumbers {
-			if j != 0 {
+		out = append(out, ':')
+		var nMembersOut int
+		out, nMembersOut = appendFamilyJSON(out, &g, filter)
+		nRoutesOut += nMembersOut
+	}
+	out = append(out, '}')
+
+	if len(rrs.hosts) > 0 {
+		out = append(out, `,"hosts":{`...)
+		for i := range rrs.hosts {
+			if i != 0 {
 				out = append(out, ',')
 			}
-			out = appendJsonPosInt(out, npg)
+			out = appendJsonString(out, rrs.hosts[i].host)
+			out = append(out, ':')
+			out, _ = appendRouteRegexpsJSON(out, &rrs.hosts[i].routeRegexps, filter)
 		}
-		out = append(out, `],"members":[`...)
-		nMembersOut := 0
-		for _, m := range g.members {
-			matchingMs := matchingMethods(filter, m.route.Route.Info.Methods, m.route.Route.Info.Tags)
-			if len(matchingMs) == 0 {
-				continue
+		out = append(out, '}')
+	}
+
+	out = append(out, '}')
+
+	return out, nRoutesOut
+}
+
+func appendFamilyJSON(out []byte, g *routeFamily, filter *TagExpr) ([]byte, int) {
+	out = append(out, `{"matchRegexp":`...)
+	out = appendJsonString(out, g.matchRegexp)
+	out = append(out, `,"nLevels":`...)
+	out = appendJsonPosInt(out, g.nLevels)
+	out = append(out, `,"nonparamGroupNumbers":[`...)
+	for j, npg := range g.nonparamGroupNumbers {
+		if j != 0 {
+			out = append(out, ',')
+		}
+		out = appendJsonPosInt(out, npg)
+	}
+	out = append(out, `],"members":[`...)
+	nMembersOut := 0
+	for _, m := range g.members {
+		matchingMs := matchingMethods(filter, m.route.Route.Info.Methods, filterTags(m.route.Route.Info.Tags, m.route.Route.Info.Meta))
+		if len(matchingMs) == 0 {
+			continue
+		}
+		if nMembersOut != 0 {
+			out = append(out, ',')
+		}
+		nMembersOut++
+		out = append(out, `{"name":`...)
+		out = appendJsonString(out, m.name)
+		out = append(out, `,"paramGroupNumbers":{`...)
+		k := 0
+		for key, pgn := range m.paramGroupNumbers {
+			if k != 0 {
+				out = append(out, ',')
+			}
+			out = appendJsonString(out, key)
+			out = append(out, ':')
+			out = appendJsonPosInt(out, pgn)
+			k++
+		}
+		template := getTemplate(m.route)
+		out = append(out, `},"paramKinds":`...)
+		out = appendParamKindsJSON(out, template)
+		out = append(out, `,"tags":[`...)
+		for k, tag := range computeTags(&m) {
+			if k != 0 {
+				out = append(out, ',')
 			}
-			if nMembersOut != 0 {
+			out = appendJsonString(out, tag)
+		}
+		out = append(out, `],"methods":[`...)
+		for k, m := range stringSetToList(matchingMs) {
+			if k != 0 {
 				out = append(out, ',')
 			}
-			nMembersOut++
-			nRoutesOut++
-			out = append(out, `{"name":`...)
-			out = appendJsonString(out, m.name)
-			out = append(out, `,"paramGroupNumbers":{`...)
-			k := 0
-			for key, pgn := range m.paramGroupNumbers {
+			out = appendJsonString(out, m)
+		}
+		out = append(out, `],"template":`...)
+		out = appendTemplateJSON(out, template)
+		if alternatives := getAlternatives(m.route); len(alternatives) > 0 {
+			out = append(out, `,"alternatives":[`...)
+			for k, a := range alternatives {
 				if k != 0 {
 					out = append(out, ',')
 				}
-				out = appendJsonString(out, key)
-				out = append(out, ':')
-				out = appendJsonPosInt(out, pgn)
-				k++
+				out = appendJsonString(out, a)
 			}
-			out = append(out, `},"tags":[`...)
-			for k, tag := range computeTags(&m) {
+			out = append(out, ']')
+		}
+		if p := m.route.Route.Info.Priority; p != 0 {
+			out = append(out, `,"priority":`...)
+			out = strconv.AppendInt(out, int64(p), 10)
+		}
+		if meta := m.route.Route.Info.Meta; len(meta) > 0 {
+			out = append(out, `,"meta":{`...)
+			for k, key := range stringSetToList(meta) {
 				if k != 0 {
 					out = append(out, ',')
 				}
-				out = appendJsonString(out, tag)
+				out = appendJsonString(out, key)
+				out = append(out, ':')
+				out = appendJsonString(out, meta[key])
 			}
-			out = append(out, `],"methods":[`...)
-			for k, m := range stringSetToList(matchingMs) {
+			out = append(out, '}')
+		}
+		if hosts := m.route.Route.Info.Hosts; len(hosts) > 0 {
+			out = append(out, `,"hosts":[`...)
+			for k, h := range hosts {
 				if k != 0 {
 					out = append(out, ',')
 				}
-				out = appendJsonString(out, m)
+				out = appendJsonString(out, h)
+			}
+			out = append(out, ']')
+		}
+		out = append(out, '}')
+	}
+	out = append(out, ']')
+	if len(g.methodFamilies) > 0 {
+		out = append(out, `,"methodFamilies":{`...)
+		for i := range g.methodFamilies {
+			if i != 0 {
+				out = append(out, ',')
 			}
-			out = append(out, "]}"...)
+			out = appendJsonString(out, g.methodFamilies[i].method)
+			out = append(out, ':')
+			out, _ = appendFamilyJSON(out, &g.methodFamilies[i].family, filter)
 		}
-		out = append(out, `]}`...)
+		out = append(out, '}')
 	}
+	out = append(out, '}')
 
-	out = append(out, `}}`...)
+	return out, nMembersOut
+}
 
-	return out, nRoutesOut
+// getTemplate returns the elements of the full path of a route (including the
+// paths of its parents), normalized so that it can be used to construct URLs.
+// Sequences of slashes are collapsed and '!/' elements are removed. The
+// template ends in a slash only if the route requires a trailing slash.
+// Alternatives chosen when the route was expanded are replaced by their
+// alternations.
+func getTemplate(rwp *RouteWithParents) []routeElement {
+	elems := []routeElement{{kind: slash}}
+	add := func(es []routeElement) {
+		for _, e := range es {
+			if e.kind == noTrailingSlash || (e.kind == slash && elems[len(elems)-1].kind == slash) {
+				continue
+			}
+			if e.kind == constant && e.values != nil {
+				e = routeElement{kind: alternation, values: e.values}
+			}
+			elems = append(elems, e)
+		}
+	}
+
+	for _, p := range rwp.Parents {
+		add(p.Compiled.Elems)
+		add([]routeElement{{kind: slash}})
+	}
+	add(rwp.Route.Compiled.Elems)
+
+	return elems
+}
+
+// getAlternatives returns the alternatives that were chosen for the
+// alternations in the full path of a route when it was expanded.
+func getAlternatives(rwp *RouteWithParents) []string {
+	var alternatives []string
+	for _, r := range append(slices.Clone(rwp.Parents), rwp.Route) {
+		for _, e := range r.Compiled.Elems {
+			if e.kind == constant && e.values != nil {
+				alternatives = append(alternatives, e.value)
+			}
+		}
+	}
+	return alternatives
+}
+
+func appendTemplateJSON(out []byte, elems []routeElement) []byte {
+	out = append(out, '[')
+	for i, e := range elems {
+		if i != 0 {
+			out = append(out, ',')
+		}
+		switch e.kind {
+		case slash:
+			out = append(out, `"/"`...)
+		case constant:
+			out = appendJsonString(out, e.value)
+		case parameter:
+			if e.paramType != "" {
+				out = append(out, `[":`...)
+				out = append(out, e.paramType...)
+				out = append(out, `",`...)
+				out = appendJsonString(out, e.value)
+				out = append(out, ']')
+				continue
+			}
+			if e.regexp != "" {
+				out = append(out, `[":~",`...)
+				out = appendJsonString(out, e.value)
+				out = append(out, ',')
+				out = appendJsonString(out, e.regexp)
+				out = append(out, ']')
+				continue
+			}
+			out = append(out, `[":",`...)
+			out = appendJsonString(out, e.value)
+			for _, v := range e.values {
+				out = append(out, ',')
+				out = appendJsonString(out, v)
+			}
+			out = append(out, ']')
+		case integerParameter:
+			out = append(out, `[":#",`...)
+			out = appendJsonString(out, e.value)
+			out = append(out, ']')
+		case restParameter:
+			out = append(out, `[":**",`...)
+			out = appendJsonString(out, e.value)
+			out = append(out, ']')
+		case singleGlob:
+			out = append(out, `["*"]`...)
+		case doubleGlob:
+			out = append(out, `["**"]`...)
+		case optionalStart:
+			out = append(out, `["["]`...)
+		case optionalEnd:
+			out = append(out, `["]"]`...)
+		case alternation:
+			out = append(out, `["|"`...)
+			for _, v := range e.values {
+				out = append(out, ',')
+				out = appendJsonString(out, v)
+			}
+			out = append(out, ']')
+		}
+	}
+	return append(out, ']')
+}
+
+func appendParamKindsJSON(out []byte, template []routeElement) []byte {
+	kinds := make(map[string]string)
+	for _, e := range template {
+		switch e.kind {
+		case parameter:
+			kinds[e.value] = "string"
+			if e.paramType != "" {
+				kinds[e.value] = e.paramType
+			}
+		case integerParameter:
+			kinds[e.value] = "integer"
+		case restParameter:
+			kinds[e.value] = "rest"
+		}
+	}
+
+	out = append(out, '{')
+	for i, name := range stringSetToList(kinds) {
+		if i != 0 {
+			out = append(out, ',')
+		}
+		out = appendJsonString(out, name)
+		out = append(out, ':')
+		out = appendJsonString(out, kinds[name])
+	}
+	return append(out, '}')
 }
 
 func matchingMethods(filter *TagExpr, methods map[string]struct{}, tags map[string]struct{}) map[string]struct{} {
@@ -751,20 +1351,30 @@ func matchingMethods(filter *TagExpr, methods map[string]struct{}, tags map[stri
 	return r
 }
 
-func computeTags(m *routeGroupMember) []string {
-	tags := make(map[string]struct{}, len(m.route.Route.Info.Tags)*2)
-	for k := range m.route.Route.Info.Tags {
-		tags[k] = struct{}{}
+// filterTags returns the set of tags against which filter expressions are
+// evaluated for a route: its tags together with a 'key=value' tag for each
+// item of its metadata.
+func filterTags(tags map[string]struct{}, meta map[string]string) map[string]struct{} {
+	if len(meta) == 0 {
+		return tags
 	}
-	for _, p := range m.route.Parents {
-		for k := range p.Info.Tags {
-			tags[k] = struct{}{}
-		}
+	ft := maps.Clone(tags)
+	if ft == nil {
+		ft = make(map[string]struct{}, len(meta))
 	}
-	return stringSetToList(tags)
+	for k, v := range meta {
+		ft[k+"="+v] = struct{}{}
+	}
+	return ft
+}
+
+// computeTags returns the tags of a route in sorted order. The tags inherited
+// from the route's parents are already resolved by ProcessRouteFiles.
+func computeTags(m *routeGroupMember) []string {
+	return stringSetToList(m.route.Route.Info.Tags)
 }
 
-func stringSetToList(tags map[string]struct{}) []string {
+func stringSetToList[V any](tags map[string]V) []string {
 	lst := make([]string, len(tags))
 	i := 0
 	for tag := range tags {
@@ -777,13 +1387,22 @@ func stringSetToList(tags map[string]struct{}) []string {
 
 type disjoinRegexResult struct {
 	regex          string
+	routes         []*RouteWithParents
 	paramGroups    []map[string]int
 	names          []string
 	nonparamGroups []int
 	nLevels        int
 }
 
+// disjoinRegexp joins the regexps of the routes in a family. The routes are
+// ordered by descending priority (and otherwise keep their order), so that the
+// first matching alternative is the route with the highest priority.
 func disjoinRegexp(routes []*RouteWithParents) disjoinRegexResult {
+	routes = slices.Clone(routes)
+	sort.SliceStable(routes, func(i, j int) bool {
+		return routes[i].Route.Info.Priority > routes[j].Route.Info.Priority
+	})
+
 	nLevels := 1
 	nLeaves := 2
 	for nLeaves < len(routes) {
@@ -868,6 +1487,7 @@ func disjoinRegexp(routes []*RouteWithParents) disjoinRegexResult {
 
 	return disjoinRegexResult{
 		regex:          sb.String(),
+		routes:         routes,
 		paramGroups:    paramGroups,
 		names:          names,
 		nonparamGroups: nonparamGroups,
@@ -880,6 +1500,7 @@ type cpNode struct {
 	leftOffset int
 	factorChar rune // 0 if regular node
 	excluded   bool // used temporarily during filtering
+	priority   int  // the highest priority of a terminal route in the subtree
 	children   []*cpNode
 }
 
@@ -926,9 +1547,37 @@ func getConstantPortionTree(routes []CompiledRoute) *cpNode {
 		lastLevel = r.Info.Depth
 	}
 
+	setSubtreePriorities(&root)
+
 	return &root
 }
 
+func setSubtreePriorities(n *cpNode) int {
+	n.priority = math.MinInt
+	if n.routeInfo != nil && n.routeInfo.Info.Terminal {
+		n.priority = n.routeInfo.Info.Priority
+	}
+	for _, c := range n.children {
+		n.priority = max(n.priority, setSubtreePriorities(c))
+	}
+	return n.priority
+}
+
+func subtreePriorities(tree *cpNode) map[*CompiledRoute]int {
+	priorities := make(map[*CompiledRoute]int)
+	var rec func(n *cpNode)
+	rec = func(n *cpNode) {
+		if n.routeInfo != nil {
+			priorities[n.routeInfo] = n.priority
+		}
+		for _, c := range n.children {
+			rec(c)
+		}
+	}
+	rec(tree)
+	return priorities
+}
+
 func optimizeConstantPortionTree(tree *cpNode) {
 	if tree == nil {
 		return
@@ -937,9 +1586,31 @@ func optimizeConstantPortionTree(tree *cpNode) {
 		return
 	}
 
-	sort.Slice(tree.children, func(i, j int) bool {
-		elem1 := tree.children[i]
-		elem2 := tree.children[j]
+	// Subtrees containing higher priority routes come first, so that they are
+	// tried first by the constant portion regexp. Factoring is done separately
+	// for each run of subtrees with the same priority, so as not to change this
+	// order.
+	sort.SliceStable(tree.children, func(i, j int) bool {
+		return tree.children[i].priority > tree.children[j].priority
+	})
+	for start := 0; start < len(tree.children); {
+		end := start + 1
+		for end < len(tree.children) && tree.children[end].priority == tree.children[start].priority {
+			end++
+		}
+		factorChildren(tree.children[start:end])
+		start = end
+	}
+
+	for _, c := range tree.children {
+		optimizeConstantPortionTree(c)
+	}
+}
+
+func factorChildren(children []*cpNode) {
+	sort.Slice(children, func(i, j int) bool {
+		elem1 := children[i]
+		elem2 := children[j]
 		var c1, c2 string
 		if len(elem1.routeInfo.Compiled.Elems) != 0 && elem1.routeInfo.Compiled.Elems[0].kind == constant {
 			c1 = elem1.routeInfo.Compiled.Elems[0].value[elem1.leftOffset:]
@@ -962,7 +1633,7 @@ func optimizeConstantPortionTree(tree *cpNode) {
 	firstCharStartIndices := make(map[rune]int)
 	firstCharEndIndices := make(map[rune]int)
 	var fc, lastChar rune
-	for i, c := range tree.children {
+	for i, c := range children {
 		fc = getFirstChar(c.routeInfo, c.leftOffset)
 
 		if fc == 0 {
@@ -980,8 +1651,8 @@ func optimizeConstantPortionTree(tree *cpNode) {
 		}
 		lastChar = fc
 	}
-	if len(tree.children) > 0 {
-		firstCharEndIndices[fc] = len(tree.children)
+	if len(children) > 0 {
+		firstCharEndIndices[fc] = len(children)
 	}
 
 	if len(nByFirstChar) >= 3 {
@@ -992,22 +1663,18 @@ func optimizeConstantPortionTree(tree *cpNode) {
 
 			si, ei := firstCharStartIndices[fc], firstCharEndIndices[fc]
 
-			children := make([]*cpNode, ei-si)
-			copy(children, tree.children[si:ei])
-			tree.children[si] = &cpNode{factorChar: fc, children: children}
+			factored := make([]*cpNode, ei-si)
+			copy(factored, children[si:ei])
+			children[si] = &cpNode{factorChar: fc, priority: children[si].priority, children: factored}
 			for i := si + 1; i < ei; i++ {
-				tree.children[i] = nil
+				children[i] = nil
 			}
-			for _, c := range children {
+			for _, c := range factored {
 				c.leftOffset += utf8.RuneLen(fc)
 			}
 
 		}
 	}
-
-	for _, c := range tree.children {
-		optimizeConstantPortionTree(c)
-	}
 }
 
 func getConstantPortionRegexp(tree *cpNode) string {
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"reflect"
 	"regexp"
+	"slices"
 	"sort"
 	"strings"
 	"testing"
@@ -11,7 +12,7 @@ import (
 
 func TestRouteToRegexp(t *testing.T) {
 	ri := routeToRegexps(parseRoute("/foo/:bar/amp"))
-	if !(reflect.DeepEqual(ri.Elems, []routeElement{{constant, "foo", 0, 1}, {slash, "", 0, 4}, {parameter, "bar", 0, 5}, {slash, "", 0, 9}, {constant, "amp", 0, 10}}) &&
+	if !(reflect.DeepEqual(ri.Elems, []routeElement{{kind: constant, value: "foo", col: 1}, {kind: slash, col: 4}, {kind: parameter, value: "bar", col: 5}, {kind: slash, col: 9}, {kind: constant, value: "amp", col: 10}}) &&
 		ri.ConstantPortion == "foo//amp" && ri.NGroups == 1 &&
 		reflect.DeepEqual(ri.ParamGroupNumbers, map[string]int{"bar": 1})) {
 		t.Errorf("Unexpected return value of routeToRegexps: %+v\n", ri)
@@ -644,6 +645,573 @@ func TestOverlapDetection(t *testing.T) {
 	)
 }
 
+func TestOverlapDetectionEnumeratedParameters(t *testing.T) {
+	assertOverlap(
+		t,
+		1, 2,
+		""+
+			"foo /docs/:{lang in en,fr}/:page\n"+
+			"bar /docs/fr/:page",
+	)
+	assertNoOverlap(
+		t,
+		""+
+			"foo /docs/:{lang in en,fr}/:page\n"+
+			"bar /docs/es/:page",
+	)
+	assertNoOverlap(
+		t,
+		""+
+			"foo /docs/:{lang in en,fr}\n"+
+			"bar /docs/:{lang in es,de}",
+	)
+	assertOverlap(
+		t,
+		1, 2,
+		""+
+			"foo /docs/:{lang in en,fr}\n"+
+			"bar /docs/:{lang in es,fr}",
+	)
+	assertOverlap(
+		t,
+		1, 2,
+		""+
+			"foo /docs/:{lang in en,fr}\n"+
+			"bar /docs/:slug",
+	)
+	assertNoOverlap(
+		t,
+		""+
+			"foo /docs/:{lang in en,fr}\n"+
+			"bar /docs/e:slug",
+	)
+	assertNoOverlap(
+		t,
+		""+
+			"foo /:{a in x,y}/:{b in z}\n"+
+			"bar /:{a in x,y}/:{b in w}",
+	)
+}
+
+func TestOverlapDetectionParamRegexps(t *testing.T) {
+	assertNoOverlap(
+		t,
+		""+
+			"hex  /items/:{id ~ [0-9a-f]+}\n"+
+			"slug /items/:{slug ~ [a-z]+-[a-z-]+}",
+	)
+	assertOverlap(
+		t,
+		1, 2,
+		""+
+			"hex  /items/:{id ~ [0-9a-f]+}\n"+
+			"word /items/:{word ~ [a-z]+}",
+	)
+	assertNoOverlap(
+		t,
+		""+
+			"hex /items/:{id ~ [0-9a-f]+}\n"+
+			"new /items/new",
+	)
+	assertOverlap(
+		t,
+		2, 1,
+		""+
+			"any /items/:{id ~ .+}\n"+
+			"new /items/new",
+	)
+	assertNoOverlap(
+		t,
+		""+
+			"any /items/:{id ~ .+}\n"+
+			"new /items/new/x",
+	)
+}
+
+func TestOverlapDetectionParamTypes(t *testing.T) {
+	assertNoOverlap(
+		t,
+		""+
+			"item /items/:uuid:id\n"+
+			"new  /items/new",
+	)
+	assertNoOverlap(
+		t,
+		""+
+			"item /items/:uuid:id\n"+
+			"day  /items/:date:day",
+	)
+	assertNoOverlap(
+		t,
+		""+
+			"item /items/:uuid:id\n"+
+			"blob /items/:hex:digest",
+	)
+	assertOverlap(
+		t,
+		2, 1,
+		""+
+			"blob /items/:hex:digest\n"+
+			"new  /items/add",
+	)
+	assertOverlap(
+		t,
+		2, 1,
+		""+
+			"post /posts/:slug:slug\n"+
+			"new  /posts/new",
+	)
+	assertNoOverlap(
+		t,
+		""+
+			"day  /archive/:date:day\n"+
+			"year /archive/:#year",
+	)
+}
+
+func TestOverlapDetectionOptionalGroups(t *testing.T) {
+	assertOverlap(
+		t,
+		1, 2,
+		""+
+			"posts /posts[/page/:#n]\n"+
+			"page  /posts/page/:#n",
+	)
+	assertOverlap(
+		t,
+		2, 1,
+		""+
+			"posts /posts[/page/:#n]\n"+
+			"all   /posts",
+	)
+	assertNoOverlap(
+		t,
+		""+
+			"posts /posts[/page/:#n]\n"+
+			"new   /posts/new\n"+
+			"page  /posts/page/last",
+	)
+	assertNoOverlap(
+		t,
+		""+
+			"posts /posts[/page/:#n]\n"+
+			"post  /posts/:#id",
+	)
+}
+
+func TestOverlapDetectionAlternations(t *testing.T) {
+	assertNoOverlap(
+		t,
+		""+
+			"photo  /(photos|images)/:id\n"+
+			"new    /photos/new/:id\n"+
+			"legacy /(pics|pictures)/:id",
+	)
+	assertOverlap(
+		t,
+		1, 2,
+		""+
+			"photo  /(photos|images)/:id\n"+
+			"image  /images/:id",
+	)
+	assertOverlap(
+		t,
+		2, 3,
+		""+
+			"photos /photos\n"+
+			"  item /(a|b)/:id\n"+
+			"images /photos/b/:id",
+	)
+}
+
+func TestProcessRouteFileExpandsAlternations(t *testing.T) {
+	const routeFile = `
+photos /(photos|images)
+  .
+  item /:id/(edit|change)
+other /other
+`
+
+	entries, errs := ParseRouteFile(strings.NewReader(routeFile), DisallowUpperCase)
+	if len(errs) > 0 {
+		t.Fatalf("%+v\n", errs)
+	}
+	routes, errs := ProcessRouteFiles([][]RouteFileEntry{entries}, []string{""}, "/")
+	if len(errs) > 0 {
+		t.Fatalf("%+v\n", errs)
+	}
+
+	var got []string
+	for _, r := range routes {
+		got = append(got, fmt.Sprintf("%v %v %v", r.Info.Depth, r.Info.Name, r.Compiled.ConstantPortion))
+	}
+	expected := []string{
+		"0 photos photos",
+		"1 photos/item /edit",
+		"1 photos/item /change",
+		"0 photos images",
+		"1 photos/item /edit",
+		"1 photos/item /change",
+		"0 other other",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected routes\n%v\ngot\n%v\n", strings.Join(expected, "\n"), strings.Join(got, "\n"))
+	}
+
+	rrs := GetRouteRegexps(routes, nil)
+	json, _ := RouteRegexpsToJSON(&rrs, nil)
+	for _, expected := range []string{
+		`"template":["/",["|","photos","images"],"/",[":","id"],"/",["|","edit","change"]],"alternatives":["images","change"]}`,
+		`"template":["/",["|","photos","images"]],"alternatives":["photos"]}`,
+	} {
+		if !strings.Contains(string(json), expected) {
+			t.Errorf("Expected JSON output to contain\n%v\ngot\n%s\n", expected, json)
+		}
+	}
+}
+
+func TestProcessRouteFileLimitsAlternationExpansions(t *testing.T) {
+	const routeFile = `
+ok      /(a|b|c|d|e)/(f|g|h|i|j)
+big     /(a|b|c|d|e)/(f|g|h|i|j)/(k|l|m|n|o)
+parent  /(a|b|c|d|e|f|g|h)
+  child /(i|j|k|l|m|n|o|p|q)
+    grandchild /(r|s)
+`
+
+	entries, errs := ParseRouteFile(strings.NewReader(routeFile), DisallowUpperCase)
+	if len(errs) > 0 {
+		t.Fatalf("%+v\n", errs)
+	}
+	_, errs = ProcessRouteFiles([][]RouteFileEntry{entries}, []string{"routes"}, "/")
+	if len(errs) != 2 {
+		t.Fatalf("Expected 2 errors, got %+v\n", errs)
+	}
+	if errs[0].Kind != TooManyAlternationExpansions || errs[0].Line != 3 {
+		t.Errorf("Expected TooManyAlternationExpansions at line 3, got %+v\n", errs[0])
+	}
+	if errs[1].Kind != TooManyAlternationExpansions || errs[1].Line != 5 {
+		t.Errorf("Expected TooManyAlternationExpansions at line 5, got %+v\n", errs[1])
+	}
+
+	entries, errs = ParseRouteFile(strings.NewReader("ok /(a|b|c|d|e)/(f|g|h|i|j)\n"), DisallowUpperCase)
+	if len(errs) > 0 {
+		t.Fatalf("%+v\n", errs)
+	}
+	routes, errs := ProcessRouteFiles([][]RouteFileEntry{entries}, []string{"routes"}, "/")
+	if len(errs) != 0 || len(routes) != 25 {
+		t.Errorf("Expected 25 routes and no errors, got %v routes and %+v\n", len(routes), errs)
+	}
+}
+
+func TestOverlapDetectionWithPriorities(t *testing.T) {
+	assertGroupErrorKinds(t, ""+
+		"new ^1 /users/new\n"+
+		"show /users/:id\n",
+	)
+	assertGroupErrorKinds(t, ""+
+		"new /users/new\n"+
+		"show ^-1 /users/:id\n",
+	)
+	assertGroupErrorKinds(t, ""+
+		"show /a/:x\n"+
+		"int ^1 /a/:#n\n",
+	)
+	assertGroupErrorKinds(t, ""+
+		"users /users\n"+
+		"  show /:id\n"+
+		"  new ^1 /new\n",
+	)
+	assertGroupErrorKinds(t, ""+
+		"users /users\n"+
+		"  new ^1 /new\n"+
+		"show /users/:id\n",
+	)
+	assertGroupErrorKinds(t, ""+
+		"new ^1 /users/new\n"+
+		"show ^1 /users/:id\n",
+		OverlappingRoutes,
+	)
+	assertGroupErrorKinds(t, ""+
+		"new ^1 /users/new\n"+
+		"show ^2 /users/:id\n"+
+		"other ^1 /users/:x\n",
+		OverlappingRoutes,
+	)
+	// The subtree containing 'show' has a route with a higher priority than
+	// 'new', so 'show' would be tried first.
+	assertGroupErrorKinds(t, ""+
+		"new ^1 /users/new\n"+
+		"show /users/:id\n"+
+		"  .\n"+
+		"  edit ^2 /edit\n",
+		UnenforceablePriority,
+	)
+}
+
+func assertGroupErrorKinds(t *testing.T, routeFile string, expected ...RouteErrorKind) {
+	t.Helper()
+
+	entries, errors := ParseRouteFile(strings.NewReader(routeFile), DisallowUpperCase)
+	if len(errors) > 0 {
+		t.Fatalf("Errors parsing route file: %+v\nRoutes:\n%v\n", errors, routeFile)
+	}
+	routes, routeErrors := ProcessRouteFiles([][]RouteFileEntry{entries}, []string{""}, "/")
+	if len(routeErrors) != 0 {
+		t.Fatalf("Expecting to get no errors back from ProcessRouteFiles, got %+v.\nRoutes:\n%v\n", routeErrors, routeFile)
+	}
+	var kinds []RouteErrorKind
+	for _, e := range CheckForGroupErrors(routes) {
+		kinds = append(kinds, e.Kind)
+	}
+	if !slices.Equal(kinds, expected) {
+		t.Errorf("Expecting errors of kinds %v from CheckForGroupErrors, got %v.\nRoutes:\n%v\n", expected, kinds, routeFile)
+	}
+}
+
+func TestDisjoinRegexpPriority(t *testing.T) {
+	routes := []*RouteWithParents{
+		makeSimpleRouteWithParents("foo", "a", nil),
+		makeSimpleRouteWithParents("bar", "b", nil),
+		makeSimpleRouteWithParents("amp", "c", nil),
+	}
+	routes[1].Route.Info.Priority = 1
+	routes[2].Route.Info.Priority = -1
+
+	result := disjoinRegexp(routes)
+	if !slices.Equal(result.names, []string{"b", "a", "c"}) {
+		t.Errorf("Expected members to be ordered by priority, got %v\n", result.names)
+	}
+	if result.regex != "(?:((\\/+bar\\/*)|(\\/+foo\\/*))|((\\/+amp\\/*)))" {
+		t.Errorf("Unexpected regex %v\n", result.regex)
+	}
+}
+
+func TestPriorityInJSON(t *testing.T) {
+	entries, errors := ParseRouteFile(strings.NewReader("new ^1 /users/new\nshow /users/:id\n"), DisallowUpperCase)
+	if len(errors) > 0 {
+		t.Fatalf("Errors parsing route file: %+v\n", errors)
+	}
+	routes, _ := ProcessRouteFiles([][]RouteFileEntry{entries}, []string{""}, "/")
+	rrs := GetRouteRegexps(routes, nil)
+	json, _ := RouteRegexpsToJSON(&rrs, nil)
+	if !strings.Contains(string(json), `"name":"new",`) || !strings.Contains(string(json), `"template":["/","users","/","new"],"priority":1}`) {
+		t.Errorf("Expected priority of 'new' route in JSON output, got\n%s\n", json)
+	}
+	if strings.Count(string(json), `"priority"`) != 1 {
+		t.Errorf("Expected priority to be omitted for route with default priority, got\n%s\n", json)
+	}
+}
+
+func TestMetadataInJSON(t *testing.T) {
+	entries, errors := ParseRouteFile(strings.NewReader("users /users [api, auth=admin, ratelimit=100]\n  .\n  public /public [auth=none]\n  show /:id\nhome /\n"), DisallowUpperCase)
+	if len(errors) > 0 {
+		t.Fatalf("Errors parsing route file: %+v\n", errors)
+	}
+	routes, _ := ProcessRouteFiles([][]RouteFileEntry{entries}, []string{""}, "/")
+	rrs := GetRouteRegexps(routes, nil)
+	json, _ := RouteRegexpsToJSON(&rrs, nil)
+	for _, expected := range []string{
+		`"template":["/","users"],"meta":{"auth":"admin","ratelimit":"100"}}`,
+		`"template":["/","users","/","public"],"meta":{"auth":"none","ratelimit":"100"}}`,
+		`"template":["/","users","/",[":","id"]],"meta":{"auth":"admin","ratelimit":"100"}}`,
+		`"tags":["api"],`,
+	} {
+		if !strings.Contains(string(json), expected) {
+			t.Errorf("Expected %v in JSON output, got\n%s\n", expected, json)
+		}
+	}
+	if strings.Count(string(json), `"meta"`) != 3 {
+		t.Errorf("Expected metadata to be omitted for route without metadata, got\n%s\n", json)
+	}
+
+	filter, err := ParseTagExpr("auth=admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rrs = GetRouteRegexps(routes, filter)
+	json, _ = RouteRegexpsToJSON(&rrs, filter)
+	if strings.Count(string(json), `"name"`) != 2 || !strings.Contains(string(json), `"name":"users/show"`) || !strings.Contains(string(json), `"name":"users"`) {
+		t.Errorf("Expected only routes with 'auth=admin' metadata in filtered output, got\n%s\n", json)
+	}
+}
+
+func TestTagInheritance(t *testing.T) {
+	const routeFile = `
+admin /admin [auth, .section, audit]
+  users /users
+  login /login [-auth]
+    help /help
+  logout /logout [-audit, -section, .public]
+`
+	entries, errors := ParseRouteFile(strings.NewReader(routeFile), DisallowUpperCase)
+	if len(errors) > 0 {
+		t.Fatalf("Errors parsing route file: %+v\n", errors)
+	}
+	routes, _ := ProcessRouteFiles([][]RouteFileEntry{entries}, []string{""}, "/")
+	tags := make(map[string][]string)
+	for _, r := range routes {
+		tags[r.Info.Name] = stringSetToList(r.Info.Tags)
+	}
+	expected := map[string][]string{
+		"admin":            {"audit", "auth", "section"},
+		"admin/users":      {"audit", "auth"},
+		"admin/login":      {"audit"},
+		"admin/login/help": {"audit"},
+		"admin/logout":     {"auth", "public"},
+	}
+	if !reflect.DeepEqual(tags, expected) {
+		t.Errorf("Unexpected tags %+v\n", tags)
+	}
+
+	filter, err := ParseTagExpr("!auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rrs := GetRouteRegexps(routes, filter)
+	json, _ := RouteRegexpsToJSON(&rrs, filter)
+	if strings.Count(string(json), `"name"`) != 1 || !strings.Contains(string(json), `"name":"admin/login/help","paramGroupNumbers":{},"paramKinds":{},"tags":["audit"]`) {
+		t.Errorf("Expected only the route without the 'auth' tag in filtered output, got\n%s\n", json)
+	}
+}
+
+func TestHosts(t *testing.T) {
+	const routeFile = `
+api @api.example.com /
+  users /users
+  about /about
+  status @status.example.com /status
+tenant @*.tenants.example.com /
+  home /home
+www @www.example.com,example.com /
+  about /about
+health /health
+`
+	entries, errors := ParseRouteFile(strings.NewReader(routeFile), DisallowUpperCase)
+	if len(errors) > 0 {
+		t.Fatalf("Errors parsing route file: %+v\n", errors)
+	}
+	routes, _ := ProcessRouteFiles([][]RouteFileEntry{entries}, []string{""}, "/")
+	hosts := make(map[string][]string)
+	for _, r := range routes {
+		hosts[r.Info.Name] = r.Info.Hosts
+	}
+	expected := map[string][]string{
+		"api":         {"api.example.com"},
+		"api/users":   {"api.example.com"},
+		"api/about":   {"api.example.com"},
+		"api/status":  {"status.example.com"},
+		"tenant":      {"*.tenants.example.com"},
+		"tenant/home": {"*.tenants.example.com"},
+		"www":         {"www.example.com", "example.com"},
+		"www/about":   {"www.example.com", "example.com"},
+		"health":      nil,
+	}
+	if !reflect.DeepEqual(hosts, expected) {
+		t.Errorf("Unexpected hosts %+v\n", hosts)
+	}
+	if errs := CheckForGroupErrors(routes); len(errs) != 0 {
+		t.Errorf("Expected no overlaps between routes with different hosts, got %+v\n", errs)
+	}
+
+	rrs := GetRouteRegexps(routes, nil)
+	json, _ := RouteRegexpsToJSON(&rrs, nil)
+	for _, expected := range []string{
+		`"template":["/","users"],"hosts":["api.example.com"]}`,
+		`"template":["/","about"],"hosts":["www.example.com","example.com"]}`,
+		`,"hosts":{"":{"constantPortionNGroups":`,
+		`,"*.tenants.example.com":{"constantPortionNGroups":`,
+		`,"api.example.com":{"constantPortionNGroups":`,
+		`,"example.com":{"constantPortionNGroups":`,
+		`,"status.example.com":{"constantPortionNGroups":`,
+		`,"www.example.com":{"constantPortionNGroups":`,
+	} {
+		if !strings.Contains(string(json), expected) {
+			t.Errorf("Expected %v in JSON output, got\n%s\n", expected, json)
+		}
+	}
+
+	// The table for each host contains only the routes that accept it.
+	for _, h := range rrs.hosts {
+		var names []string
+		for _, f := range h.families {
+			for _, m := range f.members {
+				names = append(names, m.name)
+			}
+		}
+		sort.Strings(names)
+		expected := map[string][]string{
+			"":                      {"health"},
+			"*.tenants.example.com": {"health", "tenant/home"},
+			"api.example.com":       {"api/about", "api/users", "health"},
+			"example.com":           {"health", "www/about"},
+			"status.example.com":    {"api/status", "health"},
+			"www.example.com":       {"health", "www/about"},
+		}[h.host]
+		if !slices.Equal(names, expected) {
+			t.Errorf("Expected routes %v for host %q, got %v\n", expected, h.host, names)
+		}
+	}
+
+	rrs = GetRouteRegexps(routes[len(routes)-1:], nil)
+	json, _ = RouteRegexpsToJSON(&rrs, nil)
+	if strings.Contains(string(json), `"hosts"`) {
+		t.Errorf("Expected hosts to be omitted from JSON output when no route has hosts, got\n%s\n", json)
+	}
+}
+
+func TestHostOverlaps(t *testing.T) {
+	assertGroupErrorKinds(t, ""+
+		"a @foo.example.com /users/:id\n"+
+		"b @bar.example.com,example.com /users/:name\n"+
+		"c @*.foo.example.com /users/:x\n",
+	)
+	assertGroupErrorKinds(t, ""+
+		"a @foo.example.com /users/:id\n"+
+		"b @*.example.com /users/:name\n",
+		OverlappingRoutes,
+	)
+	assertGroupErrorKinds(t, ""+
+		"a @*.example.com /users/:id\n"+
+		"b @*.example.com,example.com /users/:name\n",
+		OverlappingRoutes,
+	)
+	assertGroupErrorKinds(t, ""+
+		"a @foo.example.com /users/:id\n"+
+		"b /users/:name\n",
+		OverlappingRoutes,
+	)
+	assertGroupErrorKinds(t, ""+
+		"a @a.foo.example.com /users/:id\n"+
+		"b @*.example.com /users/:name\n",
+	)
+}
+
+func TestHostPatternMatches(t *testing.T) {
+	type tst struct {
+		pattern, host string
+		expected      bool
+	}
+
+	cases := []tst{
+		{"example.com", "example.com", true},
+		{"example.com", "foo.example.com", false},
+		{"*.example.com", "foo.example.com", true},
+		{"*.example.com", "example.com", false},
+		{"*.example.com", "foo.bar.example.com", false},
+		{"*.example.com", "fooexample.com", false},
+		{"*.example.com", "*.example.com", true},
+		{"*.bar.example.com", "*.example.com", false},
+		{"*.example.com", "*.bar.example.com", false},
+	}
+
+	for _, c := range cases {
+		if hostPatternMatches(c.pattern, c.host) != c.expected {
+			t.Errorf("Expected hostPatternMatches(%q, %q) to be %v\n", c.pattern, c.host, c.expected)
+		}
+	}
+}
+
 func TestDisjoinRegexpComplex(t *testing.T) {
 	parents := []*CompiledRoute{{Info: RouteInfo{Name: "xx"}, Compiled: RouteRegexp{MatchRegexp: "PREFIX\\/"}}}
 
@@ -666,14 +1234,14 @@ func TestDisjoinRegexpComplex(t *testing.T) {
 }
 
 func TestRouteMatching(t *testing.T) {
-	sl := routeElement{slash, "", 0, 0}
-	glob := routeElement{singleGlob, "", 0, 0}
-	dglob := routeElement{doubleGlob, "", 0, 0}
-	noslash := routeElement{noTrailingSlash, "", 0, 0}
-	c := func(s string) routeElement { return routeElement{constant, s, 0, 0} }
-	p := func(s string) routeElement { return routeElement{parameter, s, 0, 0} }
-	ip := func(s string) routeElement { return routeElement{integerParameter, s, 0, 0} }
-	rp := func(s string) routeElement { return routeElement{restParameter, s, 0, 0} }
+	sl := routeElement{kind: slash}
+	glob := routeElement{kind: singleGlob}
+	dglob := routeElement{kind: doubleGlob}
+	noslash := routeElement{kind: noTrailingSlash}
+	c := func(s string) routeElement { return routeElement{kind: constant, value: s} }
+	p := func(s string) routeElement { return routeElement{kind: parameter, value: s} }
+	ip := func(s string) routeElement { return routeElement{kind: integerParameter, value: s} }
+	rp := func(s string) routeElement { return routeElement{kind: restParameter, value: s} }
 
 	// Initial slash is not included in the raw regexps but is introduced when
 	// joining hierarchical routes, so there are no leading slashes in the
@@ -754,6 +1322,12 @@ func TestRouteMatching(t *testing.T) {
 
 	testMatchRoute(t, true, []routeElement{sl, c("foo"), sl, p("myparam"), sl, c("bar"), dglob}, "foo/myparamvalue/bar/a/lot/of/other/stuff", "myparamvalue")
 	testMatchRoute(t, true, []routeElement{sl, c("foo"), sl, p("myparam"), sl, c("bar"), sl, dglob}, "foo/myparamvalue/bar/a/lot/of/other/stuff", "myparamvalue")
+
+	withOptionalGroup := []routeElement{sl, c("posts"), {kind: optionalStart}, sl, c("page"), sl, ip("n"), {kind: optionalEnd}}
+	testMatchRoute(t, true, withOptionalGroup, "posts/page/3", "3")
+	testMatchRoute(t, true, withOptionalGroup, "posts", "")
+	testMatchRoute(t, false, withOptionalGroup, "posts/page")
+	testMatchRoute(t, false, withOptionalGroup, "posts/page/x")
 	testMatchRoute(t, true, []routeElement{sl, c("foo"), sl, p("myparam"), sl, c("bar"), dglob, c("term")}, "foo/myparamvalue/bar/a/lot/of/other/stuff/term", "myparamvalue")
 	testMatchRoute(t, true, []routeElement{sl, c("foo"), sl, p("myparam"), sl, c("bar"), sl, dglob, c("term")}, "foo/myparamvalue/bar/a/lot/of/other/stuff/term", "myparamvalue")
 	testMatchRoute(t, true, []routeElement{sl, c("foo"), sl, p("myparam"), sl, c("bar"), sl, dglob, sl, c("term")}, "foo/myparamvalue/bar/a/lot/of/other/stuff/term", "myparamvalue")
@@ -894,6 +1468,9 @@ func assertOverlap(t *testing.T, line1, line2 int, routeFile string) {
 	if line1 != err.Line || line2 != err.OtherLine {
 		t.Errorf("Expecting overlap between routes at lines %v and %v, got overlap between routes at lines %v and %v.\nRoutes:\n%v\n", line1, line2, err.Line, err.OtherLine, routeFile)
 	}
+	if err.Witness == "" {
+		t.Errorf("Expecting overlap error to include a URL matched by both routes.\nRoutes:\n%v\n", routeFile)
+	}
 }
 
 func assertNoOverlap(t *testing.T, routeFile string) {
@@ -0,0 +1,252 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+)
+
+// tsReservedIdents are the identifiers declared by every generated TypeScript
+// file.
+var tsReservedIdents = []string{"RouteName", "Route", "RawRouter", "match", "matchKey", "param", "integerParam", "restParam"}
+
+// RouteRegexpsToTS generates a TypeScript module that declares the route names,
+// a params type for each route, a Route type (a union of the possible results
+// of matching a URL, discriminated by route name), a urlFor function for each
+// route that doesn't contain a glob, and a match function that wraps the route
+// method of the JS router. The generated code is intended to be used with the
+// JSON output for the same routes and filter.
+func RouteRegexpsToTS(rrs *routeRegexps, filter *TagExpr) []byte {
+	names, variants := groupRouteVariants(getOutputRoutes(rrs, filter))
+
+	idents := newIdentAllocator(tsReservedIdents...)
+	typeIdents := make(map[*routeVariant]string)
+	urlForIdents := make(map[*routeVariant]string)
+	for _, name := range names {
+		for i, v := range variants[name] {
+			typeIdents[v] = idents.alloc(v.baseIdent(i) + "Params")
+			urlForIdents[v] = idents.alloc("urlFor" + v.baseIdent(i))
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("// Code generated by claney. DO NOT EDIT.\n\n")
+
+	nameLiterals := make([]string, len(names))
+	for i, name := range names {
+		nameLiterals[i] = string(appendJsonString(nil, name))
+	}
+	writeTSUnion(&sb, "RouteName", nameLiterals)
+
+	for _, name := range names {
+		for _, v := range variants[name] {
+			fmt.Fprintf(&sb, "// Parameters of the %v route (%v).\n", name, templateString(v.routes[0].template))
+			fmt.Fprintf(&sb, "export type %v = {", typeIdents[v])
+			for i, p := range v.routes[0].params {
+				if i != 0 {
+					sb.WriteByte(';')
+				}
+				typ := "string"
+				if p.kind == integerParameter {
+					typ = "number"
+				}
+				opt := ""
+				if p.optional {
+					opt = "?"
+				}
+				fmt.Fprintf(&sb, " %s%v: %v", appendJsonString(nil, p.name), opt, typ)
+			}
+			if len(v.routes[0].params) > 0 {
+				sb.WriteByte(' ')
+			}
+			sb.WriteString("};\n\n")
+		}
+	}
+
+	var members []string
+	seen := make(map[string]struct{})
+	for _, name := range names {
+		for _, v := range variants[name] {
+			for _, r := range v.routes {
+				member := fmt.Sprintf(
+					"{ name: %s; params: %v; query: string; anchor: string; tags: readonly [%v]; methods: readonly [%v] }",
+					appendJsonString(nil, name), typeIdents[v], tsStringList(r.tags), tsStringList(r.methods),
+				)
+				if _, ok := seen[member]; !ok {
+					seen[member] = struct{}{}
+					members = append(members, member)
+				}
+			}
+		}
+	}
+	sb.WriteString("// The result of matching a URL, discriminated by route name.\n")
+	writeTSUnion(&sb, "Route", members)
+
+	for _, name := range names {
+		for _, v := range variants[name] {
+			writeTSURLFor(&sb, name, v, typeIdents[v], urlForIdents[v])
+		}
+	}
+
+	writeTSMatch(&sb, names, variants)
+
+	return []byte(sb.String())
+}
+
+func writeTSURLFor(sb *strings.Builder, name string, v *routeVariant, typeIdent, urlForIdent string) {
+	buildable := v.buildableRoute()
+	if buildable == nil {
+		return
+	}
+
+	fmt.Fprintf(sb, "// Returns the path of the %v route.\n", name)
+	paramsIdent := "params"
+	if len(buildable.params) == 0 {
+		paramsIdent = "_params"
+	}
+	fmt.Fprintf(sb, "export function %v(%v: %v): string {\n", urlForIdent, paramsIdent, typeIdent)
+	var parts []string
+	var enclosingParts [][]string // the parts preceding each enclosing optional group
+	var conds []string            // the condition for including each enclosing optional group
+	var literal strings.Builder
+	flush := func() {
+		if literal.Len() > 0 {
+			parts = append(parts, string(appendJsonString(nil, literal.String())))
+			literal.Reset()
+		}
+	}
+	template := buildable.template
+	for i := 0; i < len(template); i++ {
+		e := template[i]
+		switch e.kind {
+		case slash:
+			literal.WriteByte('/')
+		case constant:
+			literal.WriteString(e.value)
+		case alternation:
+			literal.WriteString(e.values[0])
+		case parameter, integerParameter, restParameter:
+			flush()
+			fn := map[routeElementKind]string{parameter: "param", integerParameter: "integerParam", restParameter: "restParam"}[e.kind]
+			pn := appendJsonString(nil, e.value)
+			parts = append(parts, fmt.Sprintf("%v(%s, params[%s])", fn, pn, pn))
+		case optionalStart:
+			// An optional group is included if any of its parameters are given,
+			// so a group without parameters is never included.
+			params := optionalGroupParams(template, i)
+			if len(params) == 0 {
+				i = optionalGroupEnd(template, i)
+				continue
+			}
+			flush()
+			var cond []string
+			for _, name := range params {
+				cond = append(cond, fmt.Sprintf("params[%s] !== undefined", appendJsonString(nil, name)))
+			}
+			enclosingParts = append(enclosingParts, parts)
+			conds = append(conds, strings.Join(cond, " || "))
+			parts = nil
+		case optionalEnd:
+			flush()
+			group := fmt.Sprintf("(%v ? %v : \"\")", conds[len(conds)-1], strings.Join(parts, " + "))
+			parts = append(enclosingParts[len(enclosingParts)-1], group)
+			enclosingParts = enclosingParts[:len(enclosingParts)-1]
+			conds = conds[:len(conds)-1]
+		}
+	}
+	flush()
+	fmt.Fprintf(sb, "  return %v;\n}\n\n", strings.Join(parts, " + "))
+}
+
+func writeTSMatch(sb *strings.Builder, names []string, variants map[string][]*routeVariant) {
+	sb.WriteString(`// The interface of the JS router's Router class.
+export interface RawRouter {
+  route(url: string, host?: string): null | {
+    name: string;
+    params: Record<string, string | undefined>;
+    query: string;
+    anchor: string;
+    tags: string[];
+    methods: string[];
+  };
+}
+
+// Routes the given URL using the given router and returns the result with
+// typed parameters. Integer parameters are converted using Number(). Routes
+// restricted to particular hosts are considered only if they accept the given
+// host.
+export function match(router: RawRouter, url: string, host?: string): Route | null {
+  const r = router.route(url, host);
+  if (r === null) return null;
+  switch (matchKey(r.name, r.params)) {
+`)
+	for _, name := range names {
+		for _, v := range variants[name] {
+			fmt.Fprintf(sb, "    case %s:\n", appendJsonString(nil, name+"\x00"+v.routes[0].paramKey()))
+			var values []string
+			for _, p := range v.routes[0].params {
+				pn := appendJsonString(nil, p.name)
+				if p.kind == integerParameter && p.optional {
+					values = append(values, fmt.Sprintf("%s: r.params[%s] === undefined ? undefined : Number(r.params[%s])", pn, pn, pn))
+				} else if p.kind == integerParameter {
+					values = append(values, fmt.Sprintf("%s: Number(r.params[%s])", pn, pn))
+				} else {
+					values = append(values, fmt.Sprintf("%s: r.params[%s]", pn, pn))
+				}
+			}
+			ps := "{}"
+			if len(values) > 0 {
+				ps = "{ " + strings.Join(values, ", ") + " }"
+			}
+			fmt.Fprintf(sb, "      return { ...r, params: %v } as unknown as Route;\n", ps)
+		}
+	}
+	sb.WriteString(`  }
+  return null;
+}
+
+function matchKey(name: string, params: Record<string, string | undefined>): string {
+  return name + "\u0000" + Object.keys(params).sort().join("\u0000");
+}
+
+function param(name: string, value: string | undefined): string {
+  if (value === undefined) throw new Error("missing parameter value: " + name);
+  if (value === "") throw new Error("empty parameter value: " + name);
+  if (value.indexOf("/") !== -1) throw new Error("parameter value contains '/': " + name);
+  return encodeURIComponent(value);
+}
+
+function integerParam(name: string, value: number | undefined): string {
+  if (value === undefined) throw new Error("missing parameter value: " + name);
+  if (!Number.isInteger(value)) throw new Error("integer parameter value is not an integer: " + name);
+  return String(value);
+}
+
+function restParam(name: string, value: string | undefined): string {
+  if (value === undefined) throw new Error("missing parameter value: " + name);
+  if (value.replace(/\//g, "") === "") throw new Error("empty parameter value: " + name);
+  return value.split("/").map(encodeURIComponent).join("/");
+}
+`)
+}
+
+func writeTSUnion(sb *strings.Builder, typeIdent string, members []string) {
+	fmt.Fprintf(sb, "export type %v =", typeIdent)
+	if len(members) == 0 {
+		sb.WriteString(" never")
+	}
+	for _, m := range members {
+		sb.WriteString("\n  | " + m)
+	}
+	sb.WriteString(";\n\n")
+}
+
+func tsStringList(strs []string) string {
+	var sb strings.Builder
+	for i, s := range strs {
+		if i != 0 {
+			sb.WriteString(", ")
+		}
+		sb.Write(appendJsonString(nil, s))
+	}
+	return sb.String()
+}
@@ -0,0 +1,79 @@
+package compiler
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRouteRegexpsToTS(t *testing.T) {
+	const routeFile = `
+managers /managers/
+  .
+  user     /:manager_id/user/:#user_id [admin]
+  files    /:manager_id/files/:**path
+  glob     /glob/*
+posts [GET,POST] /posts
+posts /posts/:#n
+`
+
+	entries, errs := ParseRouteFile(strings.NewReader(routeFile), DisallowUpperCase)
+	if len(errs) > 0 {
+		t.Fatalf("%+v\n", errs)
+	}
+	routes, errs := ProcessRouteFiles([][]RouteFileEntry{entries}, []string{""}, "/")
+	if len(errs) > 0 {
+		t.Fatalf("%+v\n", errs)
+	}
+	rrs := GetRouteRegexps(routes, nil)
+	src := string(RouteRegexpsToTS(&rrs, nil))
+
+	for _, expected := range []string{
+		"export type RouteName =\n  | \"managers\"\n  | \"managers/user\"\n  | \"managers/files\"\n  | \"managers/glob\"\n  | \"posts\";\n",
+		`export type ManagersUserParams = { "manager_id": string; "user_id": number };`,
+		`export type PostsParams = {};`,
+		`export type PostsWithNParams = { "n": number };`,
+		`  | { name: "managers/user"; params: ManagersUserParams; query: string; anchor: string; tags: readonly ["admin"]; methods: readonly ["GET"] }`,
+		`  | { name: "posts"; params: PostsParams; query: string; anchor: string; tags: readonly []; methods: readonly ["GET", "POST"] }`,
+		`  return "/managers/" + param("manager_id", params["manager_id"]) + "/user/" + integerParam("user_id", params["user_id"]);`,
+		`  return "/managers/" + param("manager_id", params["manager_id"]) + "/files/" + restParam("path", params["path"]);`,
+		"export function urlForPosts(_params: PostsParams): string {\n  return \"/posts\";\n}",
+		`    case "posts\u0000n":` + "\n" + `      return { ...r, params: { "n": Number(r.params["n"]) } } as unknown as Route;`,
+		"export function match(router: RawRouter, url: string, host?: string): Route | null {\n  const r = router.route(url, host);",
+	} {
+		if !strings.Contains(src, expected) {
+			t.Errorf("Expected generated code to contain\n%v\n", expected)
+		}
+	}
+
+	if strings.Contains(src, "urlForManagersGlob") {
+		t.Errorf("Expected no URL builder for route containing glob\n")
+	}
+}
+
+func TestRouteRegexpsToTSOptionalGroups(t *testing.T) {
+	const routeFile = `
+search /search[/:q[/:#page]]
+`
+
+	entries, errs := ParseRouteFile(strings.NewReader(routeFile), DisallowUpperCase)
+	if len(errs) > 0 {
+		t.Fatalf("%+v\n", errs)
+	}
+	routes, errs := ProcessRouteFiles([][]RouteFileEntry{entries}, []string{""}, "/")
+	if len(errs) > 0 {
+		t.Fatalf("%+v\n", errs)
+	}
+	rrs := GetRouteRegexps(routes, nil)
+	src := string(RouteRegexpsToTS(&rrs, nil))
+
+	for _, expected := range []string{
+		`export type SearchParams = { "q"?: string; "page"?: number };`,
+		`  return "/search" + (params["q"] !== undefined || params["page"] !== undefined ? "/" + param("q", params["q"]) + (params["page"] !== undefined ? "/" + integerParam("page", params["page"]) : "") : "");`,
+		`      return { ...r, params: { "q": r.params["q"], "page": r.params["page"] === undefined ? undefined : Number(r.params["page"]) } } as unknown as Route;`,
+		"    params: Record<string, string | undefined>;\n",
+	} {
+		if !strings.Contains(src, expected) {
+			t.Errorf("Expected generated code to contain\n%v\n", expected)
+		}
+	}
+}
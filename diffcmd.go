@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/addrummond/claney/compiler"
+)
+
+func diffMain(args []string) int {
+	fs := flag.NewFlagSet("claney diff", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: claney diff [options] old new\n\nEach of old and new may be a route file, a JSON route file, an OpenAPI document, or compiled JSON output.\n\n")
+		fs.PrintDefaults()
+	}
+	allowUpperCase := fs.Bool("allow-upper-case", false, "allow upper case characters in routes")
+	nameSeparator := fs.String("name-separator", "", "name separator (default \"/\")")
+	filter := fs.String("filter", "", "include only routes with tags that match the given expression")
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return 1
+	}
+
+	params := runParams{
+		diffFiles:      fs.Args(),
+		filter:         *filter,
+		allowUpperCase: *allowUpperCase,
+		nameSeparator:  *nameSeparator,
+		withReader:     withReader,
+		withWriter:     withWriter,
+		fprintf:        fmt.Fprintf,
+	}
+	if params.nameSeparator == "" {
+		params.nameSeparator = "/"
+	}
+
+	return runDiff(params)
+}
+
+func runDiff(params runParams) int {
+	var sets [2]compiler.RouteSet
+	for i, filename := range params.diffFiles {
+		var ok bool
+		sets[i], ok = readRouteSet(params, filename)
+		if !ok {
+			return 1
+		}
+	}
+
+	changes, err := compiler.DiffRouteSets(&sets[0], &sets[1])
+	if err != nil {
+		_, _ = params.fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	if len(changes) == 0 {
+		_, _ = params.fprintf(os.Stdout, "No changes\n")
+		return 0
+	}
+
+	nBreaking := 0
+	for _, c := range changes {
+		_, _ = params.fprintf(os.Stdout, "%v\n", c)
+		if c.Breaking {
+			nBreaking++
+		}
+	}
+
+	changesString := "changes"
+	if len(changes) == 1 {
+		changesString = "change"
+	}
+	_, _ = params.fprintf(os.Stdout, "\n%v %v, %v breaking\n", len(changes), changesString, nBreaking)
+
+	if nBreaking > 0 {
+		return 1
+	}
+	return 0
+}
+
+// readRouteSet reads the routes from a file in any of the input formats or
+// from compiled JSON output. Compiled JSON output and OpenAPI documents are
+// JSON objects, JSON route files are JSON arrays, and anything else is taken
+// to be a route file.
+func readRouteSet(params runParams, filename string) (compiler.RouteSet, bool) {
+	var contents []byte
+	var readErr error
+	err := params.withReader(filename, func(r io.Reader) {
+		contents, readErr = io.ReadAll(r)
+	})
+	if err == nil {
+		err = readErr
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return compiler.RouteSet{}, false
+	}
+
+	fileParams := params
+	fileParams.fancyInputFiles = nil
+	fileParams.jsonInputFiles = nil
+	fileParams.openAPIInputFiles = nil
+	readers := [3][]io.Reader{}
+	reader := []io.Reader{bytes.NewReader(contents)}
+
+	trimmed := bytes.TrimSpace(contents)
+	switch {
+	case len(trimmed) > 0 && trimmed[0] == '{':
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(contents, &fields); err != nil {
+			_, _ = params.fprintf(os.Stderr, "%v: %v\n", filename, err)
+			return compiler.RouteSet{}, false
+		}
+		if _, ok := fields["openapi"]; ok {
+			fileParams.openAPIInputFiles = []string{filename}
+			readers[2] = reader
+			break
+		}
+
+		filter, err := compiler.ParseTagExpr(params.filter)
+		if err != nil {
+			params.fprintf(os.Stderr, "Error parsing value of -filter option:\n%v\n", err)
+			return compiler.RouteSet{}, false
+		}
+		rs, err := compiler.RouteSetFromJSON(contents, filter)
+		if err != nil {
+			_, _ = params.fprintf(os.Stderr, "%v: %v\n", filename, err)
+			return compiler.RouteSet{}, false
+		}
+		return rs, true
+	case len(trimmed) > 0 && trimmed[0] == '[':
+		fileParams.jsonInputFiles = []string{filename}
+		readers[1] = reader
+	default:
+		fileParams.fancyInputFiles = []string{filename}
+		readers[0] = reader
+	}
+
+	routes, filter, ok := compileInputFiles(fileParams, os.Stderr, readers[0], readers[1], readers[2])
+	if !ok {
+		return compiler.RouteSet{}, false
+	}
+	routeRegexps := compiler.GetRouteRegexps(routes, filter)
+	rs, err := compiler.RouteRegexpsToRouteSet(&routeRegexps, filter)
+	if err != nil {
+		_, _ = params.fprintf(os.Stderr, "%v: %v\n", filename, err)
+		return compiler.RouteSet{}, false
+	}
+	return rs, true
+}
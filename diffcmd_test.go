@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRunDiff(t *testing.T) {
+	const oldRoutes = `[{"name": "a", "terminal": true, "pattern": "/a/:x"}, {"name": "b", "terminal": true, "pattern": "/b"}]`
+	const newRoutes = "a /a/:#x\nb [GET,POST] /b\n"
+
+	var consoleOutb strings.Builder
+	exitCode := runDiff(runParams{
+		diffFiles:     []string{"old", "new"},
+		withReader:    mockMultifileReader(map[string]string{"old": oldRoutes, "new": newRoutes}),
+		fprintf:       getAccumFprintf(&consoleOutb),
+		nameSeparator: "/",
+	})
+	if exitCode != 1 {
+		t.Fatalf("Expected 1 exit code, got %v\n%v\n", exitCode, consoleOutb.String())
+	}
+
+	const expectedConsoleOut = "~ a (GET /a/:x): pattern changed to /a/:#x (parameter kinds changed)\n" +
+		"    BREAKING: GET /a/a is no longer matched\n" +
+		"~ b (GET /b): methods changed to GET, POST\n" +
+		"\n2 changes, 1 breaking\n"
+	if consoleOut := consoleOutb.String(); consoleOut != expectedConsoleOut {
+		t.Fatalf("Did not get expected output, got\n%v\n", consoleOut)
+	}
+}
+
+func TestRunDiffCompiledJSON(t *testing.T) {
+	var outb strings.Builder
+	exitCode := run(runParams{
+		fancyInputFiles: []string{"routes"},
+		withReader:      mockMultifileReader(map[string]string{"routes": exampleInput}),
+		withWriter:      mockWriter(&outb),
+		fprintf:         dummyFprintf,
+		nameSeparator:   "/",
+	})
+	if exitCode != 0 {
+		t.Fatalf("Expected 0 exit code, got %v\n", exitCode)
+	}
+
+	var consoleOutb strings.Builder
+	exitCode = runDiff(runParams{
+		diffFiles:     []string{"old", "new"},
+		withReader:    mockMultifileReader(map[string]string{"old": outb.String(), "new": exampleInput}),
+		fprintf:       getAccumFprintf(&consoleOutb),
+		nameSeparator: "/",
+	})
+	if exitCode != 0 {
+		t.Fatalf("Expected 0 exit code, got %v\n%v\n", exitCode, consoleOutb.String())
+	}
+	if consoleOut := consoleOutb.String(); consoleOut != "No changes\n" {
+		t.Fatalf("Did not get expected output, got\n%v\n", consoleOut)
+	}
+}
@@ -1,8 +1,48 @@
+// The JSON output by the claney compiler.
+export interface RouterJson {
+  constantPortionNGroups: number,
+  constantPortionRegexp: string,
+  families: Record<string, RouteFamily>,
+  // Present only if some route is restricted to a host. Each host pattern maps
+  // to the routes for that host, and "" maps to the routes with no host.
+  hosts?: Record<string, RouterJson>
+}
+
+export interface RouteFamily {
+  matchRegexp: string,
+  nLevels: number,
+  nonparamGroupNumbers: number[],
+  members: RouteMember[],
+  methodFamilies?: Record<string, RouteFamily>
+}
+
+export type ParamKind = "string" | "integer" | "rest" | "uuid" | "slug" | "date" | "hex"
+
+// Either "/", a constant string, or an array whose first element is one of
+// ":", ":~", ":#", ":**", ":uuid", ":slug", ":date", ":hex", "*", "**", "[",
+// "]" or "|".
+export type TemplateElement = string | string[]
+
+export interface RouteMember {
+  name: string,
+  paramGroupNumbers: Record<string, number>,
+  paramKinds: Record<string, ParamKind>,
+  tags: string[],
+  methods: string[],
+  template: TemplateElement[],
+  alternatives?: string[],
+  priority?: number,
+  meta?: Record<string, string>,
+  hosts?: string[]
+}
+
 export class Router {
-  constructor(json : object, caseSensitive? : boolean)
-  route(url : string) : null | {
+  constructor(json : RouterJson, caseSensitive? : boolean)
+  route(url : string, host? : string) : null | {
     name: string,
-    params: Record<string, string>,
+    // A parameter in an optional group that is absent from the URL is
+    // undefined.
+    params: Record<string, string | undefined>,
     query : string,
     anchor : string,
     tags: string[],
@@ -10,4 +50,5 @@ export class Router {
   }
 }
 
+export function normalizeHost(host: string) : string
 export function normalizeUrl(url: string) : string
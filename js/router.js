@@ -3,18 +3,30 @@ export class Router {
     this.json = json;
     this.caseSensitive = caseSensitive;
 
-    this.cpr = new RegExp(json.constantPortionRegexp);
-    this.groupRegexps = { };
-    for (const cp of Object.keys(json.families)) {
-      this.groupRegexps[cp] = new RegExp(json.families[cp].matchRegexp);
+    // If some routes are restricted to particular hosts then there is a table
+    // for each host pattern, and the table for the empty pattern holds the
+    // routes that accept any host.
+    this.hostTables = null;
+    if (json.hosts !== undefined) {
+      this.hostTables = { };
+      for (const h of Object.keys(json.hosts)) {
+        this.hostTables[h] = makeTable(json.hosts[h]);
+      }
+      this.table = this.hostTables[""];
+    } else {
+      this.table = makeTable(json);
     }
   }
 
-  route(url) {
+  // Routes a URL. Routes restricted to particular hosts are considered only if
+  // they accept the given host (which may include a port).
+  route(url, host) {
     if (! this.caseSensitive)
       url = normalizeUrl(url)
 
-    const m = url.match(this.cpr);
+    const table = host === undefined ? this.table : this.#hostTable(host);
+
+    const m = url.match(table.cpr);
     if (m === null)
       return null;
 
@@ -22,11 +34,11 @@ export class Router {
     // manually incrementing an index (confirmed by benchmarking).
     const cp = m.join('').substring(m[0].length);
 
-    const family = this.json.families[cp];
+    const family = table.families[cp];
     if (family === undefined)
       return null;
 
-    const submatches = url.match(this.groupRegexps[cp]);
+    const submatches = url.match(table.groupRegexps[cp]);
     if (submatches === null)
       return null;
   
@@ -51,6 +63,26 @@ export class Router {
     };
   }
 
+  // Returns the table for the host itself if there is one, then the table for
+  // the wildcard pattern that matches it (e.g. '*.foo.com' for 'bar.foo.com'),
+  // and otherwise the table for routes that accept any host.
+  #hostTable(host) {
+    if (this.hostTables === null)
+      return this.table;
+
+    host = normalizeHost(host);
+    const t = this.hostTables[host];
+    if (t !== undefined)
+      return t;
+    const dot = host.indexOf('.');
+    if (dot > 0) {
+      const wt = this.hostTables["*" + host.substring(dot)];
+      if (wt !== undefined)
+        return wt;
+    }
+    return this.table;
+  }
+
   #findGroupIndex(match, nonParamGroupNumbers, nLevels) {
     // binary search
     let mi = 0; // start of match group range
@@ -72,6 +104,28 @@ export class Router {
   }
 }
 
+function makeTable(json) {
+  const groupRegexps = { };
+  for (const cp of Object.keys(json.families)) {
+    groupRegexps[cp] = new RegExp(json.families[cp].matchRegexp);
+  }
+  return {
+    cpr: new RegExp(json.constantPortionRegexp),
+    families: json.families,
+    groupRegexps
+  };
+}
+
+// Normalizes a host to lower case and removes any port and any trailing '.'.
+export function normalizeHost(host) {
+  const c = host.lastIndexOf(':');
+  if (c !== -1 && host.indexOf(']', c) === -1)
+    host = host.substring(0, c);
+  if (host.endsWith('.'))
+    host = host.substring(0, host.length-1);
+  return host.toLowerCase();
+}
+
 export function normalizeUrl(url) {
   const q = url.indexOf('?')
   if (q === -1)
@@ -1,4 +1,4 @@
-import { Router, normalizeUrl } from './router';
+import { Router, normalizeHost, normalizeUrl } from './router';
 
 describe('router', () => {
   // Keep in sync with value of 'routesJson' var in TestRouter in router/router_test.go
@@ -212,6 +212,56 @@ describe('case sensitive router', () => {
   });
 });
 
+describe('hosts', () => {
+  // Output of claney for the routes
+  //
+  //   api @api.example.com /about
+  //   www @www.example.com,*.www.example.com /about
+  //   health /health
+  const ROUTE_INFO = {"constantPortionNGroups":1,"constantPortionRegexp":"^(?:\\/+(?:(about|about|health)\\/*))(?:\\?[^#]*)?(?:#.*)?$","families":{"about":{"matchRegexp":"^(?:(\\/+about\\/*)|(\\/+about\\/*))(\\?[^#]*)?(#.*)?$","nLevels":1,"nonparamGroupNumbers":[1,2],"members":[{"name":"api","paramGroupNumbers":{},"paramKinds":{},"tags":[],"methods":["GET"],"template":["/","about"],"hosts":["api.example.com"]},{"name":"www","paramGroupNumbers":{},"paramKinds":{},"tags":[],"methods":["GET"],"template":["/","about"],"hosts":["www.example.com","*.www.example.com"]}]},"health":{"matchRegexp":"^(?:(\\/+health\\/*))(\\?[^#]*)?(#.*)?$","nLevels":1,"nonparamGroupNumbers":[1],"members":[{"name":"health","paramGroupNumbers":{},"paramKinds":{},"tags":[],"methods":["GET"],"template":["/","health"]}]}},"hosts":{"":{"constantPortionNGroups":1,"constantPortionRegexp":"^(?:\\/+(?:(health)\\/*))(?:\\?[^#]*)?(?:#.*)?$","families":{"health":{"matchRegexp":"^(?:(\\/+health\\/*))(\\?[^#]*)?(#.*)?$","nLevels":1,"nonparamGroupNumbers":[1],"members":[{"name":"health","paramGroupNumbers":{},"paramKinds":{},"tags":[],"methods":["GET"],"template":["/","health"]}]}}},"*.www.example.com":{"constantPortionNGroups":2,"constantPortionRegexp":"^(?:\\/+(?:(about)\\/*|(health)\\/*))(?:\\?[^#]*)?(?:#.*)?$","families":{"about":{"matchRegexp":"^(?:(\\/+about\\/*))(\\?[^#]*)?(#.*)?$","nLevels":1,"nonparamGroupNumbers":[1],"members":[{"name":"www","paramGroupNumbers":{},"paramKinds":{},"tags":[],"methods":["GET"],"template":["/","about"],"hosts":["www.example.com","*.www.example.com"]}]},"health":{"matchRegexp":"^(?:(\\/+health\\/*))(\\?[^#]*)?(#.*)?$","nLevels":1,"nonparamGroupNumbers":[1],"members":[{"name":"health","paramGroupNumbers":{},"paramKinds":{},"tags":[],"methods":["GET"],"template":["/","health"]}]}}},"api.example.com":{"constantPortionNGroups":2,"constantPortionRegexp":"^(?:\\/+(?:(about)\\/*|(health)\\/*))(?:\\?[^#]*)?(?:#.*)?$","families":{"about":{"matchRegexp":"^(?:(\\/+about\\/*))(\\?[^#]*)?(#.*)?$","nLevels":1,"nonparamGroupNumbers":[1],"members":[{"name":"api","paramGroupNumbers":{},"paramKinds":{},"tags":[],"methods":["GET"],"template":["/","about"],"hosts":["api.example.com"]}]},"health":{"matchRegexp":"^(?:(\\/+health\\/*))(\\?[^#]*)?(#.*)?$","nLevels":1,"nonparamGroupNumbers":[1],"members":[{"name":"health","paramGroupNumbers":{},"paramKinds":{},"tags":[],"methods":["GET"],"template":["/","health"]}]}}},"www.example.com":{"constantPortionNGroups":2,"constantPortionRegexp":"^(?:\\/+(?:(about)\\/*|(health)\\/*))(?:\\?[^#]*)?(?:#.*)?$","families":{"about":{"matchRegexp":"^(?:(\\/+about\\/*))(\\?[^#]*)?(#.*)?$","nLevels":1,"nonparamGroupNumbers":[1],"members":[{"name":"www","paramGroupNumbers":{},"paramKinds":{},"tags":[],"methods":["GET"],"template":["/","about"],"hosts":["www.example.com","*.www.example.com"]}]},"health":{"matchRegexp":"^(?:(\\/+health\\/*))(\\?[^#]*)?(#.*)?$","nLevels":1,"nonparamGroupNumbers":[1],"members":[{"name":"health","paramGroupNumbers":{},"paramKinds":{},"tags":[],"methods":["GET"],"template":["/","health"]}]}}}}};
+  const router = new Router(ROUTE_INFO);
+
+  const result = (name) => ({
+    name,
+    methods: ["GET"],
+    params: {},
+    query: "",
+    anchor: "",
+    tags: []
+  });
+
+  test('routes with different hosts can share a path', () => {
+    expect(router.route("/about", "api.example.com")).toEqual(result("api"));
+    expect(router.route("/about", "WWW.example.com:8080")).toEqual(result("www"));
+    expect(router.route("/about", "foo.www.example.com")).toEqual(result("www"));
+    expect(router.route("/about", "other.com")).toBeNull();
+    expect(router.route("/health", "api.example.com")).toEqual(result("health"));
+    expect(router.route("/health", "other.com")).toEqual(result("health"));
+  });
+
+  test('routes restricted to particular hosts are ignored if no host is given', () => {
+    expect(router.route("/about")).toBeNull();
+    expect(router.route("/health")).toEqual(result("health"));
+  });
+});
+
+describe('normalizeHost', () => {
+  test('yields expected results', () => {
+    const cases = [
+      ["", ""],
+      ["Example.COM", "example.com"],
+      ["example.com:8080", "example.com"],
+      ["example.com.", "example.com"],
+      ["[::1]", "[::1]"],
+      ["[::1]:8080", "[::1]"],
+    ];
+
+    for (const [from, to] of cases) {
+      expect(normalizeHost(from)).toEqual(to);
+    }
+  });
+});
+
 describe('normalizeUrl', () => {
   test('yields expected results', () => {
     const cases = [
@@ -6,17 +6,28 @@ function Router(json, caseSensitive) {
 
   this.json = json;
 
-  this.cpr = new RegExp(json.constantPortionRegexp);
-  const groupRegexps = { };
-  this.groupRegexps = groupRegexps;
-  Object.keys(json.families).forEach(function (cp) {
-    groupRegexps[cp] = new RegExp(json.families[cp].matchRegexp);
-  });
-
-  this.route = function (url) {
+  // If some routes are restricted to particular hosts then there is a table
+  // for each host pattern, and the table for the empty pattern holds the
+  // routes that accept any host.
+  const hostTables = { };
+  let anyHostTable;
+  if (json.hosts !== undefined) {
+    Object.keys(json.hosts).forEach(function (h) {
+      hostTables[h] = makeTable(json.hosts[h]);
+    });
+    anyHostTable = hostTables[""];
+  } else {
+    anyHostTable = makeTable(json);
+  }
+
+  // Routes a URL. Routes restricted to particular hosts are considered only if
+  // they accept the given host (which may include a port).
+  this.route = function (url, host) {
     url = normalizeUrl(url)
 
-    const m = url.match(this.cpr);
+    const table = host === undefined ? anyHostTable : hostTable(host);
+
+    const m = url.match(table.cpr);
     if (m === null)
       return null;
 
@@ -24,11 +35,11 @@ function Router(json, caseSensitive) {
     // manually incrementing an index (confirmed by benchmarking).
     const cp = m.join('').substring(m[0].length);
 
-    const family = this.json.families[cp];
+    const family = table.families[cp];
     if (family === undefined)
       return null;
 
-    const submatches = url.match(this.groupRegexps[cp]);
+    const submatches = url.match(table.groupRegexps[cp]);
     if (submatches === null)
       return null;
   
@@ -53,6 +64,31 @@ function Router(json, caseSensitive) {
     };
   };
 
+  function makeTable(json) {
+    const groupRegexps = { };
+    Object.keys(json.families).forEach(function (cp) {
+      groupRegexps[cp] = new RegExp(json.families[cp].matchRegexp);
+    });
+    return {
+      cpr: new RegExp(json.constantPortionRegexp),
+      families: json.families,
+      groupRegexps: groupRegexps
+    };
+  }
+
+  // Returns the table for the host itself if there is one, then the table for
+  // the wildcard pattern that matches it (e.g. '*.foo.com' for 'bar.foo.com'),
+  // and otherwise the table for routes that accept any host.
+  function hostTable(host) {
+    host = normalizeHost(host);
+    if (hostTables[host] !== undefined)
+      return hostTables[host];
+    const dot = host.indexOf('.');
+    if (dot > 0 && hostTables["*" + host.substring(dot)] !== undefined)
+      return hostTables["*" + host.substring(dot)];
+    return anyHostTable;
+  }
+
   function findGroupIndex(match, nonParamGroupNumbers, nLevels) {
     // binary search
     let mi = 0; // start of match group range
@@ -74,6 +110,15 @@ function Router(json, caseSensitive) {
   }
 }
 
+function normalizeHost(host) {
+  const c = host.lastIndexOf(':');
+  if (c !== -1 && host.indexOf(']', c) === -1)
+    host = host.substring(0, c);
+  if (host.endsWith('.'))
+    host = host.substring(0, host.length-1);
+  return host.toLowerCase();
+}
+
 function normalizeUrl(url) {
   const q = url.indexOf('?')
   if (q === -1)
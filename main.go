@@ -27,18 +27,77 @@ func (ia *inputAccum) Set(s string) error {
 	return nil
 }
 
+// inputFlags holds the values of the flags that specify the route files to
+// compile. They are shared by the main command and the subcommands.
+type inputFlags struct {
+	allowUpperCase    *bool
+	nameSeparator     *string
+	fancyInputFiles   *inputAccum
+	jsonInputFiles    *inputAccum
+	openAPIInputFiles *inputAccum
+	jsonStdin         *bool
+	filter            *string
+}
+
+func addInputFlags(fs *flag.FlagSet) *inputFlags {
+	f := &inputFlags{
+		allowUpperCase:    fs.Bool("allow-upper-case", false, "allow upper case characters in routes"),
+		nameSeparator:     fs.String("name-separator", "", "name separator (default \"/\")"),
+		fancyInputFiles:   &inputAccum{},
+		jsonInputFiles:    &inputAccum{},
+		openAPIInputFiles: &inputAccum{},
+	}
+	fs.Var(f.fancyInputFiles, "input", "input file (default stdin)")
+	fs.Var(f.jsonInputFiles, "json-input", "JSON input file")
+	fs.Var(f.openAPIInputFiles, "openapi-input", "OpenAPI 3 JSON input file")
+	f.jsonStdin = fs.Bool("json-stdin", false, "interpret stdin as JSON (as with -json-input)")
+	f.filter = fs.String("filter", "", "include only routes with tags that match the given expression")
+	return f
+}
+
+// setInputParams sets the fields of params that specify the route files to
+// compile. Stdin is read if no input files are given.
+func (f *inputFlags) setInputParams(params *runParams) {
+	params.allowUpperCase = *f.allowUpperCase
+	params.nameSeparator = *f.nameSeparator
+	if params.nameSeparator == "" {
+		params.nameSeparator = "/"
+	}
+	params.filter = *f.filter
+
+	if len(f.fancyInputFiles.filenames) == 0 && len(f.jsonInputFiles.filenames) == 0 && len(f.openAPIInputFiles.filenames) == 0 {
+		if *f.jsonStdin {
+			params.jsonInputFiles = []string{""} // indicates stdin
+		} else {
+			params.fancyInputFiles = []string{""} // indicates stdin
+		}
+	} else {
+		params.fancyInputFiles = f.fancyInputFiles.filenames
+		params.jsonInputFiles = f.jsonInputFiles.filenames
+		params.openAPIInputFiles = f.openAPIInputFiles.filenames
+	}
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "test":
+			os.Exit(testMain(os.Args[2:]))
+		case "route":
+			os.Exit(routeMain(os.Args[2:]))
+		case "diff":
+			os.Exit(diffMain(os.Args[2:]))
+		}
+	}
+
 	version := flag.Bool("version", false, "show version information")
 	verbose := flag.Bool("verbose", false, "print diagnostic information")
-	allowUpperCase := flag.Bool("allow-upper-case", false, "allow upper case characters in routes")
-	nameSeparator := flag.String("name-separator", "", "name separator (default \"/\")")
-	fancyInputFiles := &inputAccum{}
-	jsonInputFiles := &inputAccum{}
-	flag.Var(fancyInputFiles, "input", "input file (default stdin)")
-	flag.Var(jsonInputFiles, "json-input", "JSON input file")
-	jsonStdin := flag.Bool("json-stdin", false, "interpret stdin as JSON (as with -json-input)")
+	inputs := addInputFlags(flag.CommandLine)
 	output := flag.String("output", "", "output file (default stdout)")
-	filter := flag.String("filter", "", "include only routes with tags that match the given expression")
+	goOutput := flag.String("go-out", "", "also write Go code for the routes to the given file")
+	goPackage := flag.String("go-package", "routes", "package name for the Go code written by -go-out")
+	tsOutput := flag.String("ts-out", "", "also write TypeScript code for the routes to the given file")
+	openAPIOutput := flag.String("openapi-out", "", "also write an OpenAPI 3 document for the routes to the given file")
 	flag.Parse()
 
 	// Claney doesn't take any bare arguments, so print the usage message and exit
@@ -48,49 +107,49 @@ func main() {
 		os.Exit(1)
 	}
 
-	if *nameSeparator == "" {
-		*nameSeparator = "/"
+	if *goOutput != "" && !compiler.IsGoPackageName(*goPackage) {
+		fmt.Fprintf(os.Stderr, "invalid value %q for -go-package: must be a Go identifier that is not a keyword\n", *goPackage)
+		os.Exit(1)
 	}
 
-	var fancyFilenames []string
-	var jsonFilenames []string
-	if len(fancyInputFiles.filenames) == 0 && len(jsonInputFiles.filenames) == 0 {
-		if *jsonStdin {
-			jsonFilenames = []string{""} // indicates stdin
-		} else {
-			fancyFilenames = []string{""} // indicates stdin
-		}
-	} else {
-		fancyFilenames = fancyInputFiles.filenames
-		jsonFilenames = jsonInputFiles.filenames
-	}
-
-	os.Exit(run(runParams{
-		version:         *version,
-		fancyInputFiles: fancyFilenames,
-		jsonInputFiles:  jsonFilenames,
-		output:          *output,
-		filter:          *filter,
-		verbose:         *verbose,
-		allowUpperCase:  *allowUpperCase,
-		withReader:      withReader,
-		withWriter:      withWriter,
-		fprintf:         fmt.Fprintf,
-		nameSeparator:   *nameSeparator}))
+	params := runParams{
+		version:       *version,
+		output:        *output,
+		goOutput:      *goOutput,
+		goPackage:     *goPackage,
+		tsOutput:      *tsOutput,
+		openAPIOutput: *openAPIOutput,
+		verbose:       *verbose,
+		withReader:    withReader,
+		withWriter:    withWriter,
+		fprintf:       fmt.Fprintf,
+	}
+	inputs.setInputParams(&params)
+
+	os.Exit(run(params))
 }
 
 type runParams struct {
-	version         bool
-	fancyInputFiles []string
-	jsonInputFiles  []string
-	output          string
-	filter          string
-	verbose         bool
-	allowUpperCase  bool
-	withReader      func(string, func(io.Reader)) error
-	withWriter      func(string, func(io.Writer)) error
-	fprintf         func(w io.Writer, format string, a ...interface{}) (int, error)
-	nameSeparator   string
+	version           bool
+	fancyInputFiles   []string
+	jsonInputFiles    []string
+	openAPIInputFiles []string
+	output            string
+	filter            string
+	goOutput          string
+	goPackage         string
+	tsOutput          string
+	openAPIOutput     string
+	testFiles         []string
+	routeRequests     []string
+	routeHost         string
+	diffFiles         []string
+	verbose           bool
+	allowUpperCase    bool
+	withReader        func(string, func(io.Reader)) error
+	withWriter        func(string, func(io.Writer)) error
+	fprintf           func(w io.Writer, format string, a ...interface{}) (int, error)
+	nameSeparator     string
 }
 
 func run(params runParams) int {
@@ -111,10 +170,8 @@ func run(params runParams) int {
 		return 0
 	}
 
-	err := withReaders([]io.Reader{}, params.fancyInputFiles, params.withReader, func(fancyInputReaders []io.Reader) {
-		withReaders([]io.Reader{}, params.jsonInputFiles, params.withReader, func(jsonInputReaders []io.Reader) {
-			exitCode = runHelper(params, fancyInputReaders, jsonInputReaders)
-		})
+	err := withInputReaders(params, func(fancyInputReaders, jsonInputReaders, openAPIInputReaders []io.Reader) {
+		exitCode = runHelper(params, fancyInputReaders, jsonInputReaders, openAPIInputReaders)
 	})
 
 	if err != nil {
@@ -125,12 +182,18 @@ func run(params runParams) int {
 	return exitCode
 }
 
-func parseInputFiles(fancyInputFiles []string, jsonInputFiles []string, fancyInputReaders []io.Reader, jsonInputReaders []io.Reader, casePolicy compiler.CasePolicy, nameSeparator string) (routes []compiler.CompiledRoute, errors []compiler.RouteError) {
+func parseInputFiles(fancyInputFiles []string, jsonInputFiles []string, openAPIInputFiles []string, fancyInputReaders []io.Reader, jsonInputReaders []io.Reader, openAPIInputReaders []io.Reader, casePolicy compiler.CasePolicy, nameSeparator string, withReader func(string, func(io.Reader)) error) (routes []compiler.CompiledRoute, errors []compiler.RouteError) {
 	jsonStart := len(fancyInputFiles)
-	allInputFiles := append(append([]string{}, fancyInputFiles...), jsonInputFiles...)
-	allInputReaders := append(append([]io.Reader{}, fancyInputReaders...), jsonInputReaders...)
+	openAPIStart := jsonStart + len(jsonInputFiles)
+	allInputFiles := append(append(append([]string{}, fancyInputFiles...), jsonInputFiles...), openAPIInputFiles...)
+	allInputReaders := append(append(append([]io.Reader{}, fancyInputReaders...), jsonInputReaders...), openAPIInputReaders...)
 	var entries [][]compiler.RouteFileEntry
-	entries, errors = compiler.ParseRouteFiles(allInputFiles, allInputReaders, jsonStart, casePolicy)
+	entries, errors = compiler.ParseRouteFilesWithOptions(allInputFiles, allInputReaders, compiler.ParseOptions{
+		JSONStart:    jsonStart,
+		OpenAPIStart: openAPIStart,
+		CasePolicy:   casePolicy,
+		WithReader:   withReader,
+	})
 	if len(errors) > 0 {
 		return
 	}
@@ -138,17 +201,21 @@ func parseInputFiles(fancyInputFiles []string, jsonInputFiles []string, fancyInp
 	return
 }
 
-func runHelper(params runParams, fancyInputReaders []io.Reader, jsonInputReaders []io.Reader) int {
-	metadataOut := os.Stdout
-	if params.output == "" {
-		metadataOut = os.Stderr
-	}
-
-	metadataOutDescription := ""
-	if params.output != "" {
-		metadataOutDescription = " written to " + params.output
-	}
+// withInputReaders opens the input files given in params and passes readers
+// for them to f.
+func withInputReaders(params runParams, f func(fancyInputReaders, jsonInputReaders, openAPIInputReaders []io.Reader)) error {
+	return withReaders([]io.Reader{}, params.fancyInputFiles, params.withReader, func(fancyInputReaders []io.Reader) {
+		withReaders([]io.Reader{}, params.jsonInputFiles, params.withReader, func(jsonInputReaders []io.Reader) {
+			withReaders([]io.Reader{}, params.openAPIInputFiles, params.withReader, func(openAPIInputReaders []io.Reader) {
+				f(fancyInputReaders, jsonInputReaders, openAPIInputReaders)
+			})
+		})
+	})
+}
 
+// compileInputFiles parses the input files and checks the routes for errors,
+// printing any errors that are found. It returns false if there are errors.
+func compileInputFiles(params runParams, metadataOut *os.File, fancyInputReaders []io.Reader, jsonInputReaders []io.Reader, openAPIInputReaders []io.Reader) ([]compiler.CompiledRoute, *compiler.TagExpr, bool) {
 	casePolicy := compiler.DisallowUpperCase
 	if params.allowUpperCase {
 		casePolicy = compiler.AllowUpperCase
@@ -157,10 +224,10 @@ func runHelper(params runParams, fancyInputReaders []io.Reader, jsonInputReaders
 	filter, filterErr := compiler.ParseTagExpr(params.filter)
 	if filterErr != nil {
 		params.fprintf(os.Stderr, "Error parsing value of -filter option:\n%v\n", filterErr)
-		return 1
+		return nil, nil, false
 	}
 
-	routes, errors := parseInputFiles(params.fancyInputFiles, params.jsonInputFiles, fancyInputReaders, jsonInputReaders, casePolicy, params.nameSeparator)
+	routes, errors := parseInputFiles(params.fancyInputFiles, params.jsonInputFiles, params.openAPIInputFiles, fancyInputReaders, jsonInputReaders, openAPIInputReaders, casePolicy, params.nameSeparator, params.withReader)
 	errors = append(errors, compiler.CheckForGroupErrors(routes)...)
 
 	if len(errors) > 0 {
@@ -173,6 +240,25 @@ func runHelper(params runParams, fancyInputReaders []io.Reader, jsonInputReaders
 				_, _ = params.fprintf(os.Stderr, "%v\n", e)
 			}
 		}
+		return nil, nil, false
+	}
+
+	return routes, filter, true
+}
+
+func runHelper(params runParams, fancyInputReaders []io.Reader, jsonInputReaders []io.Reader, openAPIInputReaders []io.Reader) int {
+	metadataOut := os.Stdout
+	if params.output == "" {
+		metadataOut = os.Stderr
+	}
+
+	metadataOutDescription := ""
+	if params.output != "" {
+		metadataOutDescription = " written to " + params.output
+	}
+
+	routes, filter, ok := compileInputFiles(params, metadataOut, fancyInputReaders, jsonInputReaders, openAPIInputReaders)
+	if !ok {
 		return 1
 	}
 
@@ -184,6 +270,29 @@ func runHelper(params runParams, fancyInputReaders []io.Reader, jsonInputReaders
 	routeRegexps := compiler.GetRouteRegexps(routes, filter)
 	json, nRoutes := compiler.RouteRegexpsToJSON(&routeRegexps, filter)
 
+	// All of the outputs are generated before any of them are written, so that
+	// a failure doesn't leave some outputs updated and others stale.
+	var goSrc, tsSrc, openAPI []byte
+	if params.goOutput != "" {
+		var err error
+		goSrc, err = compiler.RouteRegexpsToGo(&routeRegexps, filter, params.goPackage)
+		if err != nil {
+			_, _ = params.fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+	}
+	if params.tsOutput != "" {
+		tsSrc = compiler.RouteRegexpsToTS(&routeRegexps, filter)
+	}
+	if params.openAPIOutput != "" {
+		var warnings []compiler.RouteError
+		openAPI, warnings = compiler.RouteRegexpsToOpenAPI(&routeRegexps, filter)
+		sortRouteErrors(warnings)
+		for _, w := range warnings {
+			_, _ = params.fprintf(os.Stderr, "WARNING: %v\n", w)
+		}
+	}
+
 	retCode := 0
 
 	err := params.withWriter(params.output, func(of io.Writer) {
@@ -211,6 +320,31 @@ func runHelper(params runParams, fancyInputReaders []io.Reader, jsonInputReaders
 		return 1
 	}
 
+	if retCode == 0 && params.goOutput != "" {
+		retCode = writeOutput(params, params.goOutput, goSrc)
+	}
+	if retCode == 0 && params.tsOutput != "" {
+		retCode = writeOutput(params, params.tsOutput, tsSrc)
+	}
+	if retCode == 0 && params.openAPIOutput != "" {
+		retCode = writeOutput(params, params.openAPIOutput, openAPI)
+	}
+
+	return retCode
+}
+
+func writeOutput(params runParams, filename string, contents []byte) int {
+	retCode := 0
+	err := params.withWriter(filename, func(of io.Writer) {
+		if _, err := of.Write(contents); err != nil {
+			_, _ = params.fprintf(os.Stderr, "%v\n", err)
+			retCode = 1
+		}
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
 	return retCode
 }
 
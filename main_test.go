@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"reflect"
+	"slices"
 	"strings"
 	"testing"
 
@@ -355,7 +356,7 @@ r /
 		t.Fatalf("Expected 0 exit code, got %v\n", exitCode)
 	}
 	out := outb.String()
-	if out != `{"constantPortionNGroups":5,"constantPortionRegexp":"^(?:\\/+(?:(?:(?:(foo)(\\/)\\/*(bar)(\\/)\\/*(aMP)\\/*))))(?:\\?[^#]*)?(?:#.*)?$","families":{"foo/bar/aMP":{"matchRegexp":"^(?:(\\/+foo\\/+bar\\/+aMP\\/*))(\\?[^#]*)?(#.*)?$","nLevels":1,"nonparamGroupNumbers":[1],"members":[{"name":"r/route","paramGroupNumbers":{},"tags":[],"methods":["GET"]}]}}}` {
+	if out != `{"constantPortionNGroups":5,"constantPortionRegexp":"^(?:\\/+(?:(?:(?:(foo)(\\/)\\/*(bar)(\\/)\\/*(aMP)\\/*))))(?:\\?[^#]*)?(?:#.*)?$","families":{"foo/bar/aMP":{"matchRegexp":"^(?:(\\/+foo\\/+bar\\/+aMP\\/*))(\\?[^#]*)?(#.*)?$","nLevels":1,"nonparamGroupNumbers":[1],"members":[{"name":"r/route","paramGroupNumbers":{},"paramKinds":{},"tags":[],"methods":["GET"],"template":["/","foo","/","bar","/","aMP"]}]}}}` {
 		t.Fatalf("Unexpected output written:\n%v\n", out)
 	}
 
@@ -434,7 +435,7 @@ func TestOverlapErrorReportingSimpleCase(t *testing.T) {
 	}
 
 	consoleOut := consoleOutb.String()
-	const expectedConsoleOut = "file1:1: (and file5:1): routes overlap\n"
+	const expectedConsoleOut = "file1:1: (and file5:1): routes overlap; both match /foo/bar\n"
 
 	if consoleOut != expectedConsoleOut {
 		t.Fatalf("Did not get expected output, got\n%v\n", consoleOut)
@@ -472,14 +473,218 @@ func TestOverlapErrorReportingMultiline(t *testing.T) {
 	}
 
 	consoleOut := consoleOutb.String()
-	const expectedConsoleOut = "file1:2: (and file5:1): routes overlap\n" +
-		"file3:2: (and file3:3): routes overlap\n"
+	const expectedConsoleOut = "file1:2: (and file5:1): routes overlap; both match /foo/bar\n" +
+		"file3:2: (and file3:3): routes overlap; both match /another/over\n"
 
 	if consoleOut != expectedConsoleOut {
 		t.Fatalf("Did not get expected output, got\n%v\n", consoleOut)
 	}
 }
 
+func TestIncludes(t *testing.T) {
+	const mainFile = `
+root /
+  include api/routes
+  managers /managers [managers]
+    include managers/routes
+`
+	const apiFile = "getstuff /api/stuff [api]\n"
+	const managersFile = `
+list /list
+  .
+  include ../api/routes
+`
+
+	var outb strings.Builder
+	exitCode := run(runParams{
+		fancyInputFiles: []string{"routes/main"},
+		output:          "",
+		withReader:      mockMultifileReader(map[string]string{"routes/main": mainFile, "routes/api/routes": apiFile, "routes/managers/routes": managersFile}),
+		withWriter:      mockWriter(&outb),
+		fprintf:         dummyFprintf,
+		nameSeparator:   "/",
+	})
+	if exitCode != 0 {
+		t.Fatalf("Expected 0 exit code, got %v\n", exitCode)
+	}
+	doc, err := jsonquery.Parse(strings.NewReader(outb.String()))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	var routes []string
+	for _, m := range jsonquery.Find(doc, "/families/*/members/*") {
+		template := jsonquery.FindOne(m, "template").InnerText()
+		tags := valuesOf[string](jsonquery.Find(m, "tags/*"))
+		routes = append(routes, fmt.Sprintf("%v %v %v", jsonquery.FindOne(m, "name").Value(), template, tags))
+	}
+	slices.Sort(routes)
+	expected := []string{
+		"root/getstuff /api/stuff [api]",
+		"root/managers/list /managers/list [managers]",
+		"root/managers/list/getstuff /managers/list/api/stuff [api managers]",
+	}
+	if !reflect.DeepEqual(routes, expected) {
+		t.Fatalf("Unexpected routes %+v\n", routes)
+	}
+}
+
+func TestIncludeErrorReporting(t *testing.T) {
+	const mainFile = "root /\n  include a\n"
+	const aFile = "a /a\n  include b\nbroken\n"
+	const bFile = "b /b\ninclude a\n"
+
+	var consoleOutb strings.Builder
+	exitCode := run(runParams{
+		fancyInputFiles: []string{"main"},
+		output:          "",
+		withReader:      mockMultifileReader(map[string]string{"main": mainFile, "a": aFile, "b": bFile}),
+		withWriter:      mockWriter(&strings.Builder{}),
+		fprintf:         getAccumFprintf(&consoleOutb),
+		nameSeparator:   "/",
+	})
+	if exitCode != 1 {
+		t.Fatalf("Expected 1 exit code, got %v\n", exitCode)
+	}
+
+	const expectedConsoleOut = "a:3:6: missing route name or missing route pattern (included from main)\n"
+	if consoleOut := consoleOutb.String(); consoleOut != expectedConsoleOut {
+		t.Fatalf("Did not get expected output, got\n%v\n", consoleOut)
+	}
+
+	consoleOutb.Reset()
+	exitCode = run(runParams{
+		fancyInputFiles: []string{"main"},
+		output:          "",
+		withReader:      mockMultifileReader(map[string]string{"main": mainFile, "a": "a /a\n  include b\n", "b": bFile}),
+		withWriter:      mockWriter(&strings.Builder{}),
+		fprintf:         getAccumFprintf(&consoleOutb),
+		nameSeparator:   "/",
+	})
+	if exitCode != 1 {
+		t.Fatalf("Expected 1 exit code, got %v\n", exitCode)
+	}
+
+	const expectedCycleConsoleOut = "b:2: file 'a' includes itself (included from a, included from main)\n"
+	if consoleOut := consoleOutb.String(); consoleOut != expectedCycleConsoleOut {
+		t.Fatalf("Did not get expected output, got\n%v\n", consoleOut)
+	}
+}
+
+func TestMounts(t *testing.T) {
+	const mainFile = `
+mount managers /managers [managers] from manager_routes
+managers /managers [managers]
+mount clients [GET,POST] /clients [clients] from client_routes
+`
+	const managerRoutes = "foo /foo\nbar /bar [api]\n"
+	const clientRoutes = "foo /foo\n"
+
+	var outb strings.Builder
+	exitCode := run(runParams{
+		fancyInputFiles: []string{"main"},
+		output:          "",
+		withReader:      mockMultifileReader(map[string]string{"main": mainFile, "manager_routes": managerRoutes, "client_routes": clientRoutes}),
+		withWriter:      mockWriter(&outb),
+		fprintf:         dummyFprintf,
+		nameSeparator:   "/",
+	})
+	if exitCode != 0 {
+		t.Fatalf("Expected 0 exit code, got %v\n", exitCode)
+	}
+	doc, err := jsonquery.Parse(strings.NewReader(outb.String()))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	var routes []string
+	for _, m := range jsonquery.Find(doc, "/families/*/members/*") {
+		template := jsonquery.FindOne(m, "template").InnerText()
+		tags := valuesOf[string](jsonquery.Find(m, "tags/*"))
+		routes = append(routes, fmt.Sprintf("%v %v %v", jsonquery.FindOne(m, "name").Value(), template, tags))
+	}
+	slices.Sort(routes)
+	expected := []string{
+		"clients/foo /clients/foo [clients]",
+		"managers /managers [managers]",
+		"managers/bar /managers/bar [api managers]",
+		"managers/foo /managers/foo [managers]",
+	}
+	if !reflect.DeepEqual(routes, expected) {
+		t.Fatalf("Unexpected routes %+v\n", routes)
+	}
+
+	var consoleOutb strings.Builder
+	exitCode = run(runParams{
+		fancyInputFiles: []string{"main"},
+		output:          "",
+		withReader:      mockMultifileReader(map[string]string{"main": mainFile + "foo /managers/foo\n", "manager_routes": managerRoutes, "client_routes": clientRoutes}),
+		withWriter:      mockWriter(&strings.Builder{}),
+		fprintf:         getAccumFprintf(&consoleOutb),
+		nameSeparator:   "/",
+	})
+	if exitCode != 1 {
+		t.Fatalf("Expected 1 exit code, got %v\n", exitCode)
+	}
+	const expectedConsoleOut = "manager_routes:1: (and main:5): routes overlap; both match /managers/foo\n"
+	if consoleOut := consoleOutb.String(); consoleOut != expectedConsoleOut {
+		t.Fatalf("Did not get expected output, got\n%v\n", consoleOut)
+	}
+}
+
+func TestGoOutput(t *testing.T) {
+	outputs := map[string]*strings.Builder{"routes.json": {}, "routes.go": {}}
+	exitCode := run(runParams{
+		fancyInputFiles: []string{"file"},
+		output:          "routes.json",
+		goOutput:        "routes.go",
+		goPackage:       "myroutes",
+		withReader:      mockMultifileReader(map[string]string{"file": exampleInput}),
+		withWriter:      mockMultifileWriter(outputs),
+		fprintf:         dummyFprintf,
+		nameSeparator:   "/",
+	})
+	if exitCode != 0 {
+		t.Fatalf("Expected 0 exit code, got %v\n", exitCode)
+	}
+
+	if _, err := jsonquery.Parse(strings.NewReader(outputs["routes.json"].String())); err != nil {
+		t.Fatalf("Expected JSON output, got %v\n", err)
+	}
+	goOut := outputs["routes.go"].String()
+	if !strings.HasPrefix(goOut, "// Code generated by claney. DO NOT EDIT.\n\npackage myroutes\n") {
+		t.Fatalf("Unexpected Go output:\n%v\n", goOut)
+	}
+	if !strings.Contains(goOut, `RouteRootApiGetstuff     RouteName = "root/api/getstuff"`) {
+		t.Errorf("Expected route name constant in Go output:\n%v\n", goOut)
+	}
+}
+
+func TestGoOutputBadPackageName(t *testing.T) {
+	outputs := map[string]*strings.Builder{"routes.json": {}, "routes.go": {}}
+	var consoleOutb strings.Builder
+	exitCode := run(runParams{
+		fancyInputFiles: []string{"file"},
+		output:          "routes.json",
+		goOutput:        "routes.go",
+		goPackage:       "my-routes",
+		withReader:      mockMultifileReader(map[string]string{"file": exampleInput}),
+		withWriter:      mockMultifileWriter(outputs),
+		fprintf:         getAccumFprintf(&consoleOutb),
+		nameSeparator:   "/",
+	})
+	if exitCode != 1 {
+		t.Fatalf("Expected 1 exit code, got %v\n", exitCode)
+	}
+	if consoleOut := consoleOutb.String(); !strings.HasSuffix(consoleOut, "\"my-routes\" is not a valid Go package name\n") {
+		t.Fatalf("Did not get expected output, got\n%v\n", consoleOut)
+	}
+	if outputs["routes.go"].Len() != 0 {
+		t.Errorf("Expected no Go output, got\n%v\n", outputs["routes.go"])
+	}
+	if outputs["routes.json"].Len() != 0 {
+		t.Errorf("Expected no JSON output when Go output fails, got\n%v\n", outputs["routes.json"])
+	}
+}
+
 func valuesOf[T any](nodes []*jsonquery.Node) []T {
 	values := make([]T, len(nodes))
 	for i := range nodes {
@@ -513,6 +718,17 @@ func mockWriter(out *strings.Builder) func(string, func(io.Writer)) error {
 	}
 }
 
+func mockMultifileWriter(outs map[string]*strings.Builder) func(string, func(io.Writer)) error {
+	return func(filename string, f func(io.Writer)) error {
+		out, ok := outs[filename]
+		if !ok {
+			return fmt.Errorf("Unexpected output file %v in mockMultifileWriter", filename)
+		}
+		f(out)
+		return nil
+	}
+}
+
 func dummyFprintf(io.Writer, string, ...any) (int, error) {
 	return 0, nil
 }
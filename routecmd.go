@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/addrummond/claney/router"
+)
+
+// A routeRequest is a URL to look up with 'claney route', together with an
+// optional method.
+type routeRequest struct {
+	method string
+	url    string
+}
+
+func routeMain(args []string) int {
+	fs := flag.NewFlagSet("claney route", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: claney route [options] [[METHOD] URL]...\n\nIf no URLs are given, lines of the form '[METHOD] URL' are read from stdin.\n\n")
+		fs.PrintDefaults()
+	}
+	inputs := addInputFlags(fs)
+	host := fs.String("host", "", "route the URLs for the given host (by default hosts are ignored)")
+	_ = fs.Parse(args)
+
+	params := runParams{
+		routeRequests: fs.Args(),
+		routeHost:     *host,
+		withReader:    withReader,
+		withWriter:    withWriter,
+		fprintf:       fmt.Fprintf,
+	}
+	inputs.setInputParams(&params)
+
+	return runRoute(params)
+}
+
+func runRoute(params runParams) int {
+	var requests []routeRequest
+	if len(params.routeRequests) > 0 {
+		var err error
+		requests, err = parseRouteRequestArgs(params.routeRequests)
+		if err != nil {
+			_, _ = params.fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+	} else if slices.Contains(params.fancyInputFiles, "") || slices.Contains(params.jsonInputFiles, "") {
+		_, _ = params.fprintf(os.Stderr, "Route files must be specified using -input, -json-input or -openapi-input if URLs are to be read from stdin\n")
+		return 1
+	}
+
+	var r router.Router
+	ok := false
+	err := withInputReaders(params, func(fancyInputReaders, jsonInputReaders, openAPIInputReaders []io.Reader) {
+		r, ok = compileRouter(params, fancyInputReaders, jsonInputReaders, openAPIInputReaders)
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	if !ok {
+		return 1
+	}
+
+	exitCode := 0
+	lookup := func(req routeRequest) {
+		var e router.Explanation
+		if params.routeHost != "" {
+			e = router.ExplainHost(&r, req.method, params.routeHost, req.url)
+		} else {
+			e = router.Explain(&r, req.method, req.url)
+		}
+		if !printRouteExplanation(params, req, e) {
+			exitCode = 1
+		}
+	}
+
+	if len(params.routeRequests) > 0 {
+		for _, req := range requests {
+			lookup(req)
+		}
+		return exitCode
+	}
+
+	err = params.withReader("", func(rd io.Reader) {
+		scanner := bufio.NewScanner(rd)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) == 0 {
+				continue
+			}
+			reqs, err := parseRouteRequestArgs(fields)
+			if err != nil || len(reqs) != 1 {
+				_, _ = params.fprintf(os.Stderr, "Expected a line of the form '[METHOD] URL', got %v\n", scanner.Text())
+				exitCode = 1
+				continue
+			}
+			lookup(reqs[0])
+		}
+		if err := scanner.Err(); err != nil {
+			_, _ = params.fprintf(os.Stderr, "%v\n", err)
+			exitCode = 1
+		}
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	return exitCode
+}
+
+// parseRouteRequestArgs parses a sequence of URLs, each optionally preceded by
+// a method. URLs are distinguished from methods by their initial '/'.
+func parseRouteRequestArgs(args []string) ([]routeRequest, error) {
+	var requests []routeRequest
+	for i := 0; i < len(args); i++ {
+		if strings.HasPrefix(args[i], "/") {
+			requests = append(requests, routeRequest{url: args[i]})
+			continue
+		}
+		if i+1 == len(args) || !strings.HasPrefix(args[i+1], "/") {
+			return nil, fmt.Errorf("Expected a URL beginning with '/' after method %v", args[i])
+		}
+		requests = append(requests, routeRequest{method: args[i], url: args[i+1]})
+		i++
+	}
+	return requests, nil
+}
+
+// printRouteExplanation prints the result of looking up a URL. It returns false
+// if no route was found.
+func printRouteExplanation(params runParams, req routeRequest, e router.Explanation) bool {
+	url := params.routeHost + req.url
+	if req.method == "" {
+		_, _ = params.fprintf(os.Stdout, "%v\n", url)
+	} else {
+		_, _ = params.fprintf(os.Stdout, "%v %v\n", req.method, url)
+	}
+
+	switch e.Status {
+	case router.NotFound:
+		switch e.Failure {
+		case router.ConstantPortionNotMatched:
+			_, _ = params.fprintf(os.Stdout, "  not found: the constant portion regexp does not match the URL\n")
+		case router.NoFamilyForConstantPortion:
+			_, _ = params.fprintf(os.Stdout, "  not found: there is no family for the constant portion %q\n", e.ConstantPortion)
+		default:
+			_, _ = params.fprintf(os.Stdout, "  not found: the regexp for the family with constant portion %q does not match the URL\n", e.ConstantPortion)
+		}
+		return false
+	case router.MethodNotAllowed:
+		_, _ = params.fprintf(os.Stdout, "  method not allowed: allowed methods are %v\n", e.Result.Allow())
+		return false
+	}
+
+	res := &e.Result
+	_, _ = params.fprintf(os.Stdout, "  name:    %v\n", res.Name)
+	if len(res.Params) > 0 {
+		keys := make([]string, 0, len(res.Params))
+		for k := range res.Params {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		ps := make([]string, len(keys))
+		for i, k := range keys {
+			ps[i] = fmt.Sprintf("%v=%v (%v)", k, res.Params[k], res.ParamKinds[k])
+		}
+		_, _ = params.fprintf(os.Stdout, "  params:  %v\n", strings.Join(ps, ", "))
+	}
+	if len(res.Tags) > 0 {
+		_, _ = params.fprintf(os.Stdout, "  tags:    %v\n", strings.Join(res.Tags, ", "))
+	}
+	if len(res.Hosts) > 0 {
+		_, _ = params.fprintf(os.Stdout, "  hosts:   %v\n", strings.Join(res.Hosts, ", "))
+	}
+	_, _ = params.fprintf(os.Stdout, "  methods: %v\n", res.Allow())
+	if res.Query != "" {
+		_, _ = params.fprintf(os.Stdout, "  query:   %v\n", res.Query)
+	}
+	if res.Anchor != "" {
+		_, _ = params.fprintf(os.Stdout, "  anchor:  %v\n", res.Anchor)
+	}
+	return true
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRunRoute(t *testing.T) {
+	var consoleOutb strings.Builder
+	exitCode := runRoute(runParams{
+		fancyInputFiles: []string{"routes"},
+		routeRequests:   []string{"GET", "/managers/12/user/7?x=y#foo", "/managers/1/new"},
+		withReader:      mockMultifileReader(map[string]string{"routes": testCmdRoutes}),
+		fprintf:         getAccumFprintf(&consoleOutb),
+		nameSeparator:   "/",
+	})
+	if exitCode != 0 {
+		t.Fatalf("Expected 0 exit code, got %v\n%v\n", exitCode, consoleOutb.String())
+	}
+
+	const expectedConsoleOut = "GET /managers/12/user/7?x=y#foo\n" +
+		"  name:    managers/user\n" +
+		"  params:  manager_id=12 (string), user_id=7 (integer)\n" +
+		"  methods: GET\n" +
+		"  query:   ?x=y\n" +
+		"  anchor:  #foo\n" +
+		"/managers/1/new\n" +
+		"  name:    managers/create\n" +
+		"  params:  manager_id=1 (string)\n" +
+		"  methods: POST\n"
+	if consoleOut := consoleOutb.String(); consoleOut != expectedConsoleOut {
+		t.Fatalf("Did not get expected output, got\n%v\n", consoleOut)
+	}
+}
+
+func TestRunRouteHost(t *testing.T) {
+	const routes = `
+api @api.example.com /api
+  users /users/:id
+home /home
+`
+	for _, c := range []struct {
+		host     string
+		exitCode int
+		output   string
+	}{
+		{"api.example.com", 0, "GET api.example.com/api/users/1\n" +
+			"  name:    api/users\n" +
+			"  params:  id=1 (string)\n" +
+			"  hosts:   api.example.com\n" +
+			"  methods: GET\n" +
+			"GET api.example.com/home\n" +
+			"  name:    home\n" +
+			"  methods: GET\n"},
+		{"www.example.com", 1, "GET www.example.com/api/users/1\n" +
+			"  not found: the constant portion regexp does not match the URL\n" +
+			"GET www.example.com/home\n" +
+			"  name:    home\n" +
+			"  methods: GET\n"},
+	} {
+		var consoleOutb strings.Builder
+		exitCode := runRoute(runParams{
+			fancyInputFiles: []string{"routes"},
+			routeRequests:   []string{"GET", "/api/users/1", "GET", "/home"},
+			routeHost:       c.host,
+			withReader:      mockMultifileReader(map[string]string{"routes": routes}),
+			fprintf:         getAccumFprintf(&consoleOutb),
+			nameSeparator:   "/",
+		})
+		if exitCode != c.exitCode {
+			t.Errorf("Expected %v exit code for host %v, got %v\n", c.exitCode, c.host, exitCode)
+		}
+		if consoleOut := consoleOutb.String(); consoleOut != c.output {
+			t.Errorf("Did not get expected output for host %v, got\n%v\n", c.host, consoleOut)
+		}
+	}
+}
+
+func TestRunRouteFromStdin(t *testing.T) {
+	var consoleOutb strings.Builder
+	exitCode := runRoute(runParams{
+		fancyInputFiles: []string{"routes"},
+		withReader:      mockMultifileReader(map[string]string{"routes": testCmdRoutes, "": "GET /nope\n\nGET /managers/1/new\nGET\n"}),
+		fprintf:         getAccumFprintf(&consoleOutb),
+		nameSeparator:   "/",
+	})
+	if exitCode != 1 {
+		t.Fatalf("Expected 1 exit code, got %v\n", exitCode)
+	}
+
+	const expectedConsoleOut = "GET /nope\n" +
+		"  not found: the constant portion regexp does not match the URL\n" +
+		"GET /managers/1/new\n" +
+		"  method not allowed: allowed methods are POST\n" +
+		"Expected a line of the form '[METHOD] URL', got GET\n"
+	if consoleOut := consoleOutb.String(); consoleOut != expectedConsoleOut {
+		t.Fatalf("Did not get expected output, got\n%v\n", consoleOut)
+	}
+}
+
+func TestRunRouteStdinConflict(t *testing.T) {
+	var consoleOutb strings.Builder
+	exitCode := runRoute(runParams{
+		fancyInputFiles: []string{""},
+		withReader:      mockMultifileReader(map[string]string{"": testCmdRoutes}),
+		fprintf:         getAccumFprintf(&consoleOutb),
+		nameSeparator:   "/",
+	})
+	if exitCode != 1 {
+		t.Fatalf("Expected 1 exit code, got %v\n", exitCode)
+	}
+	if consoleOut := consoleOutb.String(); !strings.HasPrefix(consoleOut, "Route files must be specified") {
+		t.Fatalf("Did not get expected output, got\n%v\n", consoleOut)
+	}
+}
+
+func TestParseRouteRequestArgs(t *testing.T) {
+	reqs, err := parseRouteRequestArgs([]string{"/a", "POST", "/b", "/c"})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	expected := []routeRequest{{url: "/a"}, {method: "POST", url: "/b"}, {url: "/c"}}
+	if !slices.Equal(reqs, expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, reqs)
+	}
+
+	if _, err := parseRouteRequestArgs([]string{"/a", "POST"}); err == nil {
+		t.Fatalf("Expected error for method without URL")
+	}
+}
@@ -0,0 +1,319 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+var (
+	// ErrNoSuchRoute is returned by BuildURL if there is no route with the given
+	// name that has exactly the given set of parameters.
+	ErrNoSuchRoute = errors.New("no route with the given name and parameters")
+	// ErrMissingParam is returned by BuildURL if some but not all of the
+	// parameters of an optional group are given.
+	ErrMissingParam = errors.New("missing value for parameter in optional group")
+	// ErrRouteContainsGlob is returned by BuildURL if the route contains a '*' or
+	// '**' glob, as there is no value that could be substituted for the glob.
+	ErrRouteContainsGlob = errors.New("route contains a glob")
+	// ErrEmptyParam is returned by BuildURL if a parameter value is empty (or
+	// consists only of '/' characters in the case of a rest parameter).
+	ErrEmptyParam = errors.New("empty parameter value")
+	// ErrSlashInParam is returned by BuildURL if the value of a parameter other
+	// than a rest parameter contains a '/'.
+	ErrSlashInParam = errors.New("parameter value contains '/'")
+	// ErrBadIntegerParam is returned by BuildURL if the value of an integer
+	// parameter is not an integer.
+	ErrBadIntegerParam = errors.New("integer parameter value is not an integer")
+	// ErrParamNotInSet is returned by BuildURL if the value of an enumerated
+	// parameter is not one of the parameter's values.
+	ErrParamNotInSet = errors.New("parameter value is not one of the permitted values")
+	// ErrBadTypedParam is returned by BuildURL if the value of a parameter with
+	// a built-in type such as uuid is not a valid value of the type.
+	ErrBadTypedParam = errors.New("parameter value is not a valid value of the parameter's type")
+	// ErrParamDoesNotMatchRegexp is returned by BuildURL if the percent-encoded
+	// value of a parameter with a regexp does not match the regexp.
+	ErrParamDoesNotMatchRegexp = errors.New("parameter value does not match the parameter's regexp")
+)
+
+type templateElemKind int
+
+const (
+	templateSlash templateElemKind = iota
+	templateConstant
+	templateParam
+	templateIntegerParam
+	templateRestParam
+	templateGlob
+	templateOptionalStart
+	templateOptionalEnd
+)
+
+type templateElem struct {
+	kind      templateElemKind
+	value     string
+	values    []string       // for enumerated parameters
+	regexp    *regexp.Regexp // for parameters with a regexp
+	paramKind ParamKind      // for parameters with a built-in type
+}
+
+func (te *templateElem) UnmarshalJSON(input []byte) error {
+	var s string
+	if err := json.Unmarshal(input, &s); err == nil {
+		if s == "/" {
+			te.kind = templateSlash
+		} else {
+			te.kind = templateConstant
+			te.value = s
+		}
+		return nil
+	}
+
+	var a []string
+	if err := json.Unmarshal(input, &a); err != nil {
+		return err
+	}
+	if len(a) == 0 {
+		return fmt.Errorf("empty template element")
+	}
+	switch a[0] {
+	case ":":
+		te.kind = templateParam
+	case ":~":
+		if len(a) != 3 {
+			return fmt.Errorf("expected parameter name and regexp in template element")
+		}
+		re, err := regexp.Compile("^(?:" + a[2] + ")$")
+		if err != nil {
+			return err
+		}
+		te.kind = templateParam
+		te.value = a[1]
+		te.regexp = re
+		return nil
+	case ":#":
+		te.kind = templateIntegerParam
+	case ":**":
+		te.kind = templateRestParam
+	case "*", "**":
+		te.kind = templateGlob
+		return nil
+	case "|":
+		// URLs are built using the first alternative of an alternation.
+		if len(a) < 2 {
+			return fmt.Errorf("expected alternatives in template element")
+		}
+		te.kind = templateConstant
+		te.value = a[1]
+		return nil
+	case "[":
+		te.kind = templateOptionalStart
+		return nil
+	case "]":
+		te.kind = templateOptionalEnd
+		return nil
+	default:
+		name, isParam := strings.CutPrefix(a[0], ":")
+		kind, ok := paramKindFromName(name)
+		if !isParam || !ok || kind == StringParam || kind == IntegerParam || kind == RestParam {
+			return fmt.Errorf("unrecognized template element %q", a[0])
+		}
+		te.kind = templateParam
+		te.paramKind = kind
+	}
+	if len(a) < 2 || (len(a) > 2 && (te.kind != templateParam || te.paramKind != StringParam)) {
+		return fmt.Errorf("expected parameter name in template element")
+	}
+	te.value = a[1]
+	if len(a) > 2 {
+		te.values = a[2:]
+	}
+	return nil
+}
+
+type template struct {
+	elems    []templateElem
+	params   []string // sorted
+	required []string // the params that aren't in optional groups
+}
+
+func (te *templateElem) isParam() bool {
+	return te.kind == templateParam || te.kind == templateIntegerParam || te.kind == templateRestParam
+}
+
+func indexTemplates(families map[string]family) map[string][]template {
+	// Iterate through the families in a fixed order so that the choice between
+	// namesakes with the same parameters is deterministic.
+	cps := make([]string, 0, len(families))
+	for cp := range families {
+		cps = append(cps, cp)
+	}
+	sort.Strings(cps)
+
+	templates := make(map[string][]template)
+	for _, cp := range cps {
+		for _, m := range families[cp].Members {
+			var params, required []string
+			optionalDepth := 0
+			for _, e := range m.Template {
+				switch {
+				case e.isParam():
+					params = append(params, e.value)
+					if optionalDepth == 0 {
+						required = append(required, e.value)
+					}
+				case e.kind == templateOptionalStart:
+					optionalDepth++
+				case e.kind == templateOptionalEnd:
+					optionalDepth--
+				}
+			}
+			sort.Strings(params)
+			params = slices.Compact(params)
+			templates[m.Name] = append(templates[m.Name], template{m.Template, params, required})
+		}
+	}
+	return templates
+}
+
+// BuildURL constructs the path of the route with the given name from the given
+// parameter values. If there are several routes with the same name, the first
+// route that has all of the keys of params as parameters, and whose other
+// parameters are all in optional groups, is used. An optional group is
+// included in the path if a value is given for any of its parameters (so a
+// group without parameters is never included). String parameter
+// values are percent-encoded. The segments of rest parameter values are
+// percent-encoded individually, so that rest parameter values may contain '/'.
+// Errors wrap one of the Err* values defined in this package.
+func BuildURL(r *Router, name string, params map[string]string) (string, error) {
+	var t *template
+	for i := range r.router.Templates[name] {
+		if fitsParams(&r.router.Templates[name][i], params) {
+			t = &r.router.Templates[name][i]
+			break
+		}
+	}
+	if t == nil {
+		return "", fmt.Errorf("%w: %v", ErrNoSuchRoute, name)
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(t.elems); i++ {
+		e := t.elems[i]
+		if e.isParam() {
+			if _, ok := params[e.value]; !ok {
+				return "", fmt.Errorf("%w: %v", ErrMissingParam, e.value)
+			}
+		}
+		switch e.kind {
+		case templateOptionalStart:
+			if end, given := optionalGroupEnd(t.elems, i, params); !given {
+				i = end
+			}
+		case templateSlash:
+			sb.WriteByte('/')
+		case templateConstant:
+			sb.WriteString(e.value)
+		case templateGlob:
+			return "", fmt.Errorf("%w: %v", ErrRouteContainsGlob, name)
+		case templateParam:
+			v := params[e.value]
+			if e.values != nil {
+				if !slices.Contains(e.values, v) {
+					return "", fmt.Errorf("%w: %v", ErrParamNotInSet, e.value)
+				}
+				sb.WriteString(v)
+				continue
+			}
+			if v == "" {
+				return "", fmt.Errorf("%w: %v", ErrEmptyParam, e.value)
+			}
+			if strings.IndexByte(v, '/') != -1 {
+				return "", fmt.Errorf("%w: %v", ErrSlashInParam, e.value)
+			}
+			if !validTypedParam(e.paramKind, v) {
+				return "", fmt.Errorf("%w: %v", ErrBadTypedParam, e.value)
+			}
+			escaped := url.PathEscape(v)
+			if e.regexp != nil && !e.regexp.MatchString(escaped) {
+				return "", fmt.Errorf("%w: %v", ErrParamDoesNotMatchRegexp, e.value)
+			}
+			sb.WriteString(escaped)
+		case templateIntegerParam:
+			v := params[e.value]
+			if !isInteger(v) {
+				return "", fmt.Errorf("%w: %v", ErrBadIntegerParam, e.value)
+			}
+			sb.WriteString(v)
+		case templateRestParam:
+			v := params[e.value]
+			if strings.Trim(v, "/") == "" {
+				return "", fmt.Errorf("%w: %v", ErrEmptyParam, e.value)
+			}
+			segments := strings.Split(v, "/")
+			for i := range segments {
+				segments[i] = url.PathEscape(segments[i])
+			}
+			sb.WriteString(strings.Join(segments, "/"))
+		}
+	}
+
+	return sb.String(), nil
+}
+
+func fitsParams(t *template, params map[string]string) bool {
+	for n := range params {
+		if _, ok := slices.BinarySearch(t.params, n); !ok {
+			return false
+		}
+	}
+	for _, n := range t.required {
+		if _, ok := params[n]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// optionalGroupEnd returns the index of the end of the optional group starting
+// at index i, and whether a value is given for any of the group's parameters.
+func optionalGroupEnd(elems []templateElem, i int, params map[string]string) (int, bool) {
+	depth := 0
+	given := false
+	for ; i < len(elems); i++ {
+		switch {
+		case elems[i].kind == templateOptionalStart:
+			depth++
+		case elems[i].kind == templateOptionalEnd:
+			depth--
+			if depth == 0 {
+				return i, given
+			}
+		case elems[i].isParam():
+			if _, ok := params[elems[i].value]; ok {
+				given = true
+			}
+		}
+	}
+	return i, given
+}
+
+func isInteger(s string) bool {
+	if len(s) > 0 && s[0] == '-' {
+		s = s[1:]
+	}
+	if len(s) == 0 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
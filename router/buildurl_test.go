@@ -0,0 +1,132 @@
+package router
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestBuildURL(t *testing.T) {
+	const routeFile = `
+root /
+managers /managers/
+  .
+  user     /:manager_id/user/:#user_id
+  files    /:manager_id/files/:**path
+  glob     /glob/*
+users /users!/
+  .
+  profile  /:user_id/profile
+  settings /:user_id/settings/
+  strict   /:user_id/strict!/
+posts /posts
+posts /posts/:#n
+docs /docs/:{lang in en,fr}/:page
+hex /hex/:{id ~ [0-9a-f]+}
+slashy /
+  r /
+    rr /
+`
+
+	testRouter(t, routeFile, false, func(router *Router) {
+		assertBuildURL(t, router, "root", map[string]string{}, "/", nil)
+		assertBuildURL(t, router, "managers", map[string]string{}, "/managers/", nil)
+		assertBuildURL(t, router, "managers/user", map[string]string{"manager_id": "a b", "user_id": "-7"}, "/managers/a%20b/user/-7", nil)
+		assertBuildURL(t, router, "managers/files", map[string]string{"manager_id": "m", "path": "x/y z/w"}, "/managers/m/files/x/y%20z/w", nil)
+		assertBuildURL(t, router, "users", map[string]string{}, "/users", nil)
+		assertBuildURL(t, router, "users/profile", map[string]string{"user_id": "12"}, "/users/12/profile", nil)
+		assertBuildURL(t, router, "users/settings", map[string]string{"user_id": "12"}, "/users/12/settings/", nil)
+		assertBuildURL(t, router, "users/strict", map[string]string{"user_id": "12"}, "/users/12/strict", nil)
+		assertBuildURL(t, router, "posts", map[string]string{}, "/posts", nil)
+		assertBuildURL(t, router, "posts", map[string]string{"n": "3"}, "/posts/3", nil)
+		assertBuildURL(t, router, "slashy/r/rr", map[string]string{}, "/", nil)
+		assertBuildURL(t, router, "docs", map[string]string{"lang": "fr", "page": "intro"}, "/docs/fr/intro", nil)
+		assertBuildURL(t, router, "hex", map[string]string{"id": "c0ffee"}, "/hex/c0ffee", nil)
+
+		assertBuildURL(t, router, "nope", map[string]string{}, "", ErrNoSuchRoute)
+		assertBuildURL(t, router, "posts", map[string]string{"m": "3"}, "", ErrNoSuchRoute)
+		assertBuildURL(t, router, "managers/glob", map[string]string{}, "", ErrRouteContainsGlob)
+		assertBuildURL(t, router, "managers/user", map[string]string{"manager_id": "a", "user_id": "x"}, "", ErrBadIntegerParam)
+		assertBuildURL(t, router, "managers/user", map[string]string{"manager_id": "a/b", "user_id": "1"}, "", ErrSlashInParam)
+		assertBuildURL(t, router, "managers/user", map[string]string{"manager_id": "", "user_id": "1"}, "", ErrEmptyParam)
+		assertBuildURL(t, router, "managers/files", map[string]string{"manager_id": "m", "path": "//"}, "", ErrEmptyParam)
+		assertBuildURL(t, router, "docs", map[string]string{"lang": "de", "page": "intro"}, "", ErrParamNotInSet)
+		assertBuildURL(t, router, "hex", map[string]string{"id": "coffee"}, "", ErrParamDoesNotMatchRegexp)
+	})
+}
+
+func TestBuildURLOptionalGroups(t *testing.T) {
+	const routeFile = `
+posts  /posts[/page/:#n]
+search /search[/:q[/:#page]]/results
+fixed  /fixed[/extra]
+`
+
+	testRouter(t, routeFile, false, func(router *Router) {
+		assertBuildURL(t, router, "posts", map[string]string{}, "/posts", nil)
+		assertBuildURL(t, router, "posts", map[string]string{"n": "2"}, "/posts/page/2", nil)
+		assertBuildURL(t, router, "search", map[string]string{}, "/search/results", nil)
+		assertBuildURL(t, router, "search", map[string]string{"q": "foo"}, "/search/foo/results", nil)
+		assertBuildURL(t, router, "search", map[string]string{"q": "foo", "page": "3"}, "/search/foo/3/results", nil)
+		assertBuildURL(t, router, "fixed", map[string]string{}, "/fixed", nil)
+
+		assertBuildURL(t, router, "search", map[string]string{"page": "3"}, "", ErrMissingParam)
+		assertBuildURL(t, router, "posts", map[string]string{"m": "2"}, "", ErrNoSuchRoute)
+	})
+}
+
+func TestBuildURLAlternations(t *testing.T) {
+	const routeFile = `
+photo  /(photos|images)/:id
+legacy /gallery/(old|older)/(a|b)
+`
+
+	testRouter(t, routeFile, false, func(router *Router) {
+		assertBuildURL(t, router, "photo", map[string]string{"id": "1"}, "/photos/1", nil)
+		assertBuildURL(t, router, "legacy", map[string]string{}, "/gallery/old/a", nil)
+	})
+}
+
+func TestBuildURLRoundTrip(t *testing.T) {
+	const routeFile = `
+a /a/:x/b/:#y
+b /b/:x!/
+c /c/:**rest/d
+`
+
+	testRouter(t, routeFile, false, func(router *Router) {
+		cases := []struct {
+			name   string
+			params map[string]string
+		}{
+			{"a", map[string]string{"x": "foo", "y": "12"}},
+			{"b", map[string]string{"x": "foo.bar"}},
+			{"c", map[string]string{"rest": "one/two/three"}},
+		}
+
+		for _, c := range cases {
+			url, err := BuildURL(router, c.name, c.params)
+			if err != nil {
+				t.Errorf("Unexpected error building URL for %v: %v\n", c.name, err)
+				continue
+			}
+			assertRoute(t, router, url, c.name, c.params, "", "", []string{"GET"}, []string{})
+		}
+	})
+}
+
+func assertBuildURL(t *testing.T, router *Router, name string, params map[string]string, expectedURL string, expectedErr error) {
+	url, err := BuildURL(router, name, params)
+	if expectedErr != nil {
+		if !errors.Is(err, expectedErr) {
+			t.Errorf("Expected error %v building URL for %v, got %v\n", expectedErr, name, err)
+		}
+		return
+	}
+	if err != nil {
+		t.Errorf("Unexpected error building URL for %v: %v\n", name, err)
+		return
+	}
+	if url != expectedURL {
+		t.Errorf("Expected URL for %v to be %v, got %v\n", name, expectedURL, url)
+	}
+}
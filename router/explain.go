@@ -0,0 +1,99 @@
+package router
+
+import "fmt"
+
+// Failure identifies the stage of routing at which a URL failed to match.
+type Failure int
+
+const (
+	// NoFailure indicates that a route matches the URL.
+	NoFailure Failure = iota
+	// ConstantPortionNotMatched indicates that the constant portion regexp did
+	// not match the URL.
+	ConstantPortionNotMatched
+	// NoFamilyForConstantPortion indicates that there is no family for the
+	// constant portion extracted from the URL.
+	NoFamilyForConstantPortion
+	// FamilyRegexpNotMatched indicates that the match regexp of the family for
+	// the constant portion of the URL did not match the URL, or (if the router
+	// was constructed with the DecodeParams option) that a parameter value
+	// could not be decoded.
+	FamilyRegexpNotMatched
+)
+
+func (f Failure) String() string {
+	switch f {
+	case NoFailure:
+		return "NoFailure"
+	case ConstantPortionNotMatched:
+		return "ConstantPortionNotMatched"
+	case NoFamilyForConstantPortion:
+		return "NoFamilyForConstantPortion"
+	case FamilyRegexpNotMatched:
+		return "FamilyRegexpNotMatched"
+	}
+	return fmt.Sprintf("Failure(%v)", int(f))
+}
+
+// Explanation describes the result of routing a URL with Explain.
+type Explanation struct {
+	Result RouteResult
+	Status Status
+	// ConstantPortion is the constant portion extracted from the URL. It is
+	// empty if Failure is ConstantPortionNotMatched.
+	ConstantPortion string
+	// Failure is NoFailure unless Status is NotFound.
+	Failure Failure
+}
+
+// Explain routes a URL in the same way as RouteMethod, or in the same way as
+// Route if method is empty, and reports the stage of routing at which the URL
+// failed to match if no route matches it. It is intended for debugging and is
+// slower than RouteMethod. Like RouteMethod, it ignores routes restricted to
+// particular hosts.
+func Explain(r *Router, method string, url string) Explanation {
+	return explainInTable(r, hostTable(r, ""), method, url)
+}
+
+// ExplainHost is like Explain, but routes the URL in the same way as RouteHost,
+// ignoring routes restricted to hosts other than the given host. If method is
+// empty, the URL is routed without regard to the method.
+func ExplainHost(r *Router, method string, host string, url string) Explanation {
+	return explainInTable(r, hostTable(r, host), method, url)
+}
+
+func explainInTable(r *Router, t *table, method string, url string) Explanation {
+	var result RouteResult
+	var status Status
+	if method == "" {
+		var ok bool
+		result, ok = routeInTable(r, t, url)
+		if ok {
+			status = Found
+		}
+	} else {
+		result, status = routeMethodInTable(r, t, method, url)
+	}
+
+	if !r.router.CaseSensitive {
+		url = normalizeUrl(url)
+	}
+	cp, ok := constantPortion(t, url)
+
+	e := Explanation{Result: result, Status: status, ConstantPortion: cp}
+	switch {
+	case status != NotFound:
+	case !ok:
+		e.Failure = ConstantPortionNotMatched
+	case !hasFamily(t, cp):
+		e.Failure = NoFamilyForConstantPortion
+	default:
+		e.Failure = FamilyRegexpNotMatched
+	}
+	return e
+}
+
+func hasFamily(t *table, cp string) bool {
+	_, ok := t.Families[cp]
+	return ok
+}
@@ -0,0 +1,71 @@
+package router
+
+import "testing"
+
+func TestExplain(t *testing.T) {
+	const routeFile = `
+foo [GET] /foo/:id
+bar [POST] /bar/baz
+`
+	testRouterWithOptions(t, routeFile, Options{DecodeParams: true}, func(router *Router) {
+		assertExplanation(t, router, "", "/foo/12", Found, "foo/", NoFailure)
+		assertExplanation(t, router, "GET", "/foo/12", Found, "foo/", NoFailure)
+		assertExplanation(t, router, "GET", "/bar/baz", MethodNotAllowed, "bar/baz", NoFailure)
+		assertExplanation(t, router, "", "/bar/baz", Found, "bar/baz", NoFailure)
+		assertExplanation(t, router, "GET", "/nothing", NotFound, "", ConstantPortionNotMatched)
+		assertExplanation(t, router, "", "/foo", NotFound, "", ConstantPortionNotMatched)
+		// The parameter value can't be decoded.
+		assertExplanation(t, router, "GET", "/foo/a%2Fb", NotFound, "foo/", FamilyRegexpNotMatched)
+
+		delete(router.router.Families, "bar/baz")
+		assertExplanation(t, router, "POST", "/bar/baz", NotFound, "bar/baz", NoFamilyForConstantPortion)
+	})
+}
+
+func TestExplainHost(t *testing.T) {
+	const routeFile = `
+api @api.example.com /api
+  users [GET] /users/:id
+www @www.example.com /
+  home /home
+`
+	testRouter(t, routeFile, false, func(router *Router) {
+		for _, c := range []struct {
+			method, host, url string
+			status            Status
+			name              string
+			failure           Failure
+		}{
+			{"GET", "api.example.com", "/api/users/1", Found, "api/users", NoFailure},
+			{"", "API.example.com:443", "/api/users/1", Found, "api/users", NoFailure},
+			{"POST", "api.example.com", "/api/users/1", MethodNotAllowed, "", NoFailure},
+			{"GET", "www.example.com", "/api/users/1", NotFound, "", ConstantPortionNotMatched},
+			{"GET", "www.example.com", "/home", Found, "www/home", NoFailure},
+			{"GET", "api.example.com", "/home", NotFound, "", ConstantPortionNotMatched},
+			{"GET", "other.com", "/home", NotFound, "", ConstantPortionNotMatched},
+		} {
+			e := ExplainHost(router, c.method, c.host, c.url)
+			if e.Status != c.status || e.Result.Name != c.name || e.Failure != c.failure {
+				t.Errorf("Unexpected explanation for %v %v %v: %+v\n", c.method, c.host, c.url, e)
+			}
+		}
+
+		// Explain ignores routes restricted to particular hosts.
+		assertExplanation(t, router, "GET", "/home", NotFound, "", ConstantPortionNotMatched)
+	})
+}
+
+func assertExplanation(t *testing.T, router *Router, method, url string, expectedStatus Status, expectedConstantPortion string, expectedFailure Failure) {
+	t.Helper()
+
+	e := Explain(router, method, url)
+	if e.Status != expectedStatus {
+		t.Errorf("Expected status %v for %v %v, got %v\n", expectedStatus, method, url, e.Status)
+	}
+	if e.ConstantPortion != expectedConstantPortion {
+		t.Errorf("Expected constant portion %q for %v %v, got %q\n", expectedConstantPortion, method, url, e.ConstantPortion)
+	}
+	if e.Failure != expectedFailure {
+		t.Errorf("Expected failure %v for %v %v, got %v\n", expectedFailure, method, url, e.Failure)
+	}
+}
@@ -0,0 +1,140 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/addrummond/claney/glob"
+)
+
+// Mux is an http.Handler that routes requests using a Router and dispatches
+// each request to the handler registered for the name of the matching route.
+// The RouteResult is added to the request's context and can be retrieved using
+// FromContext.
+//
+// Requests are routed with RouteHost using the request's Host header and the
+// escaped form of the request path (r.URL.EscapedPath()) together with the raw
+// query string. Parameter values are therefore not percent-decoded unless the
+// Router was constructed with the DecodeParams option.
+type Mux struct {
+	router *Router
+	exact  map[string]http.Handler
+	globs  []globHandler
+
+	// NotFound handles requests that match no route, or that match a route with
+	// no registered handler. If nil, http.NotFound is used.
+	NotFound http.Handler
+	// MethodNotAllowed handles requests that match a route but not its methods.
+	// The Allow header is set before it is called. If nil, a plain 405 response
+	// is sent.
+	MethodNotAllowed http.Handler
+}
+
+type globHandler struct {
+	pattern string
+	handler http.Handler
+}
+
+type contextKey struct{}
+
+// NewMux constructs a Mux that routes requests using the given Router.
+func NewMux(r *Router) *Mux {
+	return &Mux{
+		router: r,
+		exact:  make(map[string]http.Handler),
+	}
+}
+
+// Handle registers the handler for the given route name. The name may contain
+// '*' globs (e.g. "managers/*"). Handlers registered for an exact name take
+// precedence over handlers registered for a glob; globs are tried in the order
+// in which they were registered. Handle panics if a handler has already been
+// registered for the name.
+func (m *Mux) Handle(name string, handler http.Handler) {
+	if handler == nil {
+		panic("router: nil handler")
+	}
+
+	if glob.IsNonLiteral(name) {
+		for _, g := range m.globs {
+			if g.pattern == name {
+				panic(fmt.Sprintf("router: multiple registrations for %v", name))
+			}
+		}
+		m.globs = append(m.globs, globHandler{name, handler})
+		return
+	}
+
+	if _, ok := m.exact[name]; ok {
+		panic(fmt.Sprintf("router: multiple registrations for %v", name))
+	}
+	m.exact[name] = handler
+}
+
+// HandleFunc registers the handler function for the given route name (see
+// Handle).
+func (m *Mux) HandleFunc(name string, handler func(http.ResponseWriter, *http.Request)) {
+	m.Handle(name, http.HandlerFunc(handler))
+}
+
+// Handler returns the handler registered for the given route name, or nil if
+// there is none.
+func (m *Mux) Handler(name string) http.Handler {
+	if h, ok := m.exact[name]; ok {
+		return h
+	}
+	for _, g := range m.globs {
+		if glob.Glob(g.pattern, name) {
+			return g.handler
+		}
+	}
+	return nil
+}
+
+func (m *Mux) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	result, status := RouteHost(m.router, req.Method, req.Host, requestURL(req))
+
+	switch status {
+	case Found:
+		if h := m.Handler(result.Name); h != nil {
+			h.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), contextKey{}, result)))
+			return
+		}
+	case MethodNotAllowed:
+		w.Header().Set("Allow", result.Allow())
+		if m.MethodNotAllowed != nil {
+			m.MethodNotAllowed.ServeHTTP(w, req)
+			return
+		}
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	case AutomaticOptions:
+		w.Header().Set("Allow", result.Allow())
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if m.NotFound != nil {
+		m.NotFound.ServeHTTP(w, req)
+		return
+	}
+	http.NotFound(w, req)
+}
+
+// FromContext returns the RouteResult added to a request's context by Mux.
+func FromContext(ctx context.Context) (RouteResult, bool) {
+	result, ok := ctx.Value(contextKey{}).(RouteResult)
+	return result, ok
+}
+
+func requestURL(req *http.Request) string {
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if req.URL.RawQuery != "" || req.URL.ForceQuery {
+		return path + "?" + req.URL.RawQuery
+	}
+	return path
+}
@@ -0,0 +1,112 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMux(t *testing.T) {
+	const routeFile = `
+root /
+managers /managers
+  login    [GET,POST] /login
+  user     /:manager_id/user/:user_id
+users /users
+  profile  /:user_id/profile
+  settings /:user_id/settings
+`
+
+	testRouterWithOptions(t, routeFile, Options{AutoOptions: true}, func(router *Router) {
+		mux := NewMux(router)
+		handler := func(label string) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				result, ok := FromContext(r.Context())
+				if !ok {
+					t.Errorf("Expected RouteResult in context")
+				}
+				fmt.Fprintf(w, "%v %v %v %v", label, result.Name, result.Params, result.Query)
+			}
+		}
+		mux.Handle("root", handler("root"))
+		mux.Handle("managers/*", handler("managers"))
+		mux.Handle("users/profile", handler("profile"))
+		mux.Handle("users/*", handler("users"))
+
+		assertMuxResponse(t, mux, "GET", "/", http.StatusOK, "root root map[] ")
+		assertMuxResponse(t, mux, "POST", "/managers/login", http.StatusOK, "managers managers/login map[] ")
+		assertMuxResponse(t, mux, "GET", "/managers/12/user/7?x=y", http.StatusOK, "managers managers/user map[manager_id:12 user_id:7] ?x=y")
+		assertMuxResponse(t, mux, "GET", "/users/a%2fb/profile", http.StatusOK, "profile users/profile map[user_id:a%2fb] ")
+		assertMuxResponse(t, mux, "GET", "/users/12/settings", http.StatusOK, "users users/settings map[user_id:12] ")
+		assertMuxResponse(t, mux, "GET", "/nope", http.StatusNotFound, "404 page not found\n")
+
+		rec := assertMuxResponse(t, mux, "PUT", "/managers/login", http.StatusMethodNotAllowed, "Method Not Allowed\n")
+		if allow := rec.Header().Get("Allow"); allow != "GET, OPTIONS, POST" {
+			t.Errorf("Unexpected Allow header %v\n", allow)
+		}
+		rec = assertMuxResponse(t, mux, "OPTIONS", "/managers/login", http.StatusNoContent, "")
+		if allow := rec.Header().Get("Allow"); allow != "GET, OPTIONS, POST" {
+			t.Errorf("Unexpected Allow header %v\n", allow)
+		}
+	})
+}
+
+func TestMuxNoHandler(t *testing.T) {
+	testRouter(t, "foo /foo\nbar /bar", false, func(router *Router) {
+		mux := NewMux(router)
+		mux.HandleFunc("foo", func(w http.ResponseWriter, r *http.Request) {})
+		mux.NotFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "custom", http.StatusNotFound)
+		})
+
+		assertMuxResponse(t, mux, "GET", "/foo", http.StatusOK, "")
+		assertMuxResponse(t, mux, "GET", "/bar", http.StatusNotFound, "custom\n")
+	})
+}
+
+func TestMuxDuplicateRegistration(t *testing.T) {
+	testRouter(t, "foo /foo", false, func(router *Router) {
+		mux := NewMux(router)
+		mux.HandleFunc("foo/*", func(w http.ResponseWriter, r *http.Request) {})
+
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Expected duplicate registration to panic")
+			}
+		}()
+		mux.HandleFunc("foo/*", func(w http.ResponseWriter, r *http.Request) {})
+	})
+}
+
+func TestMuxHosts(t *testing.T) {
+	const routeFile = `
+api @api.example.com /about
+www @www.example.com /about
+`
+
+	testRouter(t, routeFile, false, func(router *Router) {
+		mux := NewMux(router)
+		for _, name := range []string{"api", "www"} {
+			mux.HandleFunc(name, func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, name)
+			})
+		}
+
+		assertMuxResponse(t, mux, "GET", "http://api.example.com/about", http.StatusOK, "api")
+		assertMuxResponse(t, mux, "GET", "http://www.example.com:8080/about", http.StatusOK, "www")
+		assertMuxResponse(t, mux, "GET", "http://example.com/about", http.StatusNotFound, "404 page not found\n")
+	})
+}
+
+func assertMuxResponse(t *testing.T, mux *Mux, method, url string, expectedCode int, expectedBody string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(method, url, nil))
+	if rec.Code != expectedCode {
+		t.Errorf("Expected %v %v to give status %v, got %v\n", method, url, expectedCode, rec.Code)
+	}
+	if rec.Body.String() != expectedBody {
+		t.Errorf("Expected %v %v to give body %q, got %q\n", method, url, expectedBody, rec.Body.String())
+	}
+	return rec
+}
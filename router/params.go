@@ -0,0 +1,248 @@
+package router
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParamKind is the kind of a named parameter in a route pattern.
+type ParamKind int
+
+const (
+	// StringParam is the kind of parameters written ':name'.
+	StringParam ParamKind = iota
+	// IntegerParam is the kind of parameters written ':#name'.
+	IntegerParam
+	// RestParam is the kind of parameters written ':**name'.
+	RestParam
+	// UUIDParam is the kind of parameters written ':uuid:name'.
+	UUIDParam
+	// SlugParam is the kind of parameters written ':slug:name'.
+	SlugParam
+	// DateParam is the kind of parameters written ':date:name'.
+	DateParam
+	// HexParam is the kind of parameters written ':hex:name'.
+	HexParam
+)
+
+var paramKindNames = map[ParamKind]string{
+	StringParam:  "string",
+	IntegerParam: "integer",
+	RestParam:    "rest",
+	UUIDParam:    "uuid",
+	SlugParam:    "slug",
+	DateParam:    "date",
+	HexParam:     "hex",
+}
+
+func (k ParamKind) String() string {
+	if name, ok := paramKindNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("ParamKind(%v)", int(k))
+}
+
+func (k *ParamKind) UnmarshalJSON(input []byte) error {
+	var s string
+	if err := json.Unmarshal(input, &s); err != nil {
+		return err
+	}
+	kind, ok := paramKindFromName(s)
+	if !ok {
+		return fmt.Errorf("unrecognized parameter kind %q", s)
+	}
+	*k = kind
+	return nil
+}
+
+func paramKindFromName(name string) (ParamKind, bool) {
+	for k, n := range paramKindNames {
+		if n == name {
+			return k, true
+		}
+	}
+	return 0, false
+}
+
+var (
+	// ErrNoSuchParam is returned by the typed parameter getters of RouteResult
+	// if the route has no parameter with the given name.
+	ErrNoSuchParam = errors.New("no parameter with the given name")
+	// ErrAbsentParam is returned by the typed parameter getters of RouteResult
+	// if the parameter is in an optional group that is absent from the URL.
+	ErrAbsentParam = errors.New("parameter is absent from the URL")
+	// ErrWrongParamKind is returned by the typed parameter getters of
+	// RouteResult if the parameter is not of the kind required by the getter.
+	ErrWrongParamKind = errors.New("parameter is not of the required kind")
+	// ErrIntegerOverflow is returned by RouteResult.Int64 if the value of an
+	// integer parameter is outside the range of an int64.
+	ErrIntegerOverflow = errors.New("integer parameter value out of range")
+	// ErrBadParamValue is returned by RouteResult.UUID, RouteResult.Date and
+	// RouteResult.Hex if the value of the parameter cannot be decoded.
+	ErrBadParamValue = errors.New("parameter value cannot be decoded")
+)
+
+func (r *RouteResult) param(name string, kind ParamKind) (string, error) {
+	v, ok := r.Params[name]
+	if !ok {
+		if _, ok := r.ParamKinds[name]; ok {
+			return "", fmt.Errorf("%w: %v", ErrAbsentParam, name)
+		}
+		return "", fmt.Errorf("%w: %v", ErrNoSuchParam, name)
+	}
+	if r.ParamKinds[name] != kind {
+		return "", fmt.Errorf("%w: %v is a %v parameter", ErrWrongParamKind, name, r.ParamKinds[name])
+	}
+	return v, nil
+}
+
+// Int64 returns the value of an integer parameter.
+func (r *RouteResult) Int64(name string) (int64, error) {
+	v, err := r.param(name, IntegerParam)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		return 0, fmt.Errorf("%w: %v", ErrIntegerOverflow, name)
+	}
+	return n, err
+}
+
+// UUID returns the value of a uuid parameter.
+func (r *RouteResult) UUID(name string) ([16]byte, error) {
+	var u [16]byte
+	v, err := r.param(name, UUIDParam)
+	if err != nil {
+		return u, err
+	}
+	u, ok := parseUUID(v)
+	if !ok {
+		return u, fmt.Errorf("%w: bad uuid value for %v", ErrBadParamValue, name)
+	}
+	return u, nil
+}
+
+// Date returns the value of a date parameter as a time at midnight UTC. An
+// error is returned for dates such as 2023-02-30 that are matched by the
+// route but do not exist.
+func (r *RouteResult) Date(name string) (time.Time, error) {
+	v, err := r.param(name, DateParam)
+	if err != nil {
+		return time.Time{}, err
+	}
+	t, err := time.Parse(time.DateOnly, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %v: %w", ErrBadParamValue, name, err)
+	}
+	return t, nil
+}
+
+// Hex returns the bytes encoded by the value of a hex parameter. An error is
+// returned if the value has an odd number of digits.
+func (r *RouteResult) Hex(name string) ([]byte, error) {
+	v, err := r.param(name, HexParam)
+	if err != nil {
+		return nil, err
+	}
+	b, err := hex.DecodeString(v)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v: %w", ErrBadParamValue, name, err)
+	}
+	return b, nil
+}
+
+func parseUUID(v string) ([16]byte, bool) {
+	var u [16]byte
+	if len(v) != 36 || v[8] != '-' || v[13] != '-' || v[18] != '-' || v[23] != '-' {
+		return u, false
+	}
+	b, err := hex.DecodeString(v[0:8] + v[9:13] + v[14:18] + v[19:23] + v[24:36])
+	if err != nil {
+		return u, false
+	}
+	copy(u[:], b)
+	return u, true
+}
+
+// validTypedParam reports whether v is a valid value for a parameter of one of
+// the built-in parameter types.
+func validTypedParam(kind ParamKind, v string) bool {
+	switch kind {
+	case UUIDParam:
+		_, ok := parseUUID(v)
+		return ok
+	case SlugParam:
+		for _, part := range strings.Split(v, "-") {
+			if part == "" || strings.IndexFunc(part, func(c rune) bool { return !(c >= 'a' && c <= 'z') && !(c >= '0' && c <= '9') }) != -1 {
+				return false
+			}
+		}
+		return true
+	case DateParam:
+		_, err := time.Parse(time.DateOnly, v)
+		return err == nil
+	case HexParam:
+		return v != "" && strings.Trim(v, "0123456789abcdefABCDEF") == ""
+	}
+	return true
+}
+
+// Segments returns the value of a rest parameter split into its non-empty
+// '/'-separated segments. If the DecodeParams option is used, the raw value is
+// split before the segments are decoded, so that an encoded '/' in a segment
+// does not split it.
+func (r *RouteResult) Segments(name string) ([]string, error) {
+	v, err := r.param(name, RestParam)
+	if err != nil {
+		return nil, err
+	}
+	if r.RawParams == nil {
+		return splitSegments(v), nil
+	}
+
+	segments := splitSegments(r.RawParams[name])
+	for i := range segments {
+		segments[i], err = url.PathUnescape(segments[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return segments, nil
+}
+
+func splitSegments(v string) []string {
+	return strings.FieldsFunc(v, func(c rune) bool { return c == '/' })
+}
+
+func decodeParams(result *RouteResult) bool {
+	decoded := make(map[string]string, len(result.Params))
+	for name, v := range result.Params {
+		if result.ParamKinds[name] != RestParam && containsEncodedSlash(v) {
+			return false
+		}
+		d, err := url.PathUnescape(v)
+		if err != nil {
+			return false
+		}
+		decoded[name] = d
+	}
+	result.RawParams = result.Params
+	result.Params = decoded
+	return true
+}
+
+func containsEncodedSlash(v string) bool {
+	for i := 0; i+2 < len(v); i++ {
+		if v[i] == '%' && v[i+1] == '2' && (v[i+2] == 'f' || v[i+2] == 'F') {
+			return true
+		}
+	}
+	return false
+}
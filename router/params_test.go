@@ -0,0 +1,208 @@
+package router
+
+import (
+	"encoding/hex"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTypedParams(t *testing.T) {
+	const routeFile = `
+files /files/:owner
+  file /:#id/:**path
+`
+
+	testRouter(t, routeFile, false, func(router *Router) {
+		result, ok := Route(router, "/files/bob/-42/a//b/c/")
+		if !ok {
+			t.Fatalf("Expected route to be found")
+		}
+
+		expectedKinds := map[string]ParamKind{"owner": StringParam, "id": IntegerParam, "path": RestParam}
+		if !reflect.DeepEqual(result.ParamKinds, expectedKinds) {
+			t.Errorf("Expected param kinds %+v, got %+v\n", expectedKinds, result.ParamKinds)
+		}
+
+		id, err := result.Int64("id")
+		if err != nil || id != -42 {
+			t.Errorf("Expected id -42, got %v (%v)\n", id, err)
+		}
+
+		segments, err := result.Segments("path")
+		if err != nil || !reflect.DeepEqual(segments, []string{"a", "b", "c"}) {
+			t.Errorf("Expected segments [a b c], got %+v (%v)\n", segments, err)
+		}
+
+		if _, err := result.Int64("owner"); !errors.Is(err, ErrWrongParamKind) {
+			t.Errorf("Expected ErrWrongParamKind, got %v\n", err)
+		}
+		if _, err := result.Segments("id"); !errors.Is(err, ErrWrongParamKind) {
+			t.Errorf("Expected ErrWrongParamKind, got %v\n", err)
+		}
+		if _, err := result.Int64("nope"); !errors.Is(err, ErrNoSuchParam) {
+			t.Errorf("Expected ErrNoSuchParam, got %v\n", err)
+		}
+
+		result, ok = Route(router, "/files/bob/99999999999999999999/x")
+		if !ok {
+			t.Fatalf("Expected route to be found")
+		}
+		if _, err := result.Int64("id"); !errors.Is(err, ErrIntegerOverflow) {
+			t.Errorf("Expected ErrIntegerOverflow, got %v\n", err)
+		}
+	})
+}
+
+func TestAbsentParams(t *testing.T) {
+	const routeFile = `
+posts /posts[/page/:#n]
+`
+
+	testRouter(t, routeFile, false, func(router *Router) {
+		result, ok := Route(router, "/posts")
+		if !ok {
+			t.Fatalf("Expected route to be found")
+		}
+		if _, ok := result.Params["n"]; ok {
+			t.Errorf("Expected absent parameter to be omitted from Params\n")
+		}
+		if result.ParamKinds["n"] != IntegerParam {
+			t.Errorf("Expected absent parameter to have a kind, got %+v\n", result.ParamKinds)
+		}
+		if _, err := result.Int64("n"); !errors.Is(err, ErrAbsentParam) {
+			t.Errorf("Expected ErrAbsentParam, got %v\n", err)
+		}
+
+		result, ok = Route(router, "/posts/page/3")
+		if !ok {
+			t.Fatalf("Expected route to be found")
+		}
+		if n, err := result.Int64("n"); err != nil || n != 3 {
+			t.Errorf("Expected n 3, got %v (%v)\n", n, err)
+		}
+	})
+}
+
+func TestBuiltInParamTypes(t *testing.T) {
+	const routeFile = `
+item    /items/:uuid:id
+post    /posts/:slug:slug
+archive /archive/:date:day
+blob    /blobs/:hex:digest
+other   /items/:name
+`
+
+	testRouter(t, routeFile, false, func(router *Router) {
+		result, ok := Route(router, "/items/123e4567-e89b-12d3-a456-426614174000")
+		if !ok || result.Name != "item" || result.ParamKinds["id"] != UUIDParam {
+			t.Fatalf("Expected uuid route to be found, got %+v\n", result)
+		}
+		u, err := result.UUID("id")
+		if err != nil || u != [16]byte{0x12, 0x3e, 0x45, 0x67, 0xe8, 0x9b, 0x12, 0xd3, 0xa4, 0x56, 0x42, 0x66, 0x14, 0x17, 0x40, 0x00} {
+			t.Errorf("Unexpected uuid %v (%v)\n", u, err)
+		}
+		assertRoute(t, router, "/items/123e4567", "other", map[string]string{"name": "123e4567"}, "", "", []string{"GET"}, []string{})
+
+		assertRoute(t, router, "/posts/hello-world", "post", map[string]string{"slug": "hello-world"}, "", "", []string{"GET"}, []string{})
+		assertNoRoute(t, router, "/posts/hello--world")
+
+		result, ok = Route(router, "/archive/2024-02-29")
+		if !ok || result.Name != "archive" {
+			t.Fatalf("Expected date route to be found, got %+v\n", result)
+		}
+		day, err := result.Date("day")
+		if err != nil || day != time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC) {
+			t.Errorf("Unexpected date %v (%v)\n", day, err)
+		}
+		assertNoRoute(t, router, "/archive/2024-13-01")
+		result, _ = Route(router, "/archive/2023-02-29")
+		if _, err := result.Date("day"); !errors.Is(err, ErrBadParamValue) {
+			t.Errorf("Expected ErrBadParamValue for a nonexistent date, got %v\n", err)
+		}
+
+		result, ok = Route(router, "/blobs/c0ffee")
+		if !ok || result.Name != "blob" {
+			t.Fatalf("Expected hex route to be found, got %+v\n", result)
+		}
+		digest, err := result.Hex("digest")
+		if err != nil || !reflect.DeepEqual(digest, []byte{0xc0, 0xff, 0xee}) {
+			t.Errorf("Unexpected digest %v (%v)\n", digest, err)
+		}
+		if _, err := result.UUID("digest"); !errors.Is(err, ErrWrongParamKind) {
+			t.Errorf("Expected ErrWrongParamKind, got %v\n", err)
+		}
+		result, ok = Route(router, "/blobs/c0ffe")
+		if !ok || result.Name != "blob" {
+			t.Fatalf("Expected hex route to be found, got %+v\n", result)
+		}
+		if _, err := result.Hex("digest"); !errors.Is(err, ErrBadParamValue) || !errors.Is(err, hex.ErrLength) {
+			t.Errorf("Expected ErrBadParamValue for an odd number of digits, got %v\n", err)
+		}
+		bad := RouteResult{Params: map[string]string{"id": "nope"}, ParamKinds: map[string]ParamKind{"id": UUIDParam}}
+		if _, err := bad.UUID("id"); !errors.Is(err, ErrBadParamValue) {
+			t.Errorf("Expected ErrBadParamValue for a bad uuid, got %v\n", err)
+		}
+
+		assertBuildURL(t, router, "item", map[string]string{"id": "123e4567-e89b-12d3-a456-426614174000"}, "/items/123e4567-e89b-12d3-a456-426614174000", nil)
+		assertBuildURL(t, router, "item", map[string]string{"id": "123e4567"}, "", ErrBadTypedParam)
+		assertBuildURL(t, router, "post", map[string]string{"slug": "Hello"}, "", ErrBadTypedParam)
+		assertBuildURL(t, router, "archive", map[string]string{"day": "2024-01-02"}, "/archive/2024-01-02", nil)
+	})
+}
+
+func TestDecodeParams(t *testing.T) {
+	const routeFile = `
+file  /files/:name
+tree  /tree/:owner/:**path
+`
+
+	testRouterWithOptions(t, routeFile, Options{CaseSensitive: true, DecodeParams: true}, func(router *Router) {
+		result, ok := Route(router, "/files/a%20b%C3%A9")
+		if !ok {
+			t.Fatalf("Expected route to be found")
+		}
+		if result.Params["name"] != "a bé" {
+			t.Errorf("Expected decoded name, got %q\n", result.Params["name"])
+		}
+		if result.RawParams["name"] != "a%20b%C3%A9" {
+			t.Errorf("Expected raw name, got %q\n", result.RawParams["name"])
+		}
+
+		if _, ok := Route(router, "/files/a%2Fb"); ok {
+			t.Errorf("Expected encoded '/' in string parameter to be rejected\n")
+		}
+		if _, ok := Route(router, "/files/a%2fb"); ok {
+			t.Errorf("Expected encoded '/' in string parameter to be rejected\n")
+		}
+		if _, ok := Route(router, "/files/a%zzb"); ok {
+			t.Errorf("Expected invalid encoding to be rejected\n")
+		}
+		if _, status := RouteMethod(router, "GET", "/files/a%2Fb"); status != NotFound {
+			t.Errorf("Expected NotFound, got %v\n", status)
+		}
+
+		result, ok = Route(router, "/tree/bob/x%2Fy/z%20w")
+		if !ok {
+			t.Fatalf("Expected route to be found")
+		}
+		if result.Params["path"] != "x/y/z w" {
+			t.Errorf("Expected decoded path, got %q\n", result.Params["path"])
+		}
+		segments, err := result.Segments("path")
+		if err != nil || !reflect.DeepEqual(segments, []string{"x/y", "z w"}) {
+			t.Errorf("Expected segments [x/y z w], got %+v (%v)\n", segments, err)
+		}
+	})
+
+	testRouterWithOptions(t, routeFile, Options{CaseSensitive: true}, func(router *Router) {
+		result, ok := Route(router, "/files/a%2Fb")
+		if !ok {
+			t.Fatalf("Expected route to be found")
+		}
+		if result.Params["name"] != "a%2Fb" || result.RawParams != nil {
+			t.Errorf("Expected raw parameter values without DecodeParams, got %+v %+v\n", result.Params, result.RawParams)
+		}
+	})
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"regexp"
+	"slices"
+	"sort"
 	"strings"
 )
 
@@ -12,11 +14,22 @@ type Router struct {
 }
 
 type router struct {
+	table
+	Hosts         map[string]table // present only if some route is restricted to particular hosts
+	CaseSensitive bool
+	HeadAsGet     bool                  `json:"-"`
+	AutoOptions   bool                  `json:"-"`
+	DecodeParams  bool                  `json:"-"`
+	Templates     map[string][]template `json:"-"`
+}
+
+// A table holds the regexps and families for a set of routes: either all the
+// routes, or the routes that accept a given host pattern.
+type table struct {
 	ConstantPortionRegexp  myRegexp
 	ConstantPortionNGroups int
 	Families               map[string]family
 	Repl                   string
-	CaseSensitive          bool
 }
 
 type family struct {
@@ -24,13 +37,19 @@ type family struct {
 	NLevels              int
 	NonparamGroupNumbers []int
 	Members              []familyMember
+	MethodFamilies       map[string]family // present only if members differ in their methods
 }
 
 type familyMember struct {
 	Name              string
 	ParamGroupNumbers map[string]int
+	ParamKinds        map[string]ParamKind
 	Tags              []string
 	Methods           []string
+	Template          []templateElem
+	Alternatives      []string
+	Meta              map[string]string
+	Hosts             []string
 }
 
 type myRegexp struct { // wrapper to allow custom deserialization
@@ -50,8 +69,34 @@ func (myRe *myRegexp) UnmarshalJSON(input []byte) error {
 	return nil
 }
 
+// Options configures a Router constructed using MakeRouterWithOptions.
+type Options struct {
+	// CaseSensitive disables normalization of URLs to lower case.
+	CaseSensitive bool
+	// HeadAsGet makes RouteMethod route a HEAD request to the GET route for the
+	// URL if there is no HEAD route for it.
+	HeadAsGet bool
+	// AutoOptions makes RouteMethod return AutomaticOptions for an OPTIONS
+	// request if there is no OPTIONS route for the URL but there is a route for
+	// some other method.
+	AutoOptions bool
+	// DecodeParams makes Route and RouteMethod return percent-decoded parameter
+	// values. URLs are still matched in their escaped form, and the raw
+	// parameter values are available in the RawParams field of RouteResult. A
+	// URL is not matched if the value of a parameter other than a rest parameter
+	// contains an encoded '/' ("%2F") or if a parameter value is not validly
+	// encoded.
+	DecodeParams bool
+}
+
 // MakeRouter constructs a Router from JSON input
 func MakeRouter(jsonInput []byte, caseSensitive bool) (Router, error) {
+	return MakeRouterWithOptions(jsonInput, Options{CaseSensitive: caseSensitive})
+}
+
+// MakeRouterWithOptions constructs a Router from JSON input with the given
+// options.
+func MakeRouterWithOptions(jsonInput []byte, options Options) (Router, error) {
 	var r Router
 
 	err := json.Unmarshal(jsonInput, &r.router)
@@ -59,43 +104,281 @@ func MakeRouter(jsonInput []byte, caseSensitive bool) (Router, error) {
 		return r, err
 	}
 
+	r.router.Repl = makeRepl(r.router.ConstantPortionNGroups)
+	for h, t := range r.router.Hosts {
+		t.Repl = makeRepl(t.ConstantPortionNGroups)
+		r.router.Hosts[h] = t
+	}
+	r.router.CaseSensitive = options.CaseSensitive
+	r.router.HeadAsGet = options.HeadAsGet
+	r.router.AutoOptions = options.AutoOptions
+	r.router.DecodeParams = options.DecodeParams
+	r.router.Templates = indexTemplates(r.router.Families)
+
+	return r, nil
+}
+
+func makeRepl(constantPortionNGroups int) string {
 	var repl strings.Builder
 	repl.WriteRune(' ') // pad output with arbitrary additional initial char so that output will never be equal to input
-	for i := 1; i <= r.router.ConstantPortionNGroups; i++ {
+	for i := 1; i <= constantPortionNGroups; i++ {
 		repl.WriteString(fmt.Sprintf("$%v", i))
 	}
-	r.router.Repl = repl.String()
-	r.router.CaseSensitive = caseSensitive
-
-	return r, nil
+	return repl.String()
 }
 
 // RouteResult represents the result of attempting to route a URL.
 type RouteResult struct {
-	Name    string
-	Params  map[string]string
-	Query   string
-	Anchor  string
-	Tags    []string
-	Methods []string
+	Name       string
+	Params     map[string]string
+	RawParams  map[string]string // set only if the DecodeParams option is used
+	ParamKinds map[string]ParamKind
+	Query      string
+	Anchor     string
+	Tags       []string
+	Methods    []string
+	// Alternatives holds the alternative that matched for each alternation in
+	// the route's pattern (e.g. "images" for '/(photos|images)/:id'), in order
+	// of their occurrence in the pattern.
+	Alternatives []string
+	// Meta holds the route's 'key=value' metadata, including metadata
+	// inherited from parent routes.
+	Meta map[string]string
+	// Hosts holds the host patterns that the route is restricted to, including
+	// host patterns inherited from parent routes. It is empty if the route
+	// accepts any host.
+	Hosts []string
 }
 
+// Status classifies the result of routing a URL with RouteMethod.
+type Status int
+
+const (
+	// NotFound indicates that no route matches the URL.
+	NotFound Status = iota
+	// Found indicates that a route matches the URL and the method.
+	Found
+	// MethodNotAllowed indicates that at least one route matches the URL, but
+	// none of the matching routes accepts the method. The Methods field of the
+	// RouteResult holds the methods that are accepted.
+	MethodNotAllowed
+	// AutomaticOptions indicates that the method is OPTIONS, that the Router
+	// was constructed with the AutoOptions option, and that some route other
+	// than an OPTIONS route matches the URL. The Methods field of the
+	// RouteResult holds the methods that are accepted.
+	AutomaticOptions
+)
+
+func (s Status) String() string {
+	switch s {
+	case NotFound:
+		return "NotFound"
+	case Found:
+		return "Found"
+	case MethodNotAllowed:
+		return "MethodNotAllowed"
+	case AutomaticOptions:
+		return "AutomaticOptions"
+	}
+	return fmt.Sprintf("Status(%v)", int(s))
+}
+
+// Allow returns the value of the Allow header for a response to a request that
+// was routed with RouteMethod.
+func (r *RouteResult) Allow() string {
+	return strings.Join(r.Methods, ", ")
+}
+
+// Route routes a URL without regard to the HTTP method. If routes with
+// different methods share the same pattern, only one of them can be returned.
+// Use RouteMethod to route by method and URL.
+// Route routes a URL without regard to the method. Routes restricted to
+// particular hosts are ignored (see RouteHost).
 func Route(r *Router, url string) (RouteResult, bool) {
+	return routeInTable(r, hostTable(r, ""), url)
+}
+
+func routeInTable(r *Router, t *table, url string) (RouteResult, bool) {
 	if !r.router.CaseSensitive {
 		url = normalizeUrl(url)
 	}
 
-	cp := r.router.ConstantPortionRegexp.re.ReplaceAllString(url, r.router.Repl)
-	if cp == url {
+	family, ok := findFamily(t, url)
+	if !ok {
 		return RouteResult{}, false
 	}
-	cp = cp[1:] // Remove initial padding char in output
 
-	family, ok := r.router.Families[cp]
+	result, ok := matchFamily(family, url)
+	if ok && r.router.DecodeParams {
+		ok = decodeParams(&result)
+	}
+	return result, ok
+}
+
+// RouteMethod routes an HTTP method and URL. It returns NotFound if no route
+// matches the URL and MethodNotAllowed if some route matches the URL but not
+// the method. In the latter case the Query, Anchor and Methods fields of the
+// RouteResult are filled in. The HeadAsGet and AutoOptions options modify the
+// handling of HEAD and OPTIONS requests and add HEAD and OPTIONS to the list of
+// methods returned with MethodNotAllowed. Routes restricted to particular hosts
+// are ignored (see RouteHost).
+func RouteMethod(r *Router, method string, url string) (RouteResult, Status) {
+	return routeMethodInTable(r, hostTable(r, ""), method, url)
+}
+
+// RouteHost routes an HTTP method, host and URL in the same way as RouteMethod,
+// except that routes restricted to the given host are also considered. Routes
+// restricted to other hosts are ignored. The host may include a port, which is
+// ignored.
+func RouteHost(r *Router, method string, host string, url string) (RouteResult, Status) {
+	return routeMethodInTable(r, hostTable(r, host), method, url)
+}
+
+// hostTable returns the table for the routes that accept the given host. This
+// is the table for the host itself if there is one, then the table for the
+// wildcard pattern that matches it (e.g. '*.foo.com' for 'bar.foo.com'), and
+// otherwise (or if the host is empty) the table for routes that accept any
+// host.
+func hostTable(r *Router, host string) *table {
+	if r.router.Hosts == nil {
+		return &r.router.table
+	}
+
+	host = normalizeHost(host)
+	if t, ok := r.router.Hosts[host]; ok {
+		return &t
+	}
+	if dot := strings.IndexByte(host, '.'); dot > 0 {
+		if t, ok := r.router.Hosts["*"+host[dot:]]; ok {
+			return &t
+		}
+	}
+	t := r.router.Hosts[""]
+	return &t
+}
+
+// normalizeHost normalizes a host as given in the Host header of an HTTP
+// request (or as the host of a URL) to lower case and removes any port and
+// any trailing '.'.
+func normalizeHost(host string) string {
+	if i := strings.LastIndexByte(host, ':'); i != -1 && !strings.Contains(host[i:], "]") {
+		host = host[:i]
+	}
+	host = strings.TrimSuffix(host, ".")
+	return strings.ToLower(host)
+}
+
+func routeMethodInTable(r *Router, t *table, method string, url string) (RouteResult, Status) {
+	if !r.router.CaseSensitive {
+		url = normalizeUrl(url)
+	}
+
+	fam, ok := findFamily(t, url)
 	if !ok {
-		return RouteResult{}, false
+		return RouteResult{}, NotFound
+	}
+
+	result, status := routeMethodInFamily(fam, method, url)
+	switch status {
+	case NotFound:
+		return result, NotFound
+	case Found:
+		return found(r, result)
+	}
+
+	if method == "HEAD" && r.router.HeadAsGet && slices.Contains(result.Methods, "GET") {
+		if getResult, status := routeMethodInFamily(fam, "GET", url); status == Found {
+			return found(r, getResult)
+		}
+	}
+
+	result.Methods = withImplicitMethods(r, result.Methods)
+
+	if method == "OPTIONS" && r.router.AutoOptions {
+		return result, AutomaticOptions
+	}
+	return result, MethodNotAllowed
+}
+
+func found(r *Router, result RouteResult) (RouteResult, Status) {
+	if r.router.DecodeParams && !decodeParams(&result) {
+		return RouteResult{}, NotFound
+	}
+	return result, Found
+}
+
+func routeMethodInFamily(fam *family, method string, url string) (RouteResult, Status) {
+	if fam.MethodFamilies == nil {
+		result, ok := matchFamily(fam, url)
+		if !ok {
+			return RouteResult{}, NotFound
+		}
+		if slices.Contains(result.Methods, method) {
+			return result, Found
+		}
+		return RouteResult{
+			Query:   result.Query,
+			Anchor:  result.Anchor,
+			Methods: result.Methods,
+		}, MethodNotAllowed
+	}
+
+	if mf, ok := fam.MethodFamilies[method]; ok {
+		if result, ok := matchFamily(&mf, url); ok {
+			return result, Found
+		}
 	}
 
+	var notAllowed RouteResult
+	for m, mf := range fam.MethodFamilies {
+		if result, ok := matchFamily(&mf, url); ok {
+			notAllowed.Query = result.Query
+			notAllowed.Anchor = result.Anchor
+			notAllowed.Methods = append(notAllowed.Methods, m)
+		}
+	}
+	if len(notAllowed.Methods) == 0 {
+		return RouteResult{}, NotFound
+	}
+	sort.Strings(notAllowed.Methods)
+	return notAllowed, MethodNotAllowed
+}
+
+func withImplicitMethods(r *Router, methods []string) []string {
+	// Copy, as the slice may belong to a family member.
+	ms := append([]string{}, methods...)
+	if r.router.HeadAsGet && slices.Contains(ms, "GET") && !slices.Contains(ms, "HEAD") {
+		ms = append(ms, "HEAD")
+	}
+	if r.router.AutoOptions && !slices.Contains(ms, "OPTIONS") {
+		ms = append(ms, "OPTIONS")
+	}
+	sort.Strings(ms)
+	return ms
+}
+
+func findFamily(t *table, url string) (*family, bool) {
+	cp, ok := constantPortion(t, url)
+	if !ok {
+		return nil, false
+	}
+
+	family, ok := t.Families[cp]
+	if !ok {
+		return nil, false
+	}
+	return &family, true
+}
+
+func constantPortion(t *table, url string) (string, bool) {
+	cp := t.ConstantPortionRegexp.re.ReplaceAllString(url, t.Repl)
+	if cp == url {
+		return "", false
+	}
+	return cp[1:], true // Remove initial padding char in output
+}
+
+func matchFamily(family *family, url string) (RouteResult, bool) {
 	submatches := family.MatchRegexp.re.FindStringSubmatch(url)
 	if submatches == nil {
 		return RouteResult{}, false
@@ -107,16 +390,25 @@ func Route(r *Router, url string) (RouteResult, bool) {
 	member := family.Members[groupIndex]
 
 	for paramGroupName, n := range member.ParamGroupNumbers {
-		params[paramGroupName] = submatches[n]
+		// A parameter's value is never empty, so an empty submatch means that
+		// the parameter is in an optional group that is absent from the URL.
+		// Such parameters are omitted from Params (but not from ParamKinds).
+		if submatches[n] != "" {
+			params[paramGroupName] = submatches[n]
+		}
 	}
 
 	return RouteResult{
-		Name:    member.Name,
-		Params:  params,
-		Query:   submatches[len(submatches)-2],
-		Anchor:  submatches[len(submatches)-1],
-		Tags:    member.Tags,
-		Methods: member.Methods,
+		Name:         member.Name,
+		Params:       params,
+		ParamKinds:   member.ParamKinds,
+		Query:        submatches[len(submatches)-2],
+		Anchor:       submatches[len(submatches)-1],
+		Tags:         member.Tags,
+		Methods:      member.Methods,
+		Alternatives: member.Alternatives,
+		Meta:         member.Meta,
+		Hosts:        member.Hosts,
 	}, true
 }
 
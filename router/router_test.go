@@ -215,6 +215,304 @@ func TestRouterBinarySearch(t *testing.T) {
 	}
 }
 
+func TestRouteMethod(t *testing.T) {
+	const routeFile = `
+managers /managers
+  login    [GET] /login
+  login    [POST] /login
+  get      [GET] /:manager_id/x
+  put      [PUT] /:{manager id}/x
+users /users
+  profile  [GET,POST] /:user_id/profile
+`
+
+	testRouter(t, routeFile, false, func(router *Router) {
+		assertRouteMethod(t, router, "GET", "/managers/login", Found, "managers/login", map[string]string{}, []string{"GET"})
+		assertRouteMethod(t, router, "POST", "/managers/login", Found, "managers/login", map[string]string{}, []string{"POST"})
+		assertRouteMethod(t, router, "PUT", "/managers/login", MethodNotAllowed, "", nil, []string{"GET", "POST"})
+		assertRouteMethod(t, router, "GET", "/managers/12/x", Found, "managers/get", map[string]string{"manager_id": "12"}, []string{"GET"})
+		assertRouteMethod(t, router, "PUT", "/managers/12/x", Found, "managers/put", map[string]string{"manager id": "12"}, []string{"PUT"})
+		assertRouteMethod(t, router, "DELETE", "/managers/12/x", MethodNotAllowed, "", nil, []string{"GET", "PUT"})
+		assertRouteMethod(t, router, "POST", "/users/12/profile", Found, "users/profile", map[string]string{"user_id": "12"}, []string{"GET", "POST"})
+		assertRouteMethod(t, router, "PUT", "/users/12/profile", MethodNotAllowed, "", nil, []string{"GET", "POST"})
+		assertRouteMethod(t, router, "GET", "/users/12/nope", NotFound, "", nil, nil)
+		assertRouteMethod(t, router, "GET", "/nope", NotFound, "", nil, nil)
+	})
+}
+
+func TestRoutePriority(t *testing.T) {
+	const routeFile = `
+show      /users/:id
+new    ^1 /users/new
+catchall ^-1 /:**path
+ab     ^1 /ab
+a         /a:x
+item      /items/:x
+int    ^1 /items/:#n
+other     /other
+zzz       /zzz
+`
+
+	testRouter(t, routeFile, false, func(router *Router) {
+		assertRoute(t, router, "/users/new", "new", map[string]string{}, "", "", []string{"GET"}, []string{})
+		assertRoute(t, router, "/users/12", "show", map[string]string{"id": "12"}, "", "", []string{"GET"}, []string{})
+		assertRoute(t, router, "/ab", "ab", map[string]string{}, "", "", []string{"GET"}, []string{})
+		assertRoute(t, router, "/ac", "a", map[string]string{"x": "c"}, "", "", []string{"GET"}, []string{})
+		assertRoute(t, router, "/items/12", "int", map[string]string{"n": "12"}, "", "", []string{"GET"}, []string{})
+		assertRoute(t, router, "/items/x", "item", map[string]string{"x": "x"}, "", "", []string{"GET"}, []string{})
+		assertRoute(t, router, "/other", "other", map[string]string{}, "", "", []string{"GET"}, []string{})
+		assertRoute(t, router, "/zzz", "zzz", map[string]string{}, "", "", []string{"GET"}, []string{})
+		assertRoute(t, router, "/users/12/nope", "catchall", map[string]string{"path": "users/12/nope"}, "", "", []string{"GET"}, []string{})
+	})
+}
+
+func TestRouteEnumeratedParameter(t *testing.T) {
+	const routeFile = `
+docs      /docs/:{lang in en,fr,de}/:page
+legacy    /docs/es/:page
+versioned /v/:{version in 1.0,1.1}
+`
+
+	testRouter(t, routeFile, false, func(router *Router) {
+		assertRoute(t, router, "/docs/en/intro", "docs", map[string]string{"lang": "en", "page": "intro"}, "", "", []string{"GET"}, []string{})
+		assertRoute(t, router, "/docs/de/intro", "docs", map[string]string{"lang": "de", "page": "intro"}, "", "", []string{"GET"}, []string{})
+		assertRoute(t, router, "/docs/es/intro", "legacy", map[string]string{"page": "intro"}, "", "", []string{"GET"}, []string{})
+		assertNoRoute(t, router, "/docs/it/intro")
+		assertNoRoute(t, router, "/docs/eng/intro")
+		assertRoute(t, router, "/v/1.1", "versioned", map[string]string{"version": "1.1"}, "", "", []string{"GET"}, []string{})
+		assertNoRoute(t, router, "/v/1x1")
+	})
+}
+
+func TestRouteParamRegexp(t *testing.T) {
+	const routeFile = `
+item  /items/:{id ~ [0-9a-f]+}
+slug  /items/:{slug ~ [a-z]+(-[a-z]+)+}
+dated /archive/:{year ~ [0-9][0-9][0-9][0-9]}/:{rest ~ .+}
+`
+
+	testRouter(t, routeFile, false, func(router *Router) {
+		assertRoute(t, router, "/items/c0ffee", "item", map[string]string{"id": "c0ffee"}, "", "", []string{"GET"}, []string{})
+		assertRoute(t, router, "/items/hello-world", "slug", map[string]string{"slug": "hello-world"}, "", "", []string{"GET"}, []string{})
+		assertNoRoute(t, router, "/items/hello")
+		assertRoute(t, router, "/archive/2024/x.y", "dated", map[string]string{"year": "2024", "rest": "x.y"}, "", "", []string{"GET"}, []string{})
+		assertNoRoute(t, router, "/archive/24/x")
+		assertNoRoute(t, router, "/archive/2024/x/y")
+	})
+}
+
+func TestRouteOptionalGroups(t *testing.T) {
+	const routeFile = `
+posts  /posts[/page/:#n]
+search /search[/:q[/:#page]]/results
+`
+
+	testRouter(t, routeFile, false, func(router *Router) {
+		assertRoute(t, router, "/posts", "posts", map[string]string{}, "", "", []string{"GET"}, []string{})
+		assertRoute(t, router, "/posts/", "posts", map[string]string{}, "", "", []string{"GET"}, []string{})
+		assertRoute(t, router, "/posts/page/2", "posts", map[string]string{"n": "2"}, "", "", []string{"GET"}, []string{})
+		assertNoRoute(t, router, "/posts/page")
+		assertNoRoute(t, router, "/posts/page/x")
+		assertRoute(t, router, "/search/results", "search", map[string]string{}, "", "", []string{"GET"}, []string{})
+		assertRoute(t, router, "/search/foo/results", "search", map[string]string{"q": "foo"}, "", "", []string{"GET"}, []string{})
+		assertRoute(t, router, "/search/foo/3/results", "search", map[string]string{"q": "foo", "page": "3"}, "", "", []string{"GET"}, []string{})
+	})
+}
+
+func TestRouteAlternations(t *testing.T) {
+	const routeFile = `
+photo  /(photos|images)/:id
+legacy /gallery/(old|older)/(a|b)
+`
+
+	testRouter(t, routeFile, false, func(router *Router) {
+		assertRoute(t, router, "/photos/1", "photo", map[string]string{"id": "1"}, "", "", []string{"GET"}, []string{})
+		assertRoute(t, router, "/images/1", "photo", map[string]string{"id": "1"}, "", "", []string{"GET"}, []string{})
+		assertNoRoute(t, router, "/pictures/1")
+
+		for url, expected := range map[string][]string{
+			"/photos/1":        {"photos"},
+			"/IMAGES/1":        {"images"},
+			"/gallery/older/a": {"older", "a"},
+			"/gallery/old/b":   {"old", "b"},
+		} {
+			result, ok := Route(router, url)
+			if !ok {
+				t.Errorf("Expected %v to be routed\n", url)
+				continue
+			}
+			if !reflect.DeepEqual(result.Alternatives, expected) {
+				t.Errorf("Expected alternatives %+v for %v, got %+v\n", expected, url, result.Alternatives)
+			}
+		}
+	})
+}
+
+func TestRouteMeta(t *testing.T) {
+	const routeFile = `
+users /users [auth=admin, ratelimit=100]
+  show /show/:id
+  public /public [auth=none]
+home /
+`
+
+	testRouter(t, routeFile, false, func(router *Router) {
+		for url, expected := range map[string]map[string]string{
+			"/users/show/1": {"auth": "admin", "ratelimit": "100"},
+			"/users/public": {"auth": "none", "ratelimit": "100"},
+			"/":             nil,
+		} {
+			result, ok := Route(router, url)
+			if !ok {
+				t.Errorf("Expected %v to be routed\n", url)
+				continue
+			}
+			if !reflect.DeepEqual(result.Meta, expected) {
+				t.Errorf("Expected metadata %+v for %v, got %+v\n", expected, url, result.Meta)
+			}
+		}
+	})
+}
+
+func TestRouteHost(t *testing.T) {
+	const routeFile = `
+api @api.example.com /
+  users /users/:id
+  about [GET] /about
+tenant @*.tenants.example.com /
+  home /home
+  page /pages/:page
+www @www.example.com,example.com /
+  about /about
+  page ^-1 /:page
+health /health/check
+`
+
+	testRouter(t, routeFile, false, func(router *Router) {
+		type tst struct {
+			method, host, url string
+			status            Status
+			name              string
+			hosts             []string
+		}
+
+		cases := []tst{
+			{"GET", "api.example.com", "/about", Found, "api/about", []string{"api.example.com"}},
+			{"POST", "api.example.com", "/about", MethodNotAllowed, "", nil},
+			{"GET", "api.example.com", "/users/1", Found, "api/users", []string{"api.example.com"}},
+			{"GET", "api.example.com", "/x", NotFound, "", nil},
+			{"GET", "www.example.com", "/about", Found, "www/about", []string{"www.example.com", "example.com"}},
+			{"GET", "www.example.com", "/x", Found, "www/page", []string{"www.example.com", "example.com"}},
+			{"GET", "Example.COM:8080", "/about", Found, "www/about", []string{"www.example.com", "example.com"}},
+			{"GET", "example.com.", "/about", Found, "www/about", []string{"www.example.com", "example.com"}},
+			{"GET", "foo.tenants.example.com", "/home", Found, "tenant/home", []string{"*.tenants.example.com"}},
+			{"GET", "foo.tenants.example.com", "/pages/x", Found, "tenant/page", []string{"*.tenants.example.com"}},
+			{"GET", "foo.tenants.example.com", "/about", NotFound, "", nil},
+			{"GET", "tenants.example.com", "/home", NotFound, "", nil},
+			{"GET", "a.b.tenants.example.com", "/home", NotFound, "", nil},
+			{"GET", "other.com", "/about", NotFound, "", nil},
+			{"GET", "other.com", "/health/check", Found, "health", nil},
+			{"GET", "api.example.com", "/health/check", Found, "health", nil},
+			{"GET", "", "/health/check", Found, "health", nil},
+		}
+
+		for _, c := range cases {
+			result, status := RouteHost(router, c.method, c.host, c.url)
+			if status != c.status {
+				t.Errorf("Expected %v %v %v to have status %v, got %v\n", c.method, c.host, c.url, c.status, status)
+				continue
+			}
+			if result.Name != c.name {
+				t.Errorf("Expected %v %v %v to resolve to '%v', got '%v'\n", c.method, c.host, c.url, c.name, result.Name)
+			}
+			if !reflect.DeepEqual(result.Hosts, c.hosts) {
+				t.Errorf("Expected hosts %+v for %v %v %v, got %+v\n", c.hosts, c.method, c.host, c.url, result.Hosts)
+			}
+		}
+
+		// Route and RouteMethod ignore routes restricted to particular hosts.
+		assertRouteMethod(t, router, "GET", "/home", NotFound, "", nil, nil)
+		assertRouteMethod(t, router, "GET", "/health/check", Found, "health", map[string]string{}, []string{"GET"})
+		assertNoRoute(t, router, "/home")
+		assertRoute(t, router, "/health/check", "health", map[string]string{}, "", "", []string{"GET"}, []string{})
+	})
+
+	// Routes with different hosts may share a path, and only RouteHost
+	// distinguishes between them.
+	testRouter(t, "api @api.example.com /about\nwww @www.example.com /about\n", false, func(router *Router) {
+		for host, name := range map[string]string{"api.example.com": "api", "www.example.com": "www"} {
+			if result, status := RouteHost(router, "GET", host, "/about"); status != Found || result.Name != name {
+				t.Errorf("Expected /about on %v to be routed to '%v', got %v %+v\n", host, name, status, result)
+			}
+		}
+		assertNoRoute(t, router, "/about")
+		assertRouteMethod(t, router, "GET", "/about", NotFound, "", nil, nil)
+	})
+
+	// A router for routes without hosts routes every host in the same way.
+	testRouter(t, "home /home\n", false, func(router *Router) {
+		if result, status := RouteHost(router, "GET", "example.com", "/home"); status != Found || result.Name != "home" {
+			t.Errorf("Expected /home to be routed to 'home', got %v %+v\n", status, result)
+		}
+	})
+}
+
+func TestNormalizeHost(t *testing.T) {
+	type tst struct {
+		from, to string
+	}
+
+	cases := []tst{
+		{"", ""},
+		{"example.com", "example.com"},
+		{"Example.COM", "example.com"},
+		{"example.com:8080", "example.com"},
+		{"example.com.", "example.com"},
+		{"example.com.:8080", "example.com"},
+		{"[::1]", "[::1]"},
+		{"[::1]:8080", "[::1]"},
+	}
+
+	for _, c := range cases {
+		out := normalizeHost(c.from)
+		if out != c.to {
+			t.Errorf("Expected %v -> %v, got %v\n", c.from, c.to, out)
+		}
+	}
+}
+
+func TestRouteMethodHeadAndOptions(t *testing.T) {
+	const routeFile = `
+page      [GET] /page
+page      [POST] /page
+headed    [GET] /headed
+headed    [HEAD] /headed
+opts      [PUT] /opts
+opts      [OPTIONS] /opts
+`
+
+	testRouter(t, routeFile, false, func(router *Router) {
+		assertRouteMethod(t, router, "HEAD", "/page", MethodNotAllowed, "", nil, []string{"GET", "POST"})
+		assertRouteMethod(t, router, "OPTIONS", "/page", MethodNotAllowed, "", nil, []string{"GET", "POST"})
+	})
+
+	testRouterWithOptions(t, routeFile, Options{HeadAsGet: true, AutoOptions: true}, func(router *Router) {
+		assertRouteMethod(t, router, "HEAD", "/page", Found, "page", map[string]string{}, []string{"GET"})
+		assertRouteMethod(t, router, "HEAD", "/headed", Found, "headed", map[string]string{}, []string{"HEAD"})
+		assertRouteMethod(t, router, "GET", "/headed", Found, "headed", map[string]string{}, []string{"GET"})
+		assertRouteMethod(t, router, "OPTIONS", "/page", AutomaticOptions, "", nil, []string{"GET", "HEAD", "OPTIONS", "POST"})
+		assertRouteMethod(t, router, "OPTIONS", "/opts", Found, "opts", map[string]string{}, []string{"OPTIONS"})
+		assertRouteMethod(t, router, "HEAD", "/opts", MethodNotAllowed, "", nil, []string{"OPTIONS", "PUT"})
+		assertRouteMethod(t, router, "DELETE", "/page", MethodNotAllowed, "", nil, []string{"GET", "HEAD", "OPTIONS", "POST"})
+		assertRouteMethod(t, router, "OPTIONS", "/nope", NotFound, "", nil, nil)
+
+		r, _ := RouteMethod(router, "DELETE", "/page")
+		if r.Allow() != "GET, HEAD, OPTIONS, POST" {
+			t.Errorf("Unexpected Allow header value %v\n", r.Allow())
+		}
+	})
+}
+
 func TestNormalizeUrl(t *testing.T) {
 	type tst struct {
 		from, to string
@@ -272,8 +570,12 @@ func makeBSRoute(i, n int, param string) string {
 }
 
 func testRouter(t *testing.T, routeFile string, caseSensitive bool, callback func(*Router)) {
+	testRouterWithOptions(t, routeFile, Options{CaseSensitive: caseSensitive}, callback)
+}
+
+func testRouterWithOptions(t *testing.T, routeFile string, options Options, callback func(*Router)) {
 	casePolicy := compiler.DisallowUpperCase
-	if caseSensitive {
+	if options.CaseSensitive {
 		casePolicy = compiler.AllowUpperCase
 	}
 
@@ -289,7 +591,7 @@ func testRouter(t *testing.T, routeFile string, caseSensitive bool, callback fun
 	routesJson, _ := compiler.RouteRegexpsToJSON(&rrs, nil)
 	//fmt.Printf("JS %v\n", string(routesJson))
 
-	router, err := MakeRouter(routesJson, caseSensitive)
+	router, err := MakeRouterWithOptions(routesJson, options)
 	if err != nil {
 		t.Errorf("%v\n", err)
 	}
@@ -337,6 +639,26 @@ func assertRoute(t *testing.T, router *Router, url, expectedName string, expecte
 	}
 }
 
+func assertRouteMethod(t *testing.T, router *Router, method, url string, expectedStatus Status, expectedName string, expectedParams map[string]string, expectedMethods []string) {
+	routeResult, status := RouteMethod(router, method, url)
+	if status != expectedStatus {
+		t.Errorf("Expected %v %v to have status %v, got %v\n", method, url, expectedStatus, status)
+		return
+	}
+
+	if routeResult.Name != expectedName {
+		t.Errorf("Expected %v %v to resolve to '%v', got '%v'\n", method, url, expectedName, routeResult.Name)
+	}
+
+	if !reflect.DeepEqual(routeResult.Params, expectedParams) {
+		t.Errorf("Expected params: %+v\nGot params: %+v\n", expectedParams, routeResult.Params)
+	}
+
+	if !reflect.DeepEqual(routeResult.Methods, expectedMethods) {
+		t.Errorf("Expected methods: %+v\nGot methods: %+v\n", expectedMethods, routeResult.Methods)
+	}
+}
+
 func benchmarkRouterSimpleRoutes(b *testing.B, nRoutes int) {
 	var sb strings.Builder
 	for i := 0; i < nRoutes; i++ {
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/addrummond/claney/compiler"
+	"github.com/addrummond/claney/router"
+)
+
+// A routeTest is a single case read from a test file. A line of the form
+//
+//	GET /managers/12/user/7 => managers/user manager_id=12 user_id=7
+//
+// says that the URL should be routed to the named route with the given
+// parameters. Parameter values are compared with the values in the URL without
+// decoding them. The expectation may instead be '404' (no route matches the URL)
+// or '405' (some route matches the URL but not the method). The method may be
+// omitted, in which case the URL is routed without regard to the method.
+type routeTest struct {
+	line   int
+	method string
+	host   string // empty if the URL is routed without regard to the host
+	url    string
+	status router.Status
+	name   string
+	params map[string]string
+}
+
+func testMain(args []string) int {
+	fs := flag.NewFlagSet("claney test", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: claney test [options] testfile...\n")
+		fs.PrintDefaults()
+	}
+	inputs := addInputFlags(fs)
+	_ = fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 1
+	}
+
+	params := runParams{
+		testFiles:  fs.Args(),
+		withReader: withReader,
+		withWriter: withWriter,
+		fprintf:    fmt.Fprintf,
+	}
+	inputs.setInputParams(&params)
+
+	return runTests(params)
+}
+
+func runTests(params runParams) int {
+	var r router.Router
+	ok := false
+	err := withInputReaders(params, func(fancyInputReaders, jsonInputReaders, openAPIInputReaders []io.Reader) {
+		r, ok = compileRouter(params, fancyInputReaders, jsonInputReaders, openAPIInputReaders)
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	if !ok {
+		return 1
+	}
+
+	nTests, nFailures := 0, 0
+	for _, filename := range params.testFiles {
+		err := params.withReader(filename, func(rd io.Reader) {
+			scanner := bufio.NewScanner(rd)
+			line := 0
+			for scanner.Scan() {
+				line++
+				test, isTest, err := parseTestLine(line, scanner.Text())
+				if !isTest {
+					continue
+				}
+				nTests++
+				if err != nil {
+					_, _ = params.fprintf(os.Stderr, "%v:%v: %v\n", filename, line, err)
+					nFailures++
+				} else if !runTest(params, &r, filename, &test) {
+					nFailures++
+				}
+			}
+			if err := scanner.Err(); err != nil {
+				_, _ = params.fprintf(os.Stderr, "%v: %v\n", filename, err)
+				nFailures++
+			}
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+	}
+
+	testsString := "tests"
+	if nTests == 1 {
+		testsString = "test"
+	}
+	if nFailures > 0 {
+		_, _ = params.fprintf(os.Stdout, "%v of %v %v failed\n", nFailures, nTests, testsString)
+		return 1
+	}
+	_, _ = params.fprintf(os.Stdout, "%v %v passed\n", nTests, testsString)
+	return 0
+}
+
+// compileRouter compiles the input files to a router in memory.
+func compileRouter(params runParams, fancyInputReaders []io.Reader, jsonInputReaders []io.Reader, openAPIInputReaders []io.Reader) (router.Router, bool) {
+	routes, filter, ok := compileInputFiles(params, os.Stderr, fancyInputReaders, jsonInputReaders, openAPIInputReaders)
+	if !ok {
+		return router.Router{}, false
+	}
+
+	routeRegexps := compiler.GetRouteRegexps(routes, filter)
+	json, _ := compiler.RouteRegexpsToJSON(&routeRegexps, filter)
+	r, err := router.MakeRouter(json, params.allowUpperCase)
+	if err != nil {
+		_, _ = params.fprintf(os.Stderr, "%v\n", err)
+		return router.Router{}, false
+	}
+	return r, true
+}
+
+// runTest runs a test read from the given file, reporting it if it fails. It
+// returns false if the test failed.
+func runTest(params runParams, r *router.Router, filename string, test *routeTest) bool {
+	var result router.RouteResult
+	var status router.Status
+	if test.method == "" {
+		var ok bool
+		result, ok = router.Route(r, test.url)
+		status = router.NotFound
+		if ok {
+			status = router.Found
+		}
+	} else if test.host != "" {
+		result, status = router.RouteHost(r, test.method, test.host, test.url)
+	} else {
+		result, status = router.RouteMethod(r, test.method, test.url)
+	}
+
+	if testPassed(test, &result, status) {
+		return true
+	}
+
+	request := test.host + test.url
+	if test.method != "" {
+		request = test.method + " " + request
+	}
+	_, _ = params.fprintf(os.Stderr, "%v:%v: %v: expected %v, got %v\n", filename, test.line, request, describeExpectation(test), describeResult(&result, status))
+	return false
+}
+
+// parseTestLine parses a line of a test file. isTest is false for blank lines
+// and comment lines.
+func parseTestLine(line int, text string) (test routeTest, isTest bool, err error) {
+	text = strings.TrimSpace(text)
+	if text == "" || text[0] == '#' {
+		return
+	}
+	isTest = true
+
+	request, expectation, ok := strings.Cut(text, "=>")
+	if !ok {
+		err = fmt.Errorf("expected a line of the form 'METHOD URL => EXPECTATION'")
+		return
+	}
+
+	test.line = line
+	requestFields := strings.Fields(request)
+	switch len(requestFields) {
+	case 1:
+		test.url = requestFields[0]
+	case 2:
+		test.method = requestFields[0]
+		test.url = requestFields[1]
+	default:
+		err = fmt.Errorf("expected a method and URL before '=>'")
+		return
+	}
+	// A URL that doesn't start with '/' starts with a host.
+	if !strings.HasPrefix(test.url, "/") {
+		var path string
+		test.host, path, _ = strings.Cut(test.url, "/")
+		test.url = "/" + path
+		if test.method == "" {
+			err = fmt.Errorf("a method must be given for a test with a host")
+			return
+		}
+	}
+
+	expectationFields := strings.Fields(expectation)
+	if len(expectationFields) == 0 {
+		err = fmt.Errorf("expected a route name, '404' or '405' after '=>'")
+		return
+	}
+
+	switch expectationFields[0] {
+	case "404":
+		test.status = router.NotFound
+	case "405":
+		test.status = router.MethodNotAllowed
+	default:
+		test.status = router.Found
+		test.name = expectationFields[0]
+		test.params = make(map[string]string)
+		for _, f := range expectationFields[1:] {
+			k, v, ok := strings.Cut(f, "=")
+			if !ok {
+				err = fmt.Errorf("expected a parameter of the form 'NAME=VALUE', got %v", f)
+				return
+			}
+			test.params[k] = v
+		}
+		return
+	}
+
+	if len(expectationFields) > 1 {
+		err = fmt.Errorf("unexpected text after %v", expectationFields[0])
+	}
+	if test.status == router.MethodNotAllowed && test.method == "" {
+		err = fmt.Errorf("a method must be given for a '405' test")
+	}
+	return
+}
+
+func testPassed(test *routeTest, result *router.RouteResult, status router.Status) bool {
+	if status != test.status {
+		return false
+	}
+	if status != router.Found {
+		return true
+	}
+	if result.Name != test.name || len(result.Params) != len(test.params) {
+		return false
+	}
+	for k, v := range test.params {
+		if rv, ok := result.Params[k]; !ok || rv != v {
+			return false
+		}
+	}
+	return true
+}
+
+func describeExpectation(test *routeTest) string {
+	switch test.status {
+	case router.NotFound:
+		return "404"
+	case router.MethodNotAllowed:
+		return "405"
+	}
+	return describeRoute(test.name, test.params)
+}
+
+func describeResult(result *router.RouteResult, status router.Status) string {
+	switch status {
+	case router.NotFound:
+		return "404"
+	case router.MethodNotAllowed:
+		return fmt.Sprintf("405 (allowed methods: %v)", result.Allow())
+	}
+	return describeRoute(result.Name, result.Params)
+}
+
+func describeRoute(name string, params map[string]string) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	sb.WriteString(name)
+	for _, k := range keys {
+		sb.WriteString(" ")
+		sb.WriteString(k)
+		sb.WriteString("=")
+		sb.WriteString(params[k])
+	}
+	return sb.String()
+}
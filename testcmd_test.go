@@ -0,0 +1,133 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const testCmdRoutes = `
+managers /managers/:manager_id
+  user /user/:#user_id
+  create [POST] /new
+`
+
+func TestRunTestsPassing(t *testing.T) {
+	const tests = `
+# Comments and blank lines are ignored.
+
+GET /managers/12/user/7 => managers/user manager_id=12 user_id=7
+GET /managers/12/user/7?x=y#anchor => managers/user manager_id=12 user_id=7
+/managers/12/user/7 => managers/user manager_id=12 user_id=7
+GET /managers/a%20b/user/7 => managers/user manager_id=a%20b user_id=7
+GET /nope => 404
+GET /managers/12/user/x => 404
+GET /managers/1/new => 405
+POST /managers/1/new => managers/create manager_id=1
+`
+
+	var consoleOutb strings.Builder
+	exitCode := runTests(runParams{
+		fancyInputFiles: []string{"routes"},
+		testFiles:       []string{"tests"},
+		withReader:      mockMultifileReader(map[string]string{"routes": testCmdRoutes, "tests": tests}),
+		fprintf:         getAccumFprintf(&consoleOutb),
+		nameSeparator:   "/",
+	})
+	if exitCode != 0 {
+		t.Fatalf("Expected 0 exit code, got %v\n%v\n", exitCode, consoleOutb.String())
+	}
+	if consoleOut := consoleOutb.String(); consoleOut != "8 tests passed\n" {
+		t.Fatalf("Did not get expected output, got\n%v\n", consoleOut)
+	}
+}
+
+func TestRunTestsFailing(t *testing.T) {
+	const tests1 = `GET /managers/12/user/7 => managers/user manager_id=13 user_id=7
+GET /managers/12/user/7 => managers/user
+GET /managers/12/user/7 => 404
+`
+	const tests2 = `
+POST /managers/12/user/7 => managers/user manager_id=12 user_id=7
+GET /nope => managers/user
+GET /nope
+GET /managers/1 => 405 extra
+/managers/1/new => 405
+`
+
+	var consoleOutb strings.Builder
+	exitCode := runTests(runParams{
+		fancyInputFiles: []string{"routes"},
+		testFiles:       []string{"tests1", "tests2"},
+		withReader:      mockMultifileReader(map[string]string{"routes": testCmdRoutes, "tests1": tests1, "tests2": tests2}),
+		fprintf:         getAccumFprintf(&consoleOutb),
+		nameSeparator:   "/",
+	})
+	if exitCode != 1 {
+		t.Fatalf("Expected 1 exit code, got %v\n", exitCode)
+	}
+
+	const expectedConsoleOut = "tests1:1: GET /managers/12/user/7: expected managers/user manager_id=13 user_id=7, got managers/user manager_id=12 user_id=7\n" +
+		"tests1:2: GET /managers/12/user/7: expected managers/user, got managers/user manager_id=12 user_id=7\n" +
+		"tests1:3: GET /managers/12/user/7: expected 404, got managers/user manager_id=12 user_id=7\n" +
+		"tests2:2: POST /managers/12/user/7: expected managers/user manager_id=12 user_id=7, got 405 (allowed methods: GET)\n" +
+		"tests2:3: GET /nope: expected managers/user, got 404\n" +
+		"tests2:4: expected a line of the form 'METHOD URL => EXPECTATION'\n" +
+		"tests2:5: unexpected text after 405\n" +
+		"tests2:6: a method must be given for a '405' test\n" +
+		"8 of 8 tests failed\n"
+	if consoleOut := consoleOutb.String(); consoleOut != expectedConsoleOut {
+		t.Fatalf("Did not get expected output, got\n%v\n", consoleOut)
+	}
+}
+
+func TestRunTestsHosts(t *testing.T) {
+	const routes = `
+api @api.example.com /
+  about /about
+www @www.example.com,*.www.example.com /
+  about /about
+`
+	const tests = `GET api.example.com/about => api/about
+GET WWW.example.com:8080/about => www/about
+GET foo.www.example.com/about?x=y => www/about
+GET example.com/about => 404
+GET api.example.com/about => www/about
+api.example.com/about => api/about
+`
+
+	var consoleOutb strings.Builder
+	exitCode := runTests(runParams{
+		fancyInputFiles: []string{"routes"},
+		testFiles:       []string{"tests"},
+		withReader:      mockMultifileReader(map[string]string{"routes": routes, "tests": tests}),
+		fprintf:         getAccumFprintf(&consoleOutb),
+		nameSeparator:   "/",
+	})
+	if exitCode != 1 {
+		t.Fatalf("Expected 1 exit code, got %v\n", exitCode)
+	}
+
+	const expectedConsoleOut = "tests:5: GET api.example.com/about: expected www/about, got api/about\n" +
+		"tests:6: a method must be given for a test with a host\n" +
+		"2 of 6 tests failed\n"
+	if consoleOut := consoleOutb.String(); consoleOut != expectedConsoleOut {
+		t.Fatalf("Did not get expected output, got\n%v\n", consoleOut)
+	}
+}
+
+func TestRunTestsRouteErrors(t *testing.T) {
+	var consoleOutb strings.Builder
+	exitCode := runTests(runParams{
+		fancyInputFiles: []string{"routes"},
+		testFiles:       []string{"tests"},
+		withReader:      mockMultifileReader(map[string]string{"routes": "a /foo\nb /foo\n", "tests": "GET /foo => a\n"}),
+		fprintf:         getAccumFprintf(&consoleOutb),
+		nameSeparator:   "/",
+	})
+	if exitCode != 1 {
+		t.Fatalf("Expected 1 exit code, got %v\n", exitCode)
+	}
+	if consoleOut := consoleOutb.String(); consoleOut != "routes:1: (and routes:2): routes overlap; both match /foo\n" {
+		t.Fatalf("Did not get expected output, got\n%v\n", consoleOut)
+	}
+}